@@ -2,31 +2,15 @@ package main
 
 import (
 	"fmt"
-	"net/http"
 	"net/url"
-	"regexp"
-	"strings"
-)
-
-func sanitizeQueryParam(param string) string {
-	// Remove any HTML or JavaScript tags
-	param = regexp.MustCompile(`<[^>]*>`).ReplaceAllString(param, "")
-
-	// Remove any single quotes
-	param = strings.Replace(param, "'", "\\'", -1)
-
-	// Remove any double quotes
-	param = strings.Replace(param, "\"", "\\\"", -1)
+	"sort"
 
-	// Remove any backslashes (except for those used to escape quotes)
-	param = strings.Replace(param, `\`, "\\", -1)
-
-	return param
-}
+	"snippets/493779/sanitizer"
+)
 
 func main() {
-	// Example query string
-	queryString := "user=admin&password=secret&action=<script>alert('XSS');</script>"
+	// Example query string, with the ";" escaped as url.ParseQuery requires
+	queryString := "user=admin&password=secret&action=<script>alert('XSS')%3B</script>"
 
 	// Parse the query string
 	parsedQuery, err := url.ParseQuery(queryString)
@@ -35,19 +19,20 @@ func main() {
 		return
 	}
 
-	// Sanitize each parameter
-	sanitizedParams := make(url.Values)
-	for key, value := range parsedQuery {
-		sanitizedValues := make([]string, len(value))
-		for i, v := range value {
-			sanitizedValues[i] = sanitizeQueryParam(v)
+	// The values are kept as sent and encoded for where they are written:
+	// Encode escapes them for a query string, and sanitizer.HTMLBody for
+	// the text of a page
+	fmt.Println("Original query string:", queryString)
+	fmt.Println("Re-encoded query string:", parsedQuery.Encode())
+
+	keys := make([]string, 0, len(parsedQuery))
+	for key := range parsedQuery {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range parsedQuery[key] {
+			fmt.Printf("<p>%s: %s</p>\n", sanitizer.HTMLBody(key), sanitizer.HTMLBody(value))
 		}
-		sanitizedParams[key] = sanitizedValues
 	}
-
-	// Convert back to a query string
-	sanitizedQueryString := sanitizedParams.Encode()
-
-	fmt.Println("Original query string:", queryString)
-	fmt.Println("Sanitized query string:", sanitizedQueryString)
-}
\ No newline at end of file
+}
@@ -0,0 +1,3 @@
+module snippets/493779
+
+go 1.22
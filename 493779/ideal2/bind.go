@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Binder decodes HTTP requests into typed structs and validates them.
+// Fields are populated from the request according to their tags:
+//
+//	type GetUser struct {
+//		ID      int    `path:"id" validate:"required,min=1"`
+//		Email   string `query:"email" validate:"omitempty,email"`
+//		TraceID string `header:"X-Trace-ID"`
+//		Name    string `json:"name" form:"name" validate:"required"`
+//	}
+//
+// The body is decoded first (JSON into json-tagged fields, forms into
+// form-tagged fields), then path, query and header values are applied.
+type Binder struct {
+	Validator *Validator
+
+	// PathVars returns the route variables of a request. Set it to mux.Vars
+	// for gorilla/mux routers; when nil, http.Request.PathValue is used,
+	// which serves the patterns of the standard http.ServeMux.
+	PathVars func(*http.Request) map[string]string
+
+	// MaxBodyBytes limits the body size; 0 means 1 MiB
+	MaxBodyBytes int64
+
+	// Schema, if set, checks JSON bodies before they are decoded, so the
+	// JSON Schema from an API description can be enforced as is. Its
+	// failures are reported with JSON Pointer paths.
+	Schema *Schema
+}
+
+// DefaultBinder is used by Bind and Decode
+var DefaultBinder = &Binder{Validator: NewValidator()}
+
+// DecodeError reports a request that could not be decoded into the target
+// struct, as opposed to one that decoded but failed validation. Errors
+// holds one entry per unconvertible field; it is empty for body-level
+// problems such as malformed JSON.
+type DecodeError struct {
+	Err    error
+	Errors ValidationErrors
+}
+
+func (e *DecodeError) Error() string {
+	if len(e.Errors) > 0 {
+		return "bind: " + e.Errors.Error()
+	}
+	return "bind: " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// Bind wraps a typed handler as a plain http.HandlerFunc, so it works with
+// http.ServeMux and gorilla/mux alike. The request is decoded and validated
+// with DefaultBinder; on failure a problem document is written with status
+// 400 (undecodable input) or 422 (failed validation) and handler is not
+// called.
+func Bind[T any](handler func(http.ResponseWriter, *http.Request, T)) http.HandlerFunc {
+	return BindWith(DefaultBinder, handler)
+}
+
+// BindWith is Bind with an explicit Binder
+func BindWith[T any](b *Binder, handler func(http.ResponseWriter, *http.Request, T)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		in, err := DecodeWith[T](b, r)
+		if err != nil {
+			writeBindError(w, err)
+			return
+		}
+		handler(w, r, in)
+	}
+}
+
+// Decode decodes and validates r into a T with DefaultBinder
+func Decode[T any](r *http.Request) (T, error) {
+	return DecodeWith[T](DefaultBinder, r)
+}
+
+// DecodeWith decodes and validates r into a T, which must be a struct type.
+// The error is a *DecodeError or ValidationErrors.
+func DecodeWith[T any](b *Binder, r *http.Request) (T, error) {
+	var in T
+	rv := reflect.ValueOf(&in).Elem()
+	if rv.Kind() != reflect.Struct {
+		return in, fmt.Errorf("bind: %T is not a struct", in)
+	}
+
+	if err := b.decodeBody(r, &in); err != nil {
+		return in, err
+	}
+
+	var errs ValidationErrors
+	b.bindValues(r, rv, &errs)
+	if len(errs) > 0 {
+		return in, &DecodeError{Err: errors.New("request values do not match field types"), Errors: errs}
+	}
+
+	if b.Validator != nil {
+		if err := b.Validator.StructCtx(r.Context(), in); err != nil {
+			return in, err
+		}
+	}
+	return in, nil
+}
+
+// decodeBody reads a JSON or form body, if there is one
+func (b *Binder) decodeBody(r *http.Request, in any) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	limit := b.MaxBodyBytes
+	if limit == 0 {
+		limit = 1 << 20
+	}
+	r.Body = http.MaxBytesReader(nil, r.Body, limit)
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return &DecodeError{Err: fmt.Errorf("invalid Content-Type: %w", err)}
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		if b.Schema != nil {
+			return b.decodeSchemaBody(r, in)
+		}
+		if err := json.NewDecoder(r.Body).Decode(in); err != nil && !errors.Is(err, io.EOF) {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				verr := &ValidationError{
+					Field:   typeErr.Field,
+					Rule:    "type",
+					Code:    "invalid_type",
+					Message: fmt.Sprintf("%s must be of type %s", typeErr.Field, typeErr.Type),
+				}
+				return &DecodeError{Err: err, Errors: ValidationErrors{verr}}
+			}
+			return &DecodeError{Err: fmt.Errorf("invalid JSON body: %w", err)}
+		}
+	case mediaType == "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			return &DecodeError{Err: fmt.Errorf("invalid form body: %w", err)}
+		}
+	case mediaType == "multipart/form-data":
+		if err := r.ParseMultipartForm(limit); err != nil {
+			return &DecodeError{Err: fmt.Errorf("invalid multipart body: %w", err)}
+		}
+	default:
+		return &DecodeError{Err: fmt.Errorf("unsupported Content-Type %q", mediaType)}
+	}
+	return nil
+}
+
+// decodeSchemaBody validates a JSON body against b.Schema and then decodes it
+func (b *Binder) decodeSchemaBody(r *http.Request, in any) error {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return &DecodeError{Err: fmt.Errorf("reading body: %w", err)}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	var instance any
+	if err := json.Unmarshal(data, &instance); err != nil {
+		return &DecodeError{Err: fmt.Errorf("invalid JSON body: %w", err)}
+	}
+	if err := b.Schema.ValidateCtx(r.Context(), instance); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, in); err != nil {
+		return &DecodeError{Err: fmt.Errorf("invalid JSON body: %w", err)}
+	}
+	return nil
+}
+
+// bindValues copies path, query, header and form values into tagged fields
+func (b *Binder) bindValues(r *http.Request, rv reflect.Value, errs *ValidationErrors) {
+	var pathVars map[string]string
+	if b.PathVars != nil {
+		pathVars = b.PathVars(r)
+	}
+	query := r.URL.Query()
+
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		var values []string
+		var name string
+		if name = sf.Tag.Get("form"); name != "" && r.PostForm != nil {
+			values = r.PostForm[name]
+		}
+		if name = sf.Tag.Get("path"); name != "" {
+			if pathVars != nil {
+				if v, ok := pathVars[name]; ok {
+					values = []string{v}
+				}
+			} else if v := r.PathValue(name); v != "" {
+				values = []string{v}
+			}
+		}
+		if name = sf.Tag.Get("query"); name != "" && query.Has(name) {
+			values = query[name]
+		}
+		if name = sf.Tag.Get("header"); name != "" {
+			if v := r.Header.Values(name); len(v) > 0 {
+				values = v
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		if err := setField(rv.Field(i), values); err != nil {
+			field := fieldName(sf)
+			var value any = values[0]
+			if b.sensitiveField(sf) {
+				value = redacted
+			}
+			*errs = append(*errs, &ValidationError{
+				Field:   field,
+				Rule:    "type",
+				Value:   value,
+				Code:    "invalid_type",
+				Message: fmt.Sprintf("%s must be of type %s", field, sf.Type),
+			})
+		}
+	}
+}
+
+// sensitiveField reports whether the field's validate tag, under the tag
+// name of b's Validator, marks it sensitive, so that its rejected values
+// are redacted
+func (b *Binder) sensitiveField(sf reflect.StructField) bool {
+	tagName := "validate"
+	if b.Validator != nil {
+		tagName = b.Validator.tagName
+	}
+	for _, part := range strings.Split(sf.Tag.Get(tagName), ",") {
+		if strings.TrimSpace(part) == "sensitive" {
+			return true
+		}
+	}
+	return false
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// setField converts the raw request values into the field's type
+func setField(fv reflect.Value, values []string) error {
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, v := range values {
+			if err := setScalar(slice.Index(i), v); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+	return setScalar(fv, values[0])
+}
+
+func setScalar(fv reflect.Value, raw string) error {
+	if fv.Kind() == reflect.Pointer {
+		ptr := reflect.New(fv.Type().Elem())
+		if err := setScalar(ptr.Elem(), raw); err != nil {
+			return err
+		}
+		fv.Set(ptr)
+		return nil
+	}
+
+	if fv.Addr().Type().Implements(textUnmarshalerType) {
+		return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if fv.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(raw)
+			if err != nil {
+				return err
+			}
+			fv.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(raw, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
+
+// writeBindError writes the problem document for a failed DecodeWith
+func writeBindError(w http.ResponseWriter, err error) {
+	var decodeErr *DecodeError
+	var verrs ValidationErrors
+	switch {
+	case errors.As(err, &decodeErr):
+		problem := decodeErr.Errors.Problem(http.StatusBadRequest)
+		problem.Title = "Malformed request"
+		if len(decodeErr.Errors) == 0 {
+			problem.Detail = decodeErr.Err.Error()
+			problem.Errors = ValidationErrors{}
+		}
+		writeProblem(w, problem)
+	case errors.As(err, &verrs):
+		verrs.WriteProblem(w, http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindTarget struct {
+	ID      int           `path:"id" validate:"required,min=1"`
+	Email   string        `query:"email" validate:"omitempty,email"`
+	Tags    []string      `query:"tag"`
+	Wait    time.Duration `query:"wait"`
+	TraceID string        `header:"X-Trace-ID"`
+	Name    string        `json:"name" form:"name" validate:"required"`
+	Age     int           `json:"age" form:"age" validate:"omitempty,max=120"`
+	PIN     int           `query:"pin" validate:"sensitive"`
+}
+
+func TestDecode(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/users/7?email=a@example.com&tag=x&tag=y&wait=2s", strings.NewReader(`{"name": "Ann", "age": 30}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Trace-ID", "abc")
+	req.SetPathValue("id", "7")
+	got, err := Decode[bindTarget](req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := bindTarget{ID: 7, Email: "a@example.com", Tags: []string{"x", "y"}, Wait: 2 * time.Second, TraceID: "abc", Name: "Ann", Age: 30}
+	if got.ID != expected.ID || got.Email != expected.Email || strings.Join(got.Tags, ",") != "x,y" || got.Wait != expected.Wait ||
+		got.TraceID != expected.TraceID || got.Name != expected.Name || got.Age != expected.Age {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+
+	// Form bodies and router-supplied path variables
+	form := url.Values{"name": {"Bob"}, "age": {"41"}}
+	req = httptest.NewRequest(http.MethodPost, "/users/9", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	binder := &Binder{Validator: NewValidator(), PathVars: func(*http.Request) map[string]string { return map[string]string{"id": "9"} }}
+	got, err = DecodeWith[bindTarget](binder, req)
+	if err != nil || got.ID != 9 || got.Name != "Bob" || got.Age != 41 {
+		t.Errorf("Expected Bob, 41, with ID 9, got %+v (%v)", got, err)
+	}
+}
+
+func TestBindStatus(t *testing.T) {
+	handler := Bind(func(w http.ResponseWriter, r *http.Request, in bindTarget) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		status      int
+		errors      string // field:rule of each reported error
+	}{
+		{"Valid", "/?email=a@example.com", "application/json", `{"name": "Ann"}`, http.StatusNoContent, ""},
+		{"Bad query type", "/?wait=soon", "application/json", `{"name": "Ann"}`, http.StatusBadRequest, "wait:type"},
+		{"Bad JSON type", "/", "application/json", `{"name": 3}`, http.StatusBadRequest, "name:type"},
+		{"Malformed JSON", "/", "application/json", `{"name":`, http.StatusBadRequest, ""},
+		{"Bad form type", "/", "application/x-www-form-urlencoded", "name=Ann&age=old", http.StatusBadRequest, "age:type"},
+		{"Unsupported body", "/", "text/plain", "Ann", http.StatusBadRequest, ""},
+		{"Too large", "/", "application/json", `{"name": "` + strings.Repeat("a", 1<<20) + `"}`, http.StatusBadRequest, ""},
+		{"Failed rules", "/?email=bad", "application/json", `{"age": 130}`, http.StatusUnprocessableEntity, "id:required email:email name:required age:max"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.status == http.StatusNoContent {
+				req.SetPathValue("id", "1")
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+			if tt.status == http.StatusNoContent {
+				return
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("Expected a problem document, got %s", ct)
+			}
+			var problem Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range problem.Errors {
+				got = append(got, e.Field+":"+e.Rule)
+			}
+			if strings.Join(got, " ") != tt.errors || problem.Status != tt.status {
+				t.Errorf("Expected status %d with %q, got %d with %q", tt.status, tt.errors, problem.Status, got)
+			}
+		})
+	}
+}
+
+func TestDecodeRedactsSensitive(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?pin=12x4", nil)
+	_, err := DecodeWith[bindTarget](&Binder{}, req)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || len(decodeErr.Errors) != 1 {
+		t.Fatalf("Expected a *DecodeError for the pin, got %v", err)
+	}
+	if value := decodeErr.Errors[0].Value; value != redacted {
+		t.Errorf("Expected the pin to be redacted, got %v", value)
+	}
+}
+
+func TestDecodeRedactsSensitiveOtherTagName(t *testing.T) {
+	type target struct {
+		PIN int `query:"pin" check:"sensitive"`
+	}
+	v := NewValidator()
+	v.tagName = "check"
+	req := httptest.NewRequest(http.MethodGet, "/?pin=12x4", nil)
+	_, err := DecodeWith[target](&Binder{Validator: v}, req)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || len(decodeErr.Errors) != 1 || decodeErr.Errors[0].Value != redacted {
+		t.Errorf("Expected the pin to be redacted, got %v", err)
+	}
+}
+
+func TestDecodeSchema(t *testing.T) {
+	schema, err := NewValidator().CompileSchema([]byte(`{"type": "object", "required": ["name"], "properties": {"name": {"type": "string", "minLength": 2}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	binder := &Binder{Validator: NewValidator(), Schema: schema}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "A"}`))
+	req.Header.Set("Content-Type", "application/json")
+	_, err = DecodeWith[bindTarget](binder, req)
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) || len(verrs) != 1 || verrs[0].Field != "/name" {
+		t.Errorf("Expected the schema to reject /name, got %v", err)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// now is the clock used by the relative date rules; tests may replace it
+var now = time.Now
+
+// Named layout sets accepted by the `date` rule in place of a Go layout,
+// e.g. `validate:"date=rfc3339"`. Several Go layouts may also be given
+// separated by "|", e.g. `validate:"date=2006-01-02|02/01/2006"`.
+var dateLayouts = map[string][]string{
+	"rfc3339": {time.RFC3339Nano},
+	"iso8601": {
+		"2006-01-02",
+		"2006-01-02T15:04Z07:00",
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.999999999Z0700",
+		"2006-01-02T15:04:05.999999999",
+		"20060102",
+		"20060102T150405Z0700",
+	},
+}
+
+// weekDatePattern matches ISO 8601 week dates: 2024-W05-3, 2024-W05, 2024W053
+var weekDatePattern = regexp.MustCompile(`^(\d{4})-?W(\d{2})(?:-?([1-7]))?$`)
+
+// ParseDate parses value with the first matching layout. Unlike
+// datePattern, parsing rejects impossible dates such as 2024-02-30 or
+// 2023-13-45. Layouts may be Go layouts or the names "iso8601", "rfc3339"
+// and "week"; with no layouts the date-only form 2006-01-02 is used.
+func ParseDate(value string, layouts ...string) (time.Time, error) {
+	if len(layouts) == 0 {
+		layouts = []string{time.DateOnly}
+	}
+
+	var lastErr error
+	for _, layout := range expandLayouts(layouts) {
+		var t time.Time
+		var err error
+		if layout == "week" {
+			t, err = parseWeekDate(value)
+		} else {
+			t, err = time.Parse(layout, value)
+		}
+		if err == nil {
+			return t, nil
+		}
+		lastErr = err
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q: %w", value, lastErr)
+}
+
+// expandLayouts replaces named layout sets with their Go layouts
+func expandLayouts(layouts []string) []string {
+	var expanded []string
+	for _, layout := range layouts {
+		if set, ok := dateLayouts[strings.ToLower(layout)]; ok {
+			expanded = append(expanded, set...)
+			if strings.EqualFold(layout, "iso8601") {
+				expanded = append(expanded, "week")
+			}
+			continue
+		}
+		expanded = append(expanded, layout)
+	}
+	return expanded
+}
+
+// parseWeekDate parses an ISO 8601 week date into the UTC date it names.
+// The weekday defaults to Monday when omitted.
+func parseWeekDate(value string) (time.Time, error) {
+	m := weekDatePattern.FindStringSubmatch(value)
+	if m == nil {
+		return time.Time{}, errors.New("not an ISO week date")
+	}
+	year, _ := strconv.Atoi(m[1])
+	week, _ := strconv.Atoi(m[2])
+	day := 1
+	if m[3] != "" {
+		day, _ = strconv.Atoi(m[3])
+	}
+
+	// A year has 53 ISO weeks exactly when 28 December falls in week 53
+	_, lastWeek := time.Date(year, time.December, 28, 0, 0, 0, 0, time.UTC).ISOWeek()
+	if week < 1 || week > lastWeek {
+		return time.Time{}, fmt.Errorf("week %d out of range for %d", week, year)
+	}
+
+	// Week 1 is the week containing 4 January
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	offset := (int(jan4.Weekday()) + 6) % 7 // days since Monday
+	monday := jan4.AddDate(0, 0, -offset)
+	return monday.AddDate(0, 0, (week-1)*7+day-1), nil
+}
+
+// dateOf extracts a time from a time.Time field or parses a string field
+// with the layouts given, falling back to the ISO 8601 set
+func dateOf(value reflect.Value, layouts ...string) (time.Time, bool) {
+	if !value.IsValid() {
+		return time.Time{}, false
+	}
+	switch v := value.Interface().(type) {
+	case time.Time:
+		return v, !v.IsZero()
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, false
+		}
+		return *v, !v.IsZero()
+	case string:
+		if len(layouts) == 0 {
+			layouts = []string{"iso8601"}
+		}
+		t, err := ParseDate(v, layouts...)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// validateDateRule checks that a string is a real calendar date in one of
+// the layouts named by param (2006-01-02 when empty)
+func validateDateRule(value reflect.Value, param string) bool {
+	_, ok := dateOf(value, dateRuleLayouts(param)...)
+	return ok
+}
+
+// dateRuleLayouts returns the layouts named by the param of a date rule
+func dateRuleLayouts(param string) []string {
+	if param == "" {
+		return []string{time.DateOnly}
+	}
+	return strings.Split(param, "|")
+}
+
+// dateRangeRules compare a date with the current time or with a sibling
+// field. Alone they read strings as ISO 8601.
+var dateRangeRules = map[string]bool{
+	"future":    true,
+	"notfuture": true,
+	"within":    true,
+	"after":     true,
+	"before":    true,
+}
+
+// withDateLayouts makes the date range rules in rules read the field with
+// the layouts of the date rule beside them, so that "date=02/01/2006,
+// within=365" accepts 25/12/2024. They are skipped when the date does not
+// parse, which the date rule reports.
+func withDateLayouts(rules []rule) []rule {
+	var layouts []string
+	for _, r := range rules {
+		if r.name == "date" {
+			layouts = dateRuleLayouts(r.param)
+		}
+	}
+	if layouts == nil {
+		return rules
+	}
+	for i, r := range rules {
+		if !dateRangeRules[r.name] {
+			continue
+		}
+		check := r.fn
+		rules[i].fn = func(ctx context.Context, value, parent reflect.Value) error {
+			t, ok := dateOf(value, layouts...)
+			if !ok {
+				return nil
+			}
+			return check(ctx, reflect.ValueOf(t), parent)
+		}
+	}
+	return rules
+}
+
+// validateNotFutureRule rejects dates after the current time
+func validateNotFutureRule(value reflect.Value, _ string) bool {
+	t, ok := dateOf(value)
+	return ok && !t.After(now())
+}
+
+// validateFutureRule rejects dates at or before the current time
+func validateFutureRule(value reflect.Value, _ string) bool {
+	t, ok := dateOf(value)
+	return ok && t.After(now())
+}
+
+// withinRule requires the date to lie within param days of now, in
+// either direction, e.g. `validate:"within=30"`
+func withinRule(param string) (Rule, error) {
+	days, err := strconv.Atoi(param)
+	if err != nil || days < 0 {
+		return nil, fmt.Errorf("validate: expected a number of days, got %q", param)
+	}
+	limit := time.Duration(days) * 24 * time.Hour
+	return boolRule(func(value, _ reflect.Value, _ string) bool {
+		t, ok := dateOf(value)
+		if !ok {
+			return false
+		}
+		diff := t.Sub(now())
+		return diff >= -limit && diff <= limit
+	})(param)
+}
+
+// validateAfterRule requires the date to be strictly after the sibling
+// field named by param, e.g. `validate:"after=StartDate"`. A string
+// sibling is parsed with the layouts of its own date rule.
+func (v *Validator) validateAfterRule(value, parent reflect.Value, param string) bool {
+	return v.compareDateField(value, parent, param, func(t, other time.Time) bool { return t.After(other) })
+}
+
+// validateBeforeRule is the mirror of validateAfterRule
+func (v *Validator) validateBeforeRule(value, parent reflect.Value, param string) bool {
+	return v.compareDateField(value, parent, param, func(t, other time.Time) bool { return t.Before(other) })
+}
+
+func (v *Validator) compareDateField(value, parent reflect.Value, param string, ok func(t, other time.Time) bool) bool {
+	t, valid := dateOf(value)
+	if !valid {
+		return false
+	}
+	field, found := lookupField(parent, param)
+	if !found {
+		return false
+	}
+	other, valid := dateOf(field, v.siblingDateLayouts(parent, param)...)
+	if !valid {
+		// An unset or malformed sibling is reported by its own rules
+		return true
+	}
+	return ok(t, other)
+}
+
+// siblingDateLayouts returns the layouts of the date rule in the tag of the
+// sibling named name, or nil if it has none
+func (v *Validator) siblingDateLayouts(parent reflect.Value, name string) []string {
+	t := parent.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() || (sf.Name != name && fieldName(sf) != name) {
+			continue
+		}
+		for _, part := range strings.Split(sf.Tag.Get(v.tagName), ",") {
+			if rule, param, _ := strings.Cut(strings.TrimSpace(part), "="); rule == "date" {
+				return dateRuleLayouts(param)
+			}
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixClock sets the clock of the relative date rules for one test
+func fixClock(t *testing.T, at time.Time) {
+	t.Helper()
+	old := now
+	now = func() time.Time { return at }
+	t.Cleanup(func() { now = old })
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value    string
+		layouts  []string
+		expected string // as 2006-01-02, or "" for an error
+	}{
+		{"2024-02-29", nil, "2024-02-29"},
+		{"2024-02-30", nil, ""},
+		{"2023-13-45", nil, ""},
+		{"29/02/2024", []string{"02/01/2006"}, "2024-02-29"},
+		{"2024-02-29T10:00:00Z", []string{"iso8601"}, "2024-02-29"},
+		{"20240229", []string{"iso8601"}, "2024-02-29"},
+		{"2024-W01-1", []string{"iso8601"}, "2024-01-01"},
+		{"2020W537", []string{"week"}, "2021-01-03"},
+		{"2021-W53", []string{"week"}, ""},
+		{"2024-02-29", []string{"rfc3339"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseDate(tt.value, tt.layouts...)
+			if tt.expected == "" {
+				if err == nil {
+					t.Errorf("Expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil || got.Format(time.DateOnly) != tt.expected {
+				t.Errorf("Expected %s, got %s (%v)", tt.expected, got, err)
+			}
+		})
+	}
+}
+
+func TestDateRules(t *testing.T) {
+	fixClock(t, time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC))
+	type Trip struct {
+		Start  string     `json:"start" validate:"required,date=02/01/2006,within=365"`
+		End    string     `json:"end" validate:"required,date=02/01/2006,after=start"`
+		Born   string     `json:"born" validate:"omitempty,notfuture"`
+		Expiry *time.Time `json:"expiry" validate:"omitempty,future"`
+		Booked *time.Time `json:"booked" validate:"omitempty,before=expiry"`
+	}
+	past := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		trip     Trip
+		expected string
+	}{
+		{"Valid", Trip{Start: "15/06/2024", End: "20/06/2024", Born: "1990-05-01"}, ""},
+		{"Field layout reaches the range rules", Trip{Start: "15/06/2026", End: "16/06/2026"}, "start:within"},
+		{"End before start", Trip{Start: "15/06/2024", End: "14/06/2024"}, "end:after"},
+		{"Bad dates are reported once", Trip{Start: "2024-06-15", End: "31/02/2024"}, "start:date end:date"},
+		{"Born in the future", Trip{Start: "15/06/2024", End: "16/06/2024", Born: "2999-W01-1"}, "born:notfuture"},
+		{"Nil sibling", Trip{Start: "15/06/2024", End: "16/06/2024", Booked: &past}, ""},
+		{"Expired", Trip{Start: "15/06/2024", End: "16/06/2024", Expiry: &past}, "expiry:future"},
+	}
+	v := NewValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(failures(v.Struct(tt.trip)), " ")
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	if err := v.Var("days", "2024-06-15", "within=-1"); err == nil || failures(err) != nil {
+		t.Errorf("Expected a negative range to reject the tag, got %v", err)
+	}
+}
+
+func TestDateRulesOtherTagName(t *testing.T) {
+	// The sibling's layouts are read from the tag the Validator uses
+	type Trip struct {
+		Start string `json:"start" check:"date=02/01/2006"`
+		End   string `json:"end" check:"date=02/01/2006,after=start"`
+	}
+	v := NewValidator()
+	v.tagName = "check"
+	got := strings.Join(failures(v.Struct(Trip{Start: "15/06/2024", End: "14/06/2024"})), " ")
+	if got != "end:after" {
+		t.Errorf("Expected %q, got %q", "end:after", got)
+	}
+}
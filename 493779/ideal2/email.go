@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxLocalPartLength = 64
+	maxAddressLength   = 254
+)
+
+// Email is a parsed mailbox address
+type Email struct {
+	Local  string // local part exactly as given, quotes included
+	Domain string // ASCII (punycode) domain or address literal such as [192.0.2.1]
+}
+
+// String returns the canonical form of the address: the local part
+// untouched (it is case-sensitive per RFC 5321) and the domain lowercased
+// and converted to ASCII. This is the form to store and compare.
+func (e Email) String() string {
+	return e.Local + "@" + e.Domain
+}
+
+// ParseEmail parses an addr-spec following RFC 5321/5322: a dot-atom or
+// quoted-string local part, and a hostname or [IPv4] / [IPv6:...] address
+// literal domain. Internationalized local parts (RFC 6531) and domains
+// are accepted, the latter being converted to punycode.
+func ParseEmail(address string) (Email, error) {
+	at := strings.LastIndexByte(address, '@')
+	if at <= 0 || at == len(address)-1 {
+		return Email{}, fmt.Errorf("invalid email %q: missing local part or domain", address)
+	}
+	local, domain := address[:at], address[at+1:]
+
+	if err := checkLocalPart(local); err != nil {
+		return Email{}, fmt.Errorf("invalid email %q: %w", address, err)
+	}
+
+	if strings.HasPrefix(domain, "[") {
+		literal, err := checkAddressLiteral(domain)
+		if err != nil {
+			return Email{}, fmt.Errorf("invalid email %q: %w", address, err)
+		}
+		domain = literal
+	} else {
+		ascii, err := DomainToASCII(domain)
+		if err != nil {
+			return Email{}, fmt.Errorf("invalid email %q: %w", address, err)
+		}
+		domain = ascii
+	}
+
+	e := Email{Local: local, Domain: domain}
+	if len(e.String()) > maxAddressLength {
+		return Email{}, fmt.Errorf("invalid email %q: longer than %d octets", address, maxAddressLength)
+	}
+	return e, nil
+}
+
+// NormalizeEmail returns the canonical form of address, see Email.String
+func NormalizeEmail(address string) (string, error) {
+	e, err := ParseEmail(address)
+	if err != nil {
+		return "", err
+	}
+	return e.String(), nil
+}
+
+func checkLocalPart(local string) error {
+	if len(local) > maxLocalPartLength {
+		return fmt.Errorf("local part longer than %d octets", maxLocalPartLength)
+	}
+	if !utf8.ValidString(local) {
+		return errors.New("local part is not valid UTF-8")
+	}
+	if strings.HasPrefix(local, `"`) {
+		return checkQuotedString(local)
+	}
+
+	// dot-atom: atoms separated by single dots, no leading or trailing dot
+	for _, atom := range strings.Split(local, ".") {
+		if atom == "" {
+			return errors.New("local part has an empty atom")
+		}
+		for _, r := range atom {
+			if !isAtext(r) {
+				return fmt.Errorf("local part contains %q", r)
+			}
+		}
+	}
+	return nil
+}
+
+// isAtext reports whether r may appear unquoted in a local part
+func isAtext(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	case strings.ContainsRune("!#$%&'*+-/=?^_`{|}~", r):
+		return true
+	}
+	return r >= utf8.RuneSelf // RFC 6531 UTF8-non-ascii
+}
+
+// checkQuotedString validates a quoted local part such as "john doe"
+func checkQuotedString(s string) error {
+	if len(s) < 2 || !strings.HasSuffix(s, `"`) {
+		return errors.New("unterminated quoted local part")
+	}
+	body := s[1 : len(s)-1]
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case c == '\\':
+			i++
+			if i == len(body) || body[i] < ' ' && body[i] != '\t' || body[i] == 0x7f {
+				return errors.New("invalid quoted-pair in local part")
+			}
+		case c == '"':
+			return errors.New("unescaped quote in local part")
+		case c < ' ' && c != '\t' || c == 0x7f:
+			return fmt.Errorf("control character %q in local part", c)
+		}
+	}
+	return nil
+}
+
+// checkAddressLiteral validates [192.0.2.1] and [IPv6:2001:db8::1] domains
+// and returns them in canonical form
+func checkAddressLiteral(domain string) (string, error) {
+	if !strings.HasSuffix(domain, "]") {
+		return "", errors.New("unterminated address literal")
+	}
+	inner := domain[1 : len(domain)-1]
+
+	if rest, ok := cutPrefixFold(inner, "IPv6:"); ok {
+		addr, err := netip.ParseAddr(rest)
+		if err != nil || !addr.Is6() || addr.Zone() != "" {
+			return "", fmt.Errorf("invalid IPv6 address literal %q", domain)
+		}
+		return "[IPv6:" + addr.String() + "]", nil
+	}
+
+	addr, err := netip.ParseAddr(inner)
+	if err != nil || !addr.Is4() {
+		return "", fmt.Errorf("invalid IPv4 address literal %q", domain)
+	}
+	return "[" + addr.String() + "]", nil
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return s, false
+}
+
+// validateEmailRule checks a string with ParseEmail
+func validateEmailRule(value reflect.Value, _ string) bool {
+	if value.Kind() != reflect.String {
+		return false
+	}
+	_, err := ParseEmail(value.String())
+	return err == nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// redacted replaces the rejected value of fields tagged `sensitive`
+const redacted = "[REDACTED]"
+
+// Stable, machine-readable codes for the built-in rules. Clients should
+// switch on these rather than on Message, which is meant for humans.
+var ruleCodes = map[string]string{
+	"required":  "field_required",
+	"email":     "invalid_email",
+	"number":    "invalid_number",
+	"url":       "invalid_url",
+	"phone":     "invalid_phone",
+	"date":      "invalid_date",
+	"min":       "too_small",
+	"max":       "too_large",
+	"len":       "invalid_length",
+	"oneof":     "not_allowed",
+	"future":    "date_not_in_future",
+	"notfuture": "date_in_future",
+	"within":    "date_out_of_range",
+	"after":     "date_too_early",
+	"before":    "date_too_late",
+
+	"required_if":     "field_required",
+	"required_unless": "field_required",
+	"equal_field":     "field_mismatch",
+	"not_equal_field": "field_not_distinct",
+}
+
+// ValidationError describes one field that failed one rule
+type ValidationError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Value   any    `json:"value,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func newValidationError(field, rule, param string, value reflect.Value, sensitive bool) *ValidationError {
+	e := &ValidationError{
+		Field: field,
+		Rule:  rule,
+		Param: param,
+		Code:  ruleCode(rule),
+	}
+	if value.IsValid() && !value.IsZero() {
+		if sensitive {
+			e.Value = redacted
+		} else {
+			e.Value = value.Interface()
+		}
+	}
+	e.Message = ruleMessage(field, rule, param)
+	return e
+}
+
+// ruleError reports the failure err of rule r on the field at path. A
+// *ValidationError from the rule supplies its own Rule, Code and Message;
+// any other error becomes the message.
+func ruleError(path string, r rule, value reflect.Value, sensitive bool, err error) *ValidationError {
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		e := newValidationError(path, r.name, r.param, value, sensitive)
+		e.Message = path + " " + err.Error()
+		return e
+	}
+	name, param := verr.Rule, verr.Param
+	if name == "" {
+		name, param = r.name, r.param
+	}
+	e := newValidationError(path, name, param, value, sensitive)
+	if verr.Code != "" {
+		e.Code = verr.Code
+	}
+	if verr.Message != "" {
+		e.Message = verr.Message
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ruleCode returns the stable code for rule; custom rules get "invalid_<rule>"
+func ruleCode(rule string) string {
+	if code, ok := ruleCodes[rule]; ok {
+		return code
+	}
+	return "invalid_" + rule
+}
+
+// ruleMessage builds the human-readable explanation of a failure
+func ruleMessage(field, rule, param string) string {
+	switch rule {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", field)
+	case "number":
+		return fmt.Sprintf("%s must be a number", field)
+	case "url":
+		return fmt.Sprintf("%s must be a valid URL", field)
+	case "phone":
+		return fmt.Sprintf("%s must be a valid phone number", field)
+	case "date":
+		if param != "" {
+			return fmt.Sprintf("%s must be a date in the format %s", field, param)
+		}
+		return fmt.Sprintf("%s must be a valid date", field)
+	case "min":
+		return fmt.Sprintf("%s must be at least %s", field, param)
+	case "max":
+		return fmt.Sprintf("%s must be at most %s", field, param)
+	case "len":
+		return fmt.Sprintf("%s must have length %s", field, param)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of [%s]", field, param)
+	case "future":
+		return fmt.Sprintf("%s must be in the future", field)
+	case "notfuture":
+		return fmt.Sprintf("%s must not be in the future", field)
+	case "within":
+		return fmt.Sprintf("%s must be within %s days of today", field, param)
+	case "after":
+		return fmt.Sprintf("%s must be after %s", field, param)
+	case "before":
+		return fmt.Sprintf("%s must be before %s", field, param)
+	case "required_if", "required_unless":
+		pairs, _ := fieldConditions(param)
+		conds := make([]string, len(pairs))
+		for i, pair := range pairs {
+			conds[i] = pair[0] + " is " + pair[1]
+		}
+		when := " when "
+		if rule == "required_unless" {
+			when = " unless "
+		}
+		return field + " is required" + when + strings.Join(conds, " and ")
+	case "equal_field":
+		return fmt.Sprintf("%s must equal %s", field, param)
+	case "not_equal_field":
+		return fmt.Sprintf("%s must differ from %s", field, param)
+	}
+	if param != "" {
+		return fmt.Sprintf("%s failed %s=%s", field, rule, param)
+	}
+	return fmt.Sprintf("%s failed %s", field, rule)
+}
+
+// ValidationErrors collects every failing field of a validated struct
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Fields groups the errors by field name so a client can look up the
+// failures for one input directly
+func (e ValidationErrors) Fields() map[string][]*ValidationError {
+	fields := make(map[string][]*ValidationError)
+	for _, err := range e {
+		fields[err.Field] = append(fields[err.Field], err)
+	}
+	return fields
+}
+
+// Problem is an RFC 9457 problem document carrying the field-level errors
+type Problem struct {
+	Type   string             `json:"type"`
+	Title  string             `json:"title"`
+	Status int                `json:"status"`
+	Detail string             `json:"detail,omitempty"`
+	Errors []*ValidationError `json:"errors"`
+}
+
+// Problem converts the errors into a problem document for the given status
+func (e ValidationErrors) Problem(status int) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  "Validation failed",
+		Status: status,
+		Detail: fmt.Sprintf("%d field(s) failed validation", len(e)),
+		Errors: e,
+	}
+}
+
+// WriteProblem writes the errors to w as an application/problem+json response
+func (e ValidationErrors) WriteProblem(w http.ResponseWriter, status int) {
+	writeProblem(w, e.Problem(status))
+}
+
+func writeProblem(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+)
+
+// Precompiled regex patterns for better performance
+var (
+	numberPattern = regexp.MustCompile(`^[-+]?\d+(\.\d+)?$`)
+	datePattern   = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+// ValidateEmail checks if the email is a valid RFC 5321 address,
+// see ParseEmail
+func ValidateEmail(email string) bool {
+	_, err := ParseEmail(email)
+	return err == nil
+}
+
+// ValidateNumber checks if the input is a valid number (integer or float)
+func ValidateNumber(value string) bool {
+	return numberPattern.MatchString(value)
+}
+
+// ValidateDate checks if the input date is in the "YYYY-MM-DD" format and
+// names a real calendar day, so 2024-02-30 and 2023-13-45 are rejected
+func ValidateDate(date string) bool {
+	if !datePattern.MatchString(date) {
+		return false
+	}
+	_, err := ParseDate(date)
+	return err == nil
+}
+
+// ValidateURL checks if the input URL is valid and starts with http/https
+func ValidateURL(url string) bool {
+	_, err := URLPolicy{}.ParseURL(url)
+	return err == nil
+}
+
+// ValidatePhoneNumber checks the number against the embedded numbering
+// plans, treating numbers without a country code as US numbers
+func ValidatePhoneNumber(phone string) bool {
+	_, err := ParsePhone(phone, "US")
+	return err == nil
+}
+
+// UserQuery is the set of parameters accepted by the user endpoint
+type UserQuery struct {
+	UserID   string `json:"user_id" validate:"required,number"`
+	Email    string `json:"email" validate:"required,email"`
+	Age      int    `json:"age" validate:"min=1,max=120"`
+	Date     string `json:"date" validate:"required,date=2006-01-02"`
+	Website  string `json:"website" validate:"omitempty,url"`
+	Phone    string `json:"phone" validate:"omitempty,phone"`
+	Password string `json:"password" validate:"min=8,sensitive"`
+}
+
+// Booking shows the calendar rules: real dates, ordering and ranges
+type Booking struct {
+	CheckIn   string `json:"check_in" validate:"required,date,within=365"`
+	CheckOut  string `json:"check_out" validate:"required,date,after=check_in"`
+	BirthDate string `json:"birth_date" validate:"omitempty,date=iso8601,notfuture"`
+}
+
+// Signup shows cross-field, conditional and team-registered rules
+type Signup struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=8,not_equal_field=email,sensitive"`
+	Confirm  string `json:"confirm" validate:"required,equal_field=password,sensitive"`
+	Country  string `json:"country" validate:"required,oneof=US GB FR"`
+	Zip      string `json:"zip" validate:"required_if=Country US"`
+	Contact  string `json:"contact" validate:"omitempty,contact"`
+}
+
+// userSchema is the request body schema of the user endpoint, as it appears
+// in the API description
+const userSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["user_id", "email"],
+	"additionalProperties": false,
+	"properties": {
+		"user_id": {"type": "integer", "minimum": 1},
+		"email": {"type": "string", "format": "email"},
+		"date": {"type": "string", "format": "date"},
+		"tags": {"type": "array", "maxItems": 5, "items": {"type": "string", "pattern": "^[a-z]+$"}}
+	}
+}`
+
+// UserParams replaces the hand-written parsing in handleUser of b2.go
+type UserParams struct {
+	UserID int    `query:"user_id" validate:"required,min=1"`
+	Email  string `query:"email" validate:"required,email"`
+	Date   string `query:"date" validate:"required,date"`
+}
+
+// handleUser only runs once every parameter has been bound and validated
+var handleUser = Bind(func(w http.ResponseWriter, r *http.Request, p UserParams) {
+	fmt.Fprintf(w, "Hello, User ID: %d, Email: %s, Date: %s", p.UserID, p.Email, p.Date)
+})
+
+func main() {
+	// Sample inputs for validation
+	email := "user@example.com"
+	number := "12345"
+	date := "2024-12-31"
+	url := "https://www.example.com"
+	phone := "+1 (212) 456-7890"
+
+	// Validate email
+	if ValidateEmail(email) {
+		fmt.Println("Email is valid.")
+	} else {
+		fmt.Println("Email is invalid.")
+	}
+
+	// Validate number
+	if ValidateNumber(number) {
+		fmt.Println("Number is valid.")
+	} else {
+		fmt.Println("Number is invalid.")
+	}
+
+	// Validate date
+	if ValidateDate(date) {
+		fmt.Println("Date is valid.")
+	} else {
+		fmt.Println("Date is invalid.")
+	}
+
+	// Validate URL
+	if ValidateURL(url) {
+		fmt.Println("URL is valid.")
+	} else {
+		fmt.Println("URL is invalid.")
+	}
+
+	// Validate phone number
+	if ValidatePhoneNumber(phone) {
+		fmt.Println("Phone number is valid.")
+	} else {
+		fmt.Println("Phone number is invalid.")
+	}
+
+	// Validate a whole struct at once; every failing field is reported
+	validator := NewValidator()
+	query := UserQuery{
+		UserID:   "42",
+		Email:    "not-an-email",
+		Age:      130,
+		Date:     "2024-12-31",
+		Website:  "https://example.com",
+		Password: "hunter2",
+	}
+	if err := validator.Struct(query); err != nil {
+		fmt.Println("Query is invalid:", err)
+
+		// The same errors as a machine-readable problem document
+		var verrs ValidationErrors
+		if errors.As(err, &verrs) {
+			doc, _ := json.MarshalIndent(verrs.Problem(http.StatusUnprocessableEntity), "", "  ")
+			fmt.Println(string(doc))
+		}
+	} else {
+		fmt.Println("Query is valid.")
+	}
+
+	// Impossible and out-of-order dates are rejected
+	booking := Booking{CheckIn: "2024-02-30", CheckOut: "2024-02-28", BirthDate: "2999-W01-1"}
+	if err := validator.Struct(booking); err != nil {
+		fmt.Println("Booking is invalid:", err)
+	} else {
+		fmt.Println("Booking is valid.")
+	}
+
+	// Business rules are registered once and then used by name in tags
+	validator.Register("contact", Or(validator.MustCompile("email"), validator.MustCompile("phone=GB")))
+	signup := Signup{
+		Email:    "user@example.com",
+		Password: "user@example.com",
+		Confirm:  "something-else",
+		Country:  "US",
+		Contact:  "call me",
+	}
+	if err := validator.Struct(signup); err != nil {
+		fmt.Println("Signup is invalid:", err)
+	} else {
+		fmt.Println("Signup is valid.")
+	}
+
+	// The API description's JSON Schema checks payloads with the same rules
+	schema, err := validator.CompileSchema([]byte(userSchema))
+	if err != nil {
+		fmt.Println("Schema is invalid:", err)
+		return
+	}
+	payload := `{"user_id": 0, "email": "not-an-email", "date": "2024-02-30", "tags": ["ok", "Not OK"], "admin": true}`
+	if err := schema.ValidateJSON([]byte(payload)); err != nil {
+		fmt.Println("Payload is invalid:", err)
+	} else {
+		fmt.Println("Payload is valid.")
+	}
+
+	// Normalized forms suitable for storage
+	if canonical, err := NormalizeEmail("Pelé@Bücher.DE"); err == nil {
+		fmt.Println("Canonical email:", canonical)
+	}
+	if canonical, err := (URLPolicy{}).NormalizeURL("HTTP://LocalHost:8080/a/../b"); err == nil {
+		fmt.Println("Canonical URL:", canonical)
+	}
+
+	// Bound handlers work with any router; with gorilla/mux, set
+	// Binder.PathVars to mux.Vars
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /user", handleUser)
+	for _, target := range []string{
+		"/user?user_id=42&email=user@example.com&date=2024-12-31",
+		"/user?user_id=abc&email=user@example.com&date=2024-12-31",
+		"/user?user_id=42&email=bad&date=2024-02-30",
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		fmt.Printf("GET %s -> %d %s\n", target, rec.Code, strings.TrimSpace(rec.Body.String()))
+	}
+
+	// Phone numbers are parsed against per-country numbering plans
+	for _, input := range []struct{ number, region string }{
+		{"+1 (212) 456-7890", ""},
+		{"020 7946 0018", "GB"},
+		{"06 12 34 56 78", "FR"},
+		{"098765 43210", "IN"},
+	} {
+		p, err := ParsePhone(input.number, input.region)
+		if err != nil {
+			fmt.Println("Phone number is invalid:", err)
+			continue
+		}
+		fmt.Printf("%s -> %s, %s, %s (%s)\n", input.number, p.E164(), p.National(), p.International(), p.Type)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Punycode parameters from RFC 3492 section 5
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+	acePrefix       = "xn--"
+)
+
+const (
+	maxLabelLength  = 63
+	maxDomainLength = 253
+)
+
+// DomainToASCII converts a possibly internationalized domain name into its
+// lowercase ASCII (punycode) form and checks the label syntax: letters,
+// digits and hyphens, no leading or trailing hyphen, 1-63 octets per label
+// and 253 octets in total. Labels are lowercased but not NFC-normalized,
+// so callers should pass input in composed form.
+func DomainToASCII(domain string) (string, error) {
+	domain = strings.TrimSuffix(domain, ".")
+	if domain == "" {
+		return "", errors.New("empty domain")
+	}
+
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		ascii, err := labelToASCII(label)
+		if err != nil {
+			return "", fmt.Errorf("invalid domain %q: %w", domain, err)
+		}
+		labels[i] = ascii
+	}
+
+	ascii := strings.Join(labels, ".")
+	if len(ascii) > maxDomainLength {
+		return "", fmt.Errorf("invalid domain %q: longer than %d octets", domain, maxDomainLength)
+	}
+	return ascii, nil
+}
+
+// DomainToUnicode is the inverse of DomainToASCII, for display
+func DomainToUnicode(domain string) (string, error) {
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if !strings.HasPrefix(strings.ToLower(label), acePrefix) {
+			continue
+		}
+		decoded, err := punycodeDecode(label[len(acePrefix):])
+		if err != nil {
+			return "", fmt.Errorf("invalid label %q: %w", label, err)
+		}
+		labels[i] = decoded
+	}
+	return strings.Join(labels, "."), nil
+}
+
+func labelToASCII(label string) (string, error) {
+	if label == "" {
+		return "", errors.New("empty label")
+	}
+
+	label = strings.ToLower(label)
+	if !isASCII(label) {
+		for _, r := range label {
+			if unicode.IsSpace(r) || unicode.IsControl(r) || unicode.IsPunct(r) && r != '-' {
+				return "", fmt.Errorf("label %q contains %q", label, r)
+			}
+		}
+		encoded, err := punycodeEncode(label)
+		if err != nil {
+			return "", err
+		}
+		label = acePrefix + encoded
+	} else if strings.HasPrefix(label, acePrefix) {
+		// Existing A-labels must round-trip
+		if _, err := punycodeDecode(label[len(acePrefix):]); err != nil {
+			return "", fmt.Errorf("label %q: %w", label, err)
+		}
+	}
+
+	if len(label) > maxLabelLength {
+		return "", fmt.Errorf("label %q longer than %d octets", label, maxLabelLength)
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return "", fmt.Errorf("label %q starts or ends with a hyphen", label)
+	}
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return "", fmt.Errorf("label %q contains %q", label, c)
+		}
+	}
+	return label, nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func punyAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punyThreshold(k, bias int) int {
+	switch {
+	case k <= bias:
+		return punyTMin
+	case k >= bias+punyTMax:
+		return punyTMax
+	}
+	return k - bias
+}
+
+// punycodeEncode implements the encoding procedure of RFC 3492 section 6.3
+func punycodeEncode(s string) (string, error) {
+	runes := []rune(s)
+	var out strings.Builder
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			out.WriteByte(byte(r))
+		}
+	}
+	basic := out.Len()
+	handled := basic
+	if basic > 0 {
+		out.WriteByte('-')
+	}
+
+	n, delta, bias := punyInitialN, 0, punyInitialBias
+	for handled < len(runes) {
+		m := int(unicode.MaxRune) + 1
+		for _, r := range runes {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+		delta += (m - n) * (handled + 1)
+		if delta < 0 {
+			return "", errors.New("punycode overflow")
+		}
+		n = m
+		for _, r := range runes {
+			if int(r) < n {
+				delta++
+			}
+			if int(r) != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := punyThreshold(k, bias)
+				if q < t {
+					break
+				}
+				out.WriteByte(punyDigit(t + (q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out.WriteByte(punyDigit(q))
+			bias = punyAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+	return out.String(), nil
+}
+
+// punycodeDecode implements the decoding procedure of RFC 3492 section 6.2
+func punycodeDecode(s string) (string, error) {
+	var output []rune
+	pos := 0
+	if i := strings.LastIndexByte(s, '-'); i >= 0 {
+		for _, r := range s[:i] {
+			if r >= utf8.RuneSelf {
+				return "", errors.New("non-basic code point before delimiter")
+			}
+			output = append(output, r)
+		}
+		pos = i + 1
+	}
+
+	n, i, bias := punyInitialN, 0, punyInitialBias
+	for pos < len(s) {
+		oldi, w := i, 1
+		for k := punyBase; ; k += punyBase {
+			if pos >= len(s) {
+				return "", errors.New("truncated punycode")
+			}
+			c := s[pos]
+			pos++
+			var digit int
+			switch {
+			case c >= 'a' && c <= 'z':
+				digit = int(c - 'a')
+			case c >= 'A' && c <= 'Z':
+				digit = int(c - 'A')
+			case c >= '0' && c <= '9':
+				digit = int(c-'0') + 26
+			default:
+				return "", fmt.Errorf("invalid punycode digit %q", c)
+			}
+			i += digit * w
+			if i < 0 {
+				return "", errors.New("punycode overflow")
+			}
+			t := punyThreshold(k, bias)
+			if digit < t {
+				break
+			}
+			w *= punyBase - t
+		}
+		bias = punyAdapt(i-oldi, len(output)+1, oldi == 0)
+		n += i / (len(output) + 1)
+		if n > unicode.MaxRune {
+			return "", errors.New("punycode overflow")
+		}
+		i %= len(output) + 1
+		output = append(output[:i], append([]rune{rune(n)}, output[i:]...)...)
+		i++
+	}
+	return string(output), nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPunycode(t *testing.T) {
+	// The sample strings of RFC 3492 section 7.1. The uppercase letters of
+	// the encoded forms only annotate mixed case and are compared without
+	// regard to case.
+	tests := []struct {
+		name    string
+		unicode string
+		encoded string
+	}{
+		{"(A) Arabic (Egyptian)", "ليهمابتكلموشعربي؟", "egbpdaj6bu4bxfgehfvwxn"},
+		{"(B) Chinese (simplified)", "他们为什么不说中文", "ihqwcrb4cv8a8dqg056pqjye"},
+		{"(C) Chinese (traditional)", "他們爲什麽不說中文", "ihqwctvzc91f659drss3x8bo0yb"},
+		{"(D) Czech", "Pročprostěnemluvíčesky", "Proprostnemluvesky-uyb24dma41a"},
+		{"(E) Hebrew", "למההםפשוטלאמדבריםעברית", "4dbcagdahymbxekheh6e0a7fei0b"},
+		{"(F) Hindi (Devanagari)", "यहलोगहिन्दीक्योंनहींबोलसकतेहैं", "i1baa7eci9glrd9b2ae1bj0hfcgg6iyaf8o0a1dig0cd"},
+		{"(G) Japanese (kanji and hiragana)", "なぜみんな日本語を話してくれないのか", "n8jok5ay5dzabd5bym9f0cm5685rrjetr6pdxa"},
+		{"(H) Korean (Hangul syllables)", "세계의모든사람들이한국어를이해한다면얼마나좋을까", "989aomsvi5e83db1d2a355cv1e0vak1dwrv93d5xbh15a0dt30a5jpsd879ccm6fea98c"},
+		{"(I) Russian (Cyrillic)", "почемужеонинеговорятпорусски", "b1abfaaepdrnnbgefbaDotcwatmq2g4l"},
+		{"(J) Spanish", "PorquénopuedensimplementehablarenEspañol", "PorqunopuedensimplementehablarenEspaol-fmd56a"},
+		{"(K) Vietnamese", "TạisaohọkhôngthểchỉnóitiếngViệt", "TisaohkhngthchnitingVit-kjcr8268qyxafd2f1b9g"},
+		{"(L) 3<nen>B<gumi><kinpachi><sensei>", "3年B組金八先生", "3B-ww4c5e180e575a65lsy2b"},
+		{"(M) <amuro><namie>-with-SUPER-MONKEYS", "安室奈美恵-with-SUPER-MONKEYS", "-with-SUPER-MONKEYS-pc58ag80a8qai00g7n9n"},
+		{"(N) Hello-Another-Way-<sorezore><no><basho>", "Hello-Another-Way-それぞれの場所", "Hello-Another-Way--fc4qua05auwb3674vfr0b"},
+		{"(O) <hitotsu><yane><no><shita>2", "ひとつ屋根の下2", "2-u9tlzr9756bt3uc0v"},
+		{"(P) Maji<de>Koi<suru>5<byou><mae>", "MajiでKoiする5秒前", "MajiKoi5-783gue6qz075azm5e"},
+		{"(Q) <pafii>de<runba>", "パフィーdeルンバ", "de-jg4avhby1noc0d"},
+		{"(R) <sono><supiido><de>", "そのスピードで", "d9juau41awczczp"},
+		{"(S) -> $1.00 <-", "-> $1.00 <-", "-> $1.00 <--"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := punycodeEncode(tt.unicode)
+			if err != nil || !strings.EqualFold(encoded, tt.encoded) {
+				t.Errorf("Expected %s, got %s (%v)", tt.encoded, encoded, err)
+			}
+			decoded, err := punycodeDecode(tt.encoded)
+			if err != nil || !strings.EqualFold(decoded, tt.unicode) {
+				t.Errorf("Expected %s, got %s (%v)", tt.unicode, decoded, err)
+			}
+		})
+	}
+
+	for _, bad := range []string{"abc-ü", "a-9", "a-#"} {
+		if decoded, err := punycodeDecode(bad); err == nil {
+			t.Errorf("Expected %q to be rejected, got %q", bad, decoded)
+		}
+	}
+}
+
+func TestDomainToASCII(t *testing.T) {
+	tests := []struct {
+		domain   string
+		expected string // "" for an error
+	}{
+		{"Bücher.DE", "xn--bcher-kva.de"},
+		{"example.com.", "example.com"},
+		{"xn--bcher-kva.de", "xn--bcher-kva.de"},
+		{"münchen.例え.jp", "xn--mnchen-3ya.xn--r8jz45g.jp"},
+		{"", ""},
+		{"a..b", ""},
+		{"-a.com", ""},
+		{"a_b.com", ""},
+		{"xn--a-.com", ""},
+		{strings.Repeat("a", 64) + ".com", ""},
+		{strings.Repeat("a.", 127) + "com", ""},
+		{"ex ample.com", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			got, err := DomainToASCII(tt.domain)
+			if tt.expected == "" {
+				if err == nil {
+					t.Errorf("Expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil || got != tt.expected {
+				t.Errorf("Expected %s, got %s (%v)", tt.expected, got, err)
+			}
+			if back, err := DomainToUnicode(got); err != nil || strings.EqualFold(back, got) && !isASCII(tt.domain) {
+				t.Errorf("Expected %s to decode, got %s (%v)", got, back, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PhoneType classifies a number by the numbering plan range it falls in
+type PhoneType string
+
+const (
+	PhoneUnknown           PhoneType = "unknown"
+	PhoneTollFree          PhoneType = "toll_free"
+	PhonePremiumRate       PhoneType = "premium_rate"
+	PhoneMobile            PhoneType = "mobile"
+	PhoneFixedLine         PhoneType = "fixed_line"
+	PhoneFixedLineOrMobile PhoneType = "fixed_line_or_mobile" // NANP does not distinguish
+)
+
+// phoneTypeOrder is the order in which type patterns are tried, most
+// specific first, since fixed-line ranges often overlap the others
+var phoneTypeOrder = []PhoneType{
+	PhoneTollFree, PhonePremiumRate, PhoneMobile, PhoneFixedLine, PhoneFixedLineOrMobile,
+}
+
+// phoneData holds the numbering metadata for each region, keyed by ISO
+// 3166-1 alpha-2 code. It is compiled into the binary; no lookups leave
+// the process.
+//
+//go:embed phonedata.json
+var phoneData []byte
+
+type phoneRegion struct {
+	Region              string
+	CountryCode         int               `json:"country_code"`
+	MainRegion          bool              `json:"main_region"` // owns the ranges shared across the code
+	InternationalPrefix string            `json:"international_prefix"`
+	NationalPrefix      string            `json:"national_prefix"`
+	Types               map[string]string `json:"types"`
+	Formats             []phoneFormat     `json:"formats"`
+
+	types map[PhoneType]*regexp.Regexp
+}
+
+type phoneFormat struct {
+	Leading       string `json:"leading"`
+	Pattern       string `json:"pattern"`
+	National      string `json:"national"`
+	International string `json:"international"`
+
+	leading *regexp.Regexp
+	pattern *regexp.Regexp
+}
+
+var (
+	phoneRegions      map[string]*phoneRegion
+	phoneCountryCodes map[int][]*phoneRegion
+)
+
+func init() {
+	if err := json.Unmarshal(phoneData, &phoneRegions); err != nil {
+		panic("phone: invalid embedded metadata: " + err.Error())
+	}
+	phoneCountryCodes = make(map[int][]*phoneRegion)
+
+	regions := make([]string, 0, len(phoneRegions))
+	for region := range phoneRegions {
+		regions = append(regions, region)
+	}
+	// Regions sharing a country code are tried alphabetically with the main
+	// region last: its patterns are the broadest (US covers every NANP area
+	// code and the non-geographic toll-free and premium ranges), so the
+	// others must get the first chance to claim a number.
+	sort.Slice(regions, func(i, j int) bool {
+		a, b := phoneRegions[regions[i]], phoneRegions[regions[j]]
+		if a.MainRegion != b.MainRegion {
+			return b.MainRegion
+		}
+		return regions[i] < regions[j]
+	})
+
+	for _, region := range regions {
+		r := phoneRegions[region]
+		r.Region = region
+		r.types = make(map[PhoneType]*regexp.Regexp)
+		for typ, pattern := range r.Types {
+			r.types[PhoneType(typ)] = regexp.MustCompile(`^(?:` + pattern + `)$`)
+		}
+		for i := range r.Formats {
+			f := &r.Formats[i]
+			if f.Leading != "" {
+				f.leading = regexp.MustCompile(`^(?:` + f.Leading + `)`)
+			}
+			f.pattern = regexp.MustCompile(`^` + f.Pattern + `$`)
+		}
+		phoneCountryCodes[r.CountryCode] = append(phoneCountryCodes[r.CountryCode], r)
+	}
+}
+
+// PhoneNumber is a parsed, validated telephone number
+type PhoneNumber struct {
+	CountryCode    int
+	NationalNumber string // significant digits, without national prefix
+	Extension      string
+	Region         string
+	Type           PhoneType
+}
+
+// phoneExtension matches a trailing extension such as "x123" or "ext. 123"
+var phoneExtension = regexp.MustCompile(`(?i)\s*(?:ext\.?|x|#)\s*(\d{1,7})$`)
+
+// ParsePhone parses number in international form (+44 20 7946 0018 or, with
+// a default region, 00 44 20 7946 0018) or in the national form of
+// defaultRegion (020 7946 0018). Spaces (including no-break spaces), dots,
+// dashes, slashes and parentheses are ignored. The number must be valid for its region.
+func ParsePhone(number, defaultRegion string) (PhoneNumber, error) {
+	var p PhoneNumber
+	raw := strings.TrimSpace(number)
+	if m := phoneExtension.FindStringSubmatchIndex(raw); m != nil {
+		p.Extension = raw[m[2]:m[3]]
+		raw = raw[:m[0]]
+	}
+
+	international := strings.HasPrefix(raw, "+")
+	var digits strings.Builder
+	for i, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case strings.ContainsRune(" .-/()\u00a0", r), r == '+' && i == 0:
+		default:
+			return p, fmt.Errorf("invalid phone number %q: unexpected %q", number, r)
+		}
+	}
+	d := digits.String()
+
+	var candidates []*phoneRegion
+	if !international {
+		region, ok := phoneRegions[strings.ToUpper(defaultRegion)]
+		if !ok {
+			return p, fmt.Errorf("invalid phone number %q: no country code and unknown region %q", number, defaultRegion)
+		}
+		if rest, ok := strings.CutPrefix(d, region.InternationalPrefix); ok && region.InternationalPrefix != "" {
+			d, international = rest, true
+		} else {
+			candidates = phoneCountryCodes[region.CountryCode]
+			if region.NationalPrefix != "" && len(d) > 0 {
+				// The national prefix is optional when dialling within the region
+				if rest, ok := strings.CutPrefix(d, region.NationalPrefix); ok && validPhone(candidates, rest) {
+					d = rest
+				}
+			}
+			p.CountryCode = region.CountryCode
+		}
+	}
+
+	if international {
+		for n := 1; n <= 3 && n <= len(d); n++ {
+			cc, _ := strconv.Atoi(d[:n])
+			if regions, ok := phoneCountryCodes[cc]; ok {
+				p.CountryCode, candidates, d = cc, regions, d[n:]
+				break
+			}
+		}
+		if candidates == nil {
+			return p, fmt.Errorf("invalid phone number %q: unknown country code", number)
+		}
+	}
+
+	for _, region := range candidates {
+		if typ := region.validate(d); typ != PhoneUnknown {
+			p.NationalNumber, p.Region, p.Type = d, region.Region, typ
+			return p, nil
+		}
+	}
+	return p, fmt.Errorf("invalid phone number %q: not a valid number for +%d", number, p.CountryCode)
+}
+
+// validPhone reports whether nsn is valid in any of regions
+func validPhone(regions []*phoneRegion, nsn string) bool {
+	for _, region := range regions {
+		if region.validate(nsn) != PhoneUnknown {
+			return true
+		}
+	}
+	return false
+}
+
+// validate returns the type of the national significant number nsn, or
+// PhoneUnknown if it is not valid in the region
+func (r *phoneRegion) validate(nsn string) PhoneType {
+	for _, typ := range phoneTypeOrder {
+		if re, ok := r.types[typ]; ok && re.MatchString(nsn) {
+			return typ
+		}
+	}
+	return PhoneUnknown
+}
+
+// E164 formats the number as +<country code><national number>, the form
+// to store and to hand to SMS providers. Extensions are not part of E.164.
+func (p PhoneNumber) E164() string {
+	return "+" + strconv.Itoa(p.CountryCode) + p.NationalNumber
+}
+
+// National formats the number as dialled within its region,
+// e.g. "020 7946 0018" or "(201) 555-0123"
+func (p PhoneNumber) National() string {
+	region, f := p.format()
+	s := p.NationalNumber
+	if f != nil {
+		s = f.pattern.ReplaceAllString(p.NationalNumber, f.National)
+	} else if region != nil {
+		s = region.NationalPrefix + s
+	}
+	return s + p.extensionSuffix()
+}
+
+// International formats the number for dialling from abroad,
+// e.g. "+44 20 7946 0018" or "+1 201-555-0123"
+func (p PhoneNumber) International() string {
+	region, f := p.format()
+	s := p.NationalNumber
+	if f != nil {
+		if f.International != "" {
+			s = f.pattern.ReplaceAllString(p.NationalNumber, f.International)
+		} else {
+			s = f.pattern.ReplaceAllString(p.NationalNumber, f.National)
+			if region.NationalPrefix != "" {
+				s = strings.TrimPrefix(s, region.NationalPrefix)
+			}
+		}
+	}
+	return "+" + strconv.Itoa(p.CountryCode) + " " + s + p.extensionSuffix()
+}
+
+func (p PhoneNumber) String() string {
+	return p.E164()
+}
+
+func (p PhoneNumber) extensionSuffix() string {
+	if p.Extension == "" {
+		return ""
+	}
+	return " ext. " + p.Extension
+}
+
+// format finds the display format for the number in its region
+func (p PhoneNumber) format() (*phoneRegion, *phoneFormat) {
+	region, ok := phoneRegions[p.Region]
+	if !ok {
+		return nil, nil
+	}
+	for i := range region.Formats {
+		f := &region.Formats[i]
+		if (f.leading == nil || f.leading.MatchString(p.NationalNumber)) && f.pattern.MatchString(p.NationalNumber) {
+			return region, f
+		}
+	}
+	return region, nil
+}
+
+// NormalizePhone parses number and returns its E.164 form
+func NormalizePhone(number, defaultRegion string) (string, error) {
+	p, err := ParsePhone(number, defaultRegion)
+	if err != nil {
+		return "", err
+	}
+	return p.E164(), nil
+}
+
+// validatePhoneRule checks a string with ParsePhone. The param names the
+// default region for numbers without a country code and may restrict the
+// type: `validate:"phone"` (US), `validate:"phone=GB"`,
+// `validate:"phone=IN|mobile"`.
+func validatePhoneRule(value reflect.Value, param string) bool {
+	if value.Kind() != reflect.String {
+		return false
+	}
+	region, typ, _ := strings.Cut(param, "|")
+	if region == "" {
+		region = "US"
+	}
+	p, err := ParsePhone(value.String(), region)
+	if err != nil {
+		return false
+	}
+	return typ == "" || string(p.Type) == typ ||
+		p.Type == PhoneFixedLineOrMobile && (typ == string(PhoneMobile) || typ == string(PhoneFixedLine))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePhone(t *testing.T) {
+	tests := []struct {
+		number        string
+		region        string
+		e164          string
+		national      string
+		international string
+		typ           PhoneType
+		parsedRegion  string
+	}{
+		// NANP: US owns the shared ranges, other regions claim their area codes
+		{"(201) 555-0123", "US", "+12015550123", "(201) 555-0123", "+1 201-555-0123", PhoneFixedLineOrMobile, "US"},
+		{"1 201 555 0123", "US", "+12015550123", "(201) 555-0123", "+1 201-555-0123", PhoneFixedLineOrMobile, "US"},
+		{"+1 416 555 0199", "", "+14165550199", "(416) 555-0199", "+1 416-555-0199", PhoneFixedLineOrMobile, "CA"},
+		{"800-555-0199", "CA", "+18005550199", "(800) 555-0199", "+1 800-555-0199", PhoneTollFree, "US"},
+		{"+1 900 555 0199", "", "+19005550199", "(900) 555-0199", "+1 900-555-0199", PhonePremiumRate, "US"},
+		{"201.555.0123 ext. 42", "US", "+12015550123", "(201) 555-0123 ext. 42", "+1 201-555-0123 ext. 42", PhoneFixedLineOrMobile, "US"},
+		{"011 44 20 7946 0018", "US", "+442079460018", "020 7946 0018", "+44 20 7946 0018", PhoneFixedLine, "GB"},
+
+		{"020 7946 0018", "GB", "+442079460018", "020 7946 0018", "+44 20 7946 0018", PhoneFixedLine, "GB"},
+		{"+44 20 7946 0018", "", "+442079460018", "020 7946 0018", "+44 20 7946 0018", PhoneFixedLine, "GB"},
+		{"01632 960123", "GB", "+441632960123", "01632 960123", "+44 1632 960123", PhoneFixedLine, "GB"},
+		{"+44 7700 900123", "", "+447700900123", "07700 900123", "+44 7700 900123", PhoneMobile, "GB"},
+		{"0800 123 4567", "GB", "+448001234567", "0800 123 4567", "+44 800 123 4567", PhoneTollFree, "GB"},
+		{"0909 876 5432", "GB", "+449098765432", "0909 876 5432", "+44 909 876 5432", PhonePremiumRate, "GB"},
+		{"00 44 20 7946 0018", "FR", "+442079460018", "020 7946 0018", "+44 20 7946 0018", PhoneFixedLine, "GB"},
+
+		{"+353 1 234 5678", "", "+35312345678", "01 234 5678", "+353 1 234 5678", PhoneFixedLine, "IE"},
+		{"085 123 4567", "IE", "+353851234567", "085 123 4567", "+353 85 123 4567", PhoneMobile, "IE"},
+		{"1800 123 456", "IE", "+3531800123456", "1800 123 456", "+353 1800 123 456", PhoneTollFree, "IE"},
+
+		{"030 1234567", "DE", "+49301234567", "030 1234567", "+49 30 1234567", PhoneFixedLine, "DE"},
+		{"0151 23456789", "DE", "+4915123456789", "0151 23456789", "+49 151 23456789", PhoneMobile, "DE"},
+		{"0800 1234567", "DE", "+498001234567", "0800 1234567", "+49 800 1234567", PhoneTollFree, "DE"},
+		{"0900 1234567", "DE", "+499001234567", "0900 1234567", "+49 900 1234567", PhonePremiumRate, "DE"},
+
+		{"06 12 34 56 78", "FR", "+33612345678", "06 12 34 56 78", "+33 6 12 34 56 78", PhoneMobile, "FR"},
+		{"01 23 45 67 89", "FR", "+33123456789", "01 23 45 67 89", "+33 1 23 45 67 89", PhoneFixedLine, "FR"},
+		{"0800 12 34 56", "FR", "+33800123456", "0 800 12 34 56", "+33 800 12 34 56", PhoneTollFree, "FR"},
+		{"0891 12 34 56", "FR", "+33891123456", "0 891 12 34 56", "+33 891 12 34 56", PhonePremiumRate, "FR"},
+
+		{"612 345 678", "ES", "+34612345678", "612 34 56 78", "+34 612 34 56 78", PhoneMobile, "ES"},
+		{"912 345 678", "ES", "+34912345678", "912 34 56 78", "+34 912 34 56 78", PhoneFixedLine, "ES"},
+		{"+34 800 123 456", "", "+34800123456", "800 12 34 56", "+34 800 12 34 56", PhoneTollFree, "ES"},
+
+		// Italian fixed-line numbers keep their leading 0 internationally
+		{"312 345 6789", "IT", "+393123456789", "312 345 6789", "+39 312 345 6789", PhoneMobile, "IT"},
+		{"06 1234 5678", "IT", "+390612345678", "06 1234 5678", "+39 06 1234 5678", PhoneFixedLine, "IT"},
+		{"800 123456", "IT", "+39800123456", "800 123456", "+39 800 123456", PhoneTollFree, "IT"},
+
+		{"06 12345678", "NL", "+31612345678", "06 12345678", "+31 6 12345678", PhoneMobile, "NL"},
+		{"020 123 4567", "NL", "+31201234567", "020 123 4567", "+31 20 123 4567", PhoneFixedLine, "NL"},
+		{"0800 1234", "NL", "+318001234", "0800 1234", "+31 800 1234", PhoneTollFree, "NL"},
+
+		{"098765 43210", "IN", "+919876543210", "098765 43210", "+91 98765 43210", PhoneMobile, "IN"},
+		{"011 2345 6789", "IN", "+911123456789", "011 2345 6789", "+91 11 2345 6789", PhoneFixedLine, "IN"},
+		{"1800 123 4567", "in", "+9118001234567", "1800 123 4567", "+91 1800 123 4567", PhoneTollFree, "IN"},
+
+		{"0412 345 678", "AU", "+61412345678", "0412 345 678", "+61 412 345 678", PhoneMobile, "AU"},
+		{"02 9876 5432", "AU", "+61298765432", "02 9876 5432", "+61 2 9876 5432", PhoneFixedLine, "AU"},
+		{"1800 123 456", "AU", "+611800123456", "1800 123 456", "+61 1800 123 456", PhoneTollFree, "AU"},
+		{"0011 44 20 7946 0018", "AU", "+442079460018", "020 7946 0018", "+44 20 7946 0018", PhoneFixedLine, "GB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.region+" "+tt.number, func(t *testing.T) {
+			p, err := ParsePhone(tt.number, tt.region)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.E164() != tt.e164 || p.National() != tt.national || p.International() != tt.international {
+				t.Errorf("Expected %s | %s | %s, got %s | %s | %s", tt.e164, tt.national, tt.international, p.E164(), p.National(), p.International())
+			}
+			if p.Type != tt.typ || p.Region != tt.parsedRegion {
+				t.Errorf("Expected %s in %s, got %s in %s", tt.typ, tt.parsedRegion, p.Type, p.Region)
+			}
+		})
+	}
+}
+
+func TestParsePhoneErrors(t *testing.T) {
+	tests := []struct {
+		number   string
+		region   string
+		expected string
+	}{
+		{"+1 201 555 012", "", "not a valid number for +1"},
+		{"+1 201 555 01234", "", "not a valid number for +1"},
+		{"(201) 155-0123", "US", "not a valid number for +1"},
+		{"020 7946 001", "GB", "not a valid number for +44"},
+		{"07600 900123", "GB", "not a valid number for +44"},
+		{"06 12 34 56", "FR", "not a valid number for +33"},
+		{"06 12 34 56 78 9", "FR", "not a valid number for +33"},
+		{"+34 512 345 678", "", "not a valid number for +34"},
+		{"+91 12345", "", "not a valid number for +91"},
+		{"0412 345 67", "AU", "not a valid number for +61"},
+		{"+999 123", "", "unknown country code"},
+		{"12345", "ZZ", `unknown region "ZZ"`},
+		{"020 7946 0018", "", `unknown region ""`},
+		{"+1 (201) abc", "", "unexpected 'a'"},
+		{"201+555+0123", "US", "unexpected '+'"},
+		{"", "US", "not a valid number for +1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.region+" "+tt.number, func(t *testing.T) {
+			p, err := ParsePhone(tt.number, tt.region)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v (%s)", tt.expected, err, p)
+			}
+		})
+	}
+}
+
+func TestPhoneRule(t *testing.T) {
+	tests := []struct {
+		tag   string
+		value any
+		valid bool
+	}{
+		{"phone", "(201) 555-0123", true},
+		{"phone", "020 7946 0018", false},
+		{"phone=GB", "020 7946 0018", true},
+		{"phone=GB|mobile", "020 7946 0018", false},
+		{"phone=GB|mobile", "07700 900123", true},
+		{"phone=US|mobile", "(201) 555-0123", true},
+		{"phone=US|fixed_line", "(201) 555-0123", true},
+		{"phone=US|toll_free", "(201) 555-0123", false},
+		{"phone", 2015550123, false},
+	}
+	v := NewValidator()
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			err := v.Var("phone", tt.value, tt.tag)
+			if (err == nil) != tt.valid {
+				t.Errorf("Expected valid = %v for %v, got %v", tt.valid, tt.value, err)
+			}
+		})
+	}
+
+	if e164, err := NormalizePhone("06 12 34 56 78", "FR"); err != nil || e164 != "+33612345678" {
+		t.Errorf("Expected +33612345678, got %s (%v)", e164, err)
+	}
+}
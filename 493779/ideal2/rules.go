@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Rule is a named business rule, e.g. "password must differ from email".
+// It returns nil if value passes. parent is the struct holding the field,
+// so rules can look at siblings; it is the zero Value when validating with
+// Var. Return a *ValidationError to choose the Code and Message reported
+// for the field; any other error is reported after the field name, so
+// errors.New("must differ from email") reads "password must differ from
+// email".
+type Rule func(ctx context.Context, value, parent reflect.Value) error
+
+// RuleFactory builds a Rule from the param of a tag, e.g. "Country US" for
+// `validate:"required_if=Country US"`. An error rejects the tag when its
+// struct is first validated, just like an unknown rule name.
+type RuleFactory func(param string) (Rule, error)
+
+// errRuleFailed is returned by rules that have nothing to add to the
+// generic message for their name
+var errRuleFailed = errors.New("rule failed")
+
+// zeroValueRules also check the zero values of omitempty fields: the
+// conditional ones decide whether the field may be empty at all, and the
+// cross-field ones compare it with a sibling that may not be
+var zeroValueRules = map[string]bool{
+	"required_if":     true,
+	"required_unless": true,
+	"equal_field":     true,
+	"not_equal_field": true,
+}
+
+// Register adds or replaces a parameterless rule. Team-specific rules are
+// registered once at startup and then used in tags like built-ins:
+//
+//	v.Register("strong_password", checkPasswordStrength)
+//	type Signup struct {
+//		Password string `validate:"required,strong_password,not_equal_field=Email"`
+//	}
+func (v *Validator) Register(name string, rule Rule) {
+	v.RegisterFactory(name, func(string) (Rule, error) { return rule, nil })
+}
+
+// RegisterFactory adds or replaces a rule that takes a param. It is safe
+// to call while the Validator is in use; tags already parsed keep the rule
+// they were parsed with until the cache is cleared here.
+func (v *Validator) RegisterFactory(name string, factory RuleFactory) {
+	v.mu.Lock()
+	v.rules[name] = factory
+	v.mu.Unlock()
+	v.cache.Range(func(key, _ any) bool {
+		v.cache.Delete(key)
+		return true
+	})
+}
+
+// ruleFactory returns the rule registered under name
+func (v *Validator) ruleFactory(name string) (RuleFactory, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	factory, ok := v.rules[name]
+	return factory, ok
+}
+
+// Compile turns a tag such as "required,email" into a single Rule, so that
+// registered and built-in rules can be combined with And, Or and Not:
+//
+//	contact := Or(v.MustCompile("email"), v.MustCompile("phone"))
+//	v.Register("contact", contact)
+func (v *Validator) Compile(tag string) (Rule, error) {
+	f, err := v.parseTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, value, parent reflect.Value) error {
+		rules, missing := f.active(value)
+		if missing {
+			return &ValidationError{Rule: "required", Code: ruleCode("required")}
+		}
+		for _, r := range rules {
+			if err := r.check(ctx, value, parent); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// MustCompile is like Compile but panics if the tag is invalid. It is meant
+// for rules built once at startup.
+func (v *Validator) MustCompile(tag string) Rule {
+	rule, err := v.Compile(tag)
+	if err != nil {
+		panic(err)
+	}
+	return rule
+}
+
+// And passes if every rule passes and reports the first failure
+func And(rules ...Rule) Rule {
+	return func(ctx context.Context, value, parent reflect.Value) error {
+		for _, rule := range rules {
+			if err := rule(ctx, value, parent); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Or passes if any rule passes. If none does, the failures are reported
+// together, e.g. "must be a valid email address or must be a valid phone
+// number".
+func Or(rules ...Rule) Rule {
+	return func(ctx context.Context, value, parent reflect.Value) error {
+		var msgs []string
+		for _, rule := range rules {
+			err := rule(ctx, value, parent)
+			if err == nil {
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			msgs = append(msgs, ruleErrorText(err))
+		}
+		return errors.New(strings.Join(msgs, " or "))
+	}
+}
+
+// Not passes if rule fails. The failure is reported under the name the
+// composed rule is registered with.
+func Not(rule Rule) Rule {
+	return func(ctx context.Context, value, parent reflect.Value) error {
+		if err := rule(ctx, value, parent); err == nil {
+			return errRuleFailed
+		}
+		return ctx.Err()
+	}
+}
+
+// ruleErrorText returns the part of a rule failure that reads well after a
+// field name
+func ruleErrorText(err error) string {
+	var verr *ValidationError
+	switch {
+	case !errors.As(err, &verr):
+		return err.Error()
+	case verr.Message != "":
+		return verr.Message
+	}
+	return strings.TrimPrefix(ruleMessage("", verr.Rule, verr.Param), " ")
+}
+
+// check runs the rule, tagging anonymous failures with its name and param
+// so they are reported like the built-in rules
+func (r rule) check(ctx context.Context, value, parent reflect.Value) error {
+	err := r.fn(ctx, value, parent)
+	if errors.Is(err, errRuleFailed) {
+		return &ValidationError{Rule: r.name, Param: r.param, Code: ruleCode(r.name)}
+	}
+	return err
+}
+
+// boolRule adapts the predicate-style FieldRuleFunc into a RuleFactory
+func boolRule(fn FieldRuleFunc) RuleFactory {
+	return func(param string) (Rule, error) {
+		return func(_ context.Context, value, parent reflect.Value) error {
+			if !fn(value, parent, param) {
+				return errRuleFailed
+			}
+			return nil
+		}, nil
+	}
+}
+
+// fieldConditions parses "Country US State CA" into field/value pairs
+func fieldConditions(param string) ([][2]string, error) {
+	words := strings.Fields(param)
+	if len(words) == 0 || len(words)%2 != 0 {
+		return nil, fmt.Errorf("validate: expected field/value pairs, got %q", param)
+	}
+	pairs := make([][2]string, 0, len(words)/2)
+	for i := 0; i < len(words); i += 2 {
+		pairs = append(pairs, [2]string{words[i], words[i+1]})
+	}
+	return pairs, nil
+}
+
+// conditionsHold reports whether every sibling named in pairs has the
+// given value
+func conditionsHold(parent reflect.Value, pairs [][2]string) bool {
+	for _, pair := range pairs {
+		field, ok := lookupField(parent, pair[0])
+		if !ok {
+			return false
+		}
+		for field.Kind() == reflect.Pointer && !field.IsNil() {
+			field = field.Elem()
+		}
+		if field.Kind() == reflect.Pointer || fmt.Sprint(field.Interface()) != pair[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// requiredIfRule requires the field when all the named siblings have the
+// given values, e.g. `validate:"required_if=Country US"`
+func requiredIfRule(param string) (Rule, error) {
+	return requiredWhen(param, true)
+}
+
+// requiredUnlessRule requires the field unless all the named siblings have
+// the given values, e.g. `validate:"required_unless=Plan free"`
+func requiredUnlessRule(param string) (Rule, error) {
+	return requiredWhen(param, false)
+}
+
+func requiredWhen(param string, hold bool) (Rule, error) {
+	pairs, err := fieldConditions(param)
+	if err != nil {
+		return nil, err
+	}
+	return func(_ context.Context, value, parent reflect.Value) error {
+		if (!value.IsValid() || value.IsZero()) && conditionsHold(parent, pairs) == hold {
+			return errRuleFailed
+		}
+		return nil
+	}, nil
+}
+
+// equalFieldRule requires the field to equal the named sibling, e.g.
+// `validate:"equal_field=Password"` on a confirmation field
+func equalFieldRule(param string) (Rule, error) {
+	return compareField(param, true)
+}
+
+// notEqualFieldRule requires the field to differ from the named sibling,
+// e.g. `validate:"not_equal_field=Email"` on a password
+func notEqualFieldRule(param string) (Rule, error) {
+	return compareField(param, false)
+}
+
+func compareField(param string, equal bool) (Rule, error) {
+	if param == "" {
+		return nil, errors.New("validate: missing field name")
+	}
+	return func(_ context.Context, value, parent reflect.Value) error {
+		other, ok := lookupField(parent, param)
+		if !ok {
+			return fmt.Errorf("refers to unknown field %s", param)
+		}
+		same := value.Type() == other.Type() && reflect.DeepEqual(value.Interface(), other.Interface())
+		if same != equal {
+			return errRuleFailed
+		}
+		return nil
+	}, nil
+}
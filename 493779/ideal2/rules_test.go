@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestComposedRules(t *testing.T) {
+	v := NewValidator()
+	v.Register("contact", Or(v.MustCompile("email"), v.MustCompile("phone=GB")))
+	v.Register("short_email", And(v.MustCompile("email"), v.MustCompile("max=12")))
+	v.Register("not_admin", Not(v.MustCompile("oneof=admin root")))
+	tests := []struct {
+		tag      string
+		value    string
+		rule     string // reported; And reports the rule that failed
+		expected string // the message, or "" if the value passes
+	}{
+		{"contact", "a@example.com", "", ""},
+		{"contact", "020 7946 0018", "", ""},
+		{"contact", "call me", "contact", "x must be a valid email address or must be a valid phone number"},
+		{"short_email", "a@example.com", "max", "x must be at most 12"},
+		{"short_email", "a@b.fr", "", ""},
+		{"short_email", "ab", "email", "x must be a valid email address"},
+		{"not_admin", "root", "not_admin", "x failed not_admin"},
+		{"not_admin", "ann", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag+" "+tt.value, func(t *testing.T) {
+			err := v.Var("x", tt.value, tt.tag)
+			var verrs ValidationErrors
+			switch {
+			case tt.expected == "" && err != nil:
+				t.Errorf("Expected %q to pass, got %v", tt.value, err)
+			case tt.expected == "":
+			case !errors.As(err, &verrs) || verrs[0].Message != tt.expected || verrs[0].Rule != tt.rule:
+				t.Errorf("Expected %s: %q, got %v", tt.rule, tt.expected, err)
+			}
+		})
+	}
+
+	if _, err := v.Compile("email,nope"); err == nil {
+		t.Errorf("Expected an unknown rule to fail to compile")
+	}
+	compiled := v.MustCompile("required,email")
+	if err := compiled(context.Background(), reflect.ValueOf(""), reflect.Value{}); ruleErrorText(err) != "is required" {
+		t.Errorf("Expected a compiled required rule to fail on \"\", got %v", err)
+	}
+}
+
+func TestRegisteredRule(t *testing.T) {
+	v := NewValidator()
+	v.Register("strong_password", func(_ context.Context, value, _ reflect.Value) error {
+		if strings.ContainsAny(value.String(), "0123456789") {
+			return nil
+		}
+		return &ValidationError{Code: "weak_password", Message: "password needs a digit"}
+	})
+	v.Register("not_taken", func(_ context.Context, value, _ reflect.Value) error {
+		if value.String() == "ann" {
+			return errors.New("is taken")
+		}
+		return nil
+	})
+	type Account struct {
+		User     string `json:"user" validate:"not_taken"`
+		Password string `json:"password" validate:"strong_password"`
+	}
+	err := v.Struct(Account{User: "ann", Password: "secret"})
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) || len(verrs) != 2 {
+		t.Fatalf("Expected two failures, got %v", err)
+	}
+	if e := verrs[0]; e.Rule != "not_taken" || e.Code != "invalid_not_taken" || e.Message != "user is taken" {
+		t.Errorf("Expected the plain error after the field name, got %+v", e)
+	}
+	if e := verrs[1]; e.Rule != "strong_password" || e.Code != "weak_password" || e.Message != "password needs a digit" {
+		t.Errorf("Expected the rule's own code and message, got %+v", e)
+	}
+}
+
+func TestRegisterWhileValidating(t *testing.T) {
+	// Run with -race: registering is safe while other goroutines validate
+	v := NewValidator()
+	type Account struct {
+		User string `validate:"required,email"`
+	}
+	pass := func(context.Context, reflect.Value, reflect.Value) error { return nil }
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := range 50 {
+				v.Register(fmt.Sprintf("rule_%d_%d", i, j), pass)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				if err := v.Struct(Account{User: "a@example.com"}); err != nil {
+					t.Errorf("Expected a valid account, got %v", err)
+				}
+				v.Var("x", "a", "rule_0_0") // may not be registered yet
+			}
+		}()
+	}
+	wg.Wait()
+	if err := v.Var("x", "a", "rule_3_49"); err != nil {
+		t.Errorf("Expected every rule to be registered, got %v", err)
+	}
+}
+
+func TestConditionalRules(t *testing.T) {
+	type Address struct {
+		Country string `json:"country"`
+		Plan    string `json:"plan"`
+		Zip     string `json:"zip" validate:"required_if=Country US"`
+		State   string `json:"state" validate:"omitempty,required_if=country US plan pro,len=2"`
+		Card    string `json:"card" validate:"required_unless=Plan free"`
+	}
+	tests := []struct {
+		name     string
+		address  Address
+		expected string
+	}{
+		{"US with zip", Address{Country: "US", Plan: "free", Zip: "10001"}, ""},
+		{"US without zip", Address{Country: "US", Plan: "free"}, "zip:required_if"},
+		{"Elsewhere without zip", Address{Country: "FR", Plan: "free"}, ""},
+		{"Both conditions", Address{Country: "US", Plan: "pro", Zip: "10001", Card: "4111"}, "state:required_if"},
+		{"Set values are still checked", Address{Country: "FR", Plan: "free", State: "Texas"}, "state:len"},
+		{"Paid plan without card", Address{Country: "FR", Plan: "pro"}, "card:required_unless"},
+	}
+	v := NewValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(failures(v.Struct(tt.address)), " ")
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	err := v.Struct(Address{Country: "US", Plan: "free"})
+	if msg := err.Error(); msg != "zip: zip is required when Country is US" {
+		t.Errorf("Expected the condition in the message, got %q", msg)
+	}
+}
+
+func TestCrossFieldRules(t *testing.T) {
+	type Signup struct {
+		Email    string `json:"email"`
+		Password string `json:"password" validate:"omitempty,not_equal_field=email"`
+		Confirm  string `json:"confirm" validate:"omitempty,equal_field=password"`
+		Backup   string `json:"backup" validate:"not_equal_field=Email"`
+	}
+	tests := []struct {
+		name     string
+		signup   Signup
+		expected string
+	}{
+		{"Valid", Signup{Email: "a@example.com", Password: "pw", Confirm: "pw", Backup: "b@example.com"}, ""},
+		{"Confirmation differs", Signup{Email: "a@example.com", Password: "pw", Confirm: "px", Backup: "b@example.com"}, "confirm:equal_field"},
+		{"Confirmation empty", Signup{Email: "a@example.com", Password: "pw", Backup: "b@example.com"}, "confirm:equal_field"},
+		{"Password is the email", Signup{Email: "a@example.com", Password: "a@example.com", Confirm: "a@example.com", Backup: "b@example.com"}, "password:not_equal_field"},
+		{"Both empty", Signup{Backup: "b@example.com"}, "password:not_equal_field"},
+		{"Backup is the email", Signup{Email: "a@example.com", Password: "pw", Confirm: "pw", Backup: "a@example.com"}, "backup:not_equal_field"},
+	}
+	v := NewValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(failures(v.Struct(tt.signup)), " ")
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	type Broken struct {
+		A string `json:"a" validate:"equal_field=Missing"`
+	}
+	err := v.Struct(Broken{A: "x"})
+	if err == nil || !strings.Contains(err.Error(), "a refers to unknown field Missing") {
+		t.Errorf("Expected an unknown sibling to be reported, got %v", err)
+	}
+	if err := v.Struct(struct {
+		A string `validate:"equal_field"`
+	}{}); err == nil || !strings.Contains(err.Error(), "missing field name") {
+		t.Errorf("Expected a missing field name to reject the tag, got %v", err)
+	}
+}
@@ -0,0 +1,606 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema is a JSON Schema compiled against a Validator, so that "format"
+// is checked by the same rules as `validate` tags. The draft 2020-12
+// keywords supported are:
+//
+//	type, enum, const, format, pattern, minLength, maxLength,
+//	minimum, maximum, exclusiveMinimum, exclusiveMaximum,
+//	properties, required, additionalProperties,
+//	items, minItems, maxItems, oneOf, $ref (within the document), $defs
+//
+// Other keywords are ignored, as the specification does for unknown ones.
+// Failures are reported with JSON Pointer (RFC 6901) paths as the field,
+// e.g. "/addresses/0/zip".
+type Schema struct {
+	root *schemaNode
+}
+
+// schemaFormats maps JSON Schema formats to the validator rule checking
+// them. Formats not listed are looked up as rule names, so a registered
+// rule is also a format; unknown formats are only annotations and pass.
+var schemaFormats = map[string]string{
+	"email":     "email",
+	"idn-email": "email",
+	"uri":       "url",
+	"iri":       "url",
+	"date":      "date",
+	"date-time": "date=rfc3339",
+}
+
+// schemaCodes maps keywords to the codes of the equivalent tag rules, so
+// clients see the same codes whichever way a payload was validated
+var schemaCodes = map[string]string{
+	"type":                 "invalid_type",
+	"enum":                 "not_allowed",
+	"const":                "not_allowed",
+	"pattern":              "invalid_pattern",
+	"minLength":            "too_small",
+	"maxLength":            "too_large",
+	"minimum":              "too_small",
+	"maximum":              "too_large",
+	"exclusiveMinimum":     "too_small",
+	"exclusiveMaximum":     "too_large",
+	"required":             "field_required",
+	"additionalProperties": "unknown_field",
+	"minItems":             "too_small",
+	"maxItems":             "too_large",
+	"oneOf":                "no_single_match",
+	"false":                "not_allowed",
+}
+
+type schemaNode struct {
+	never bool // the schema false
+
+	types      []string
+	enum       []any
+	constant   *any
+	format     string
+	formatRule Rule
+	pattern    *regexp.Regexp
+
+	minLength, maxLength *float64
+	minimum, maximum     *float64
+	exclusiveMinimum     *float64
+	exclusiveMaximum     *float64
+	minItems, maxItems   *float64
+
+	properties map[string]*schemaNode
+	required   []string
+	additional *schemaNode
+	items      *schemaNode
+	oneOf      []*schemaNode
+	ref        *schemaNode
+}
+
+// LoadSchema reads and compiles the JSON Schema in the named file
+func (v *Validator) LoadSchema(name string) (*Schema, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return v.CompileSchema(data)
+}
+
+// CompileSchema compiles a JSON Schema document. Errors in the schema, such
+// as a pattern that is not a valid regular expression or a dangling $ref,
+// are reported here rather than when validating.
+func (v *Validator) CompileSchema(data []byte) (*Schema, error) {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	c := &schemaCompiler{v: v, doc: doc, nodes: make(map[string]*schemaNode)}
+	root, err := c.compile(doc, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkLoops(); err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+type schemaCompiler struct {
+	v     *Validator
+	doc   any
+	nodes map[string]*schemaNode // by JSON Pointer into doc
+}
+
+func (c *schemaCompiler) compile(raw any, loc string) (*schemaNode, error) {
+	if n, ok := c.nodes[loc]; ok {
+		return n, nil
+	}
+	n := &schemaNode{}
+	c.nodes[loc] = n // before filling in, so recursive $refs terminate
+
+	switch s := raw.(type) {
+	case bool:
+		n.never = !s
+		return n, nil
+	case map[string]any:
+		if err := c.fill(n, s, loc); err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("schema: %s: expected object or boolean", locationOf(loc))
+}
+
+// checkLoops rejects a $ref that leads back to itself through $ref and
+// oneOf alone, which would recurse forever on any instance. Recursion
+// through properties, additionalProperties or items is fine, as each step
+// goes one level down into the instance.
+func (c *schemaCompiler) checkLoops() error {
+	locs := make([]string, 0, len(c.nodes))
+	byNode := make(map[*schemaNode]string, len(c.nodes))
+	for loc, n := range c.nodes {
+		locs = append(locs, loc)
+		byNode[n] = loc
+	}
+	sort.Strings(locs)
+
+	const visiting, done = 1, 2
+	state := make(map[*schemaNode]int)
+	var visit func(n *schemaNode) error
+	visit = func(n *schemaNode) error {
+		switch state[n] {
+		case visiting:
+			return fmt.Errorf("schema: %s: $ref loops back to itself without descending into the instance", locationOf(byNode[n]))
+		case done:
+			return nil
+		}
+		state[n] = visiting
+		for _, next := range append([]*schemaNode{n.ref}, n.oneOf...) {
+			if next == nil {
+				continue
+			}
+			if err := visit(next); err != nil {
+				return err
+			}
+		}
+		state[n] = done
+		return nil
+	}
+	for _, loc := range locs {
+		if err := visit(c.nodes[loc]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *schemaCompiler) fill(n *schemaNode, s map[string]any, loc string) error {
+	bad := func(keyword, want string) error {
+		return fmt.Errorf("schema: %s/%s: expected %s", locationOf(loc), keyword, want)
+	}
+
+	switch t := s["type"].(type) {
+	case nil:
+	case string:
+		n.types = []string{t}
+	case []any:
+		for _, item := range t {
+			name, ok := item.(string)
+			if !ok {
+				return bad("type", "string or array of strings")
+			}
+			n.types = append(n.types, name)
+		}
+	default:
+		return bad("type", "string or array of strings")
+	}
+
+	if enum, ok := s["enum"]; ok {
+		if n.enum, ok = enum.([]any); !ok {
+			return bad("enum", "array")
+		}
+	}
+	if constant, ok := s["const"]; ok {
+		n.constant = &constant
+	}
+
+	if format, ok := s["format"].(string); ok {
+		n.format = format
+		rule := format
+		if mapped, ok := schemaFormats[format]; ok {
+			rule = mapped
+		}
+		name, _, _ := strings.Cut(rule, "=")
+		if _, ok := c.v.ruleFactory(name); ok {
+			fn, err := c.v.Compile(rule)
+			if err != nil {
+				return fmt.Errorf("schema: %s/format: %w", locationOf(loc), err)
+			}
+			n.formatRule = fn
+		}
+	}
+	if pattern, ok := s["pattern"]; ok {
+		expr, ok := pattern.(string)
+		if !ok {
+			return bad("pattern", "string")
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("schema: %s/pattern: %w", locationOf(loc), err)
+		}
+		n.pattern = re
+	}
+
+	for keyword, dst := range map[string]**float64{
+		"minLength": &n.minLength, "maxLength": &n.maxLength,
+		"minimum": &n.minimum, "maximum": &n.maximum,
+		"exclusiveMinimum": &n.exclusiveMinimum, "exclusiveMaximum": &n.exclusiveMaximum,
+		"minItems": &n.minItems, "maxItems": &n.maxItems,
+	} {
+		if raw, ok := s[keyword]; ok {
+			f, ok := raw.(float64)
+			if !ok {
+				return bad(keyword, "number")
+			}
+			*dst = &f
+		}
+	}
+
+	if raw, ok := s["properties"]; ok {
+		props, ok := raw.(map[string]any)
+		if !ok {
+			return bad("properties", "object")
+		}
+		n.properties = make(map[string]*schemaNode, len(props))
+		for name, sub := range props {
+			p, err := c.compile(sub, loc+"/properties/"+escapePointer(name))
+			if err != nil {
+				return err
+			}
+			n.properties[name] = p
+		}
+	}
+	if raw, ok := s["required"]; ok {
+		names, ok := raw.([]any)
+		if !ok {
+			return bad("required", "array of strings")
+		}
+		for _, name := range names {
+			s, ok := name.(string)
+			if !ok {
+				return bad("required", "array of strings")
+			}
+			n.required = append(n.required, s)
+		}
+	}
+
+	var err error
+	if raw, ok := s["additionalProperties"]; ok {
+		if n.additional, err = c.compile(raw, loc+"/additionalProperties"); err != nil {
+			return err
+		}
+	}
+	if raw, ok := s["items"]; ok {
+		if n.items, err = c.compile(raw, loc+"/items"); err != nil {
+			return err
+		}
+	}
+	if raw, ok := s["oneOf"]; ok {
+		subs, ok := raw.([]any)
+		if !ok || len(subs) == 0 {
+			return bad("oneOf", "non-empty array")
+		}
+		for i, sub := range subs {
+			node, err := c.compile(sub, loc+"/oneOf/"+strconv.Itoa(i))
+			if err != nil {
+				return err
+			}
+			n.oneOf = append(n.oneOf, node)
+		}
+	}
+
+	if raw, ok := s["$ref"]; ok {
+		ref, ok := raw.(string)
+		if !ok || !strings.HasPrefix(ref, "#") {
+			return bad("$ref", "reference within the document, such as #/$defs/name")
+		}
+		target, ok := resolvePointer(c.doc, ref[1:])
+		if !ok {
+			return fmt.Errorf("schema: %s/$ref: %q does not resolve", locationOf(loc), ref)
+		}
+		if n.ref, err = c.compile(target, ref[1:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks a decoded JSON value, as produced by json.Unmarshal into
+// an any, against the schema
+func (s *Schema) Validate(instance any) error {
+	return s.ValidateCtx(context.Background(), instance)
+}
+
+// ValidateJSON decodes and validates a JSON document
+func (s *Schema) ValidateJSON(data []byte) error {
+	var instance any
+	if err := json.Unmarshal(data, &instance); err != nil {
+		return fmt.Errorf("schema: %w", err)
+	}
+	return s.Validate(instance)
+}
+
+// ValidateCtx is Validate with a context that is passed on to the rules
+// checking formats. If ctx is done, its error is returned instead of
+// ValidationErrors.
+func (s *Schema) ValidateCtx(ctx context.Context, instance any) error {
+	var errs ValidationErrors
+	s.root.validate(ctx, instance, "", &errs)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (n *schemaNode) validate(ctx context.Context, inst any, ptr string, errs *ValidationErrors) {
+	fail := func(keyword, param string, format string, args ...any) {
+		*errs = append(*errs, &ValidationError{
+			Field:   ptr,
+			Rule:    keyword,
+			Param:   param,
+			Value:   scalarValue(inst),
+			Code:    schemaCodes[keyword],
+			Message: fieldLabel(ptr) + " " + fmt.Sprintf(format, args...),
+		})
+	}
+
+	if n.never {
+		fail("false", "", "is not allowed")
+		return
+	}
+	if n.ref != nil {
+		n.ref.validate(ctx, inst, ptr, errs)
+	}
+
+	if len(n.types) > 0 && !isJSONType(n.types, inst) {
+		fail("type", strings.Join(n.types, "|"), "must be of type %s", strings.Join(n.types, " or "))
+		return
+	}
+	if n.enum != nil && !containsJSON(n.enum, inst) {
+		fail("enum", "", "must be one of %s", compactJSON(n.enum))
+	}
+	if n.constant != nil && !reflect.DeepEqual(*n.constant, inst) {
+		fail("const", "", "must be %s", compactJSON(*n.constant))
+	}
+
+	switch v := inst.(type) {
+	case string:
+		n.validateString(ctx, v, ptr, fail, errs)
+	case float64:
+		n.validateNumber(v, fail)
+	case map[string]any:
+		n.validateObject(ctx, v, ptr, errs)
+	case []any:
+		if n.minItems != nil && float64(len(v)) < *n.minItems {
+			fail("minItems", formatNumber(*n.minItems), "must have at least %s items", formatNumber(*n.minItems))
+		}
+		if n.maxItems != nil && float64(len(v)) > *n.maxItems {
+			fail("maxItems", formatNumber(*n.maxItems), "must have at most %s items", formatNumber(*n.maxItems))
+		}
+		if n.items != nil {
+			for i, item := range v {
+				n.items.validate(ctx, item, ptr+"/"+strconv.Itoa(i), errs)
+			}
+		}
+	}
+
+	if n.oneOf != nil {
+		matches := 0
+		for _, sub := range n.oneOf {
+			var subErrs ValidationErrors
+			sub.validate(ctx, inst, ptr, &subErrs)
+			if len(subErrs) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			fail("oneOf", "", "must match exactly one schema in oneOf, matched %d", matches)
+		}
+	}
+}
+
+func (n *schemaNode) validateString(ctx context.Context, s, ptr string, fail func(string, string, string, ...any), errs *ValidationErrors) {
+	length := float64(len([]rune(s)))
+	if n.minLength != nil && length < *n.minLength {
+		fail("minLength", formatNumber(*n.minLength), "must be at least %s characters long", formatNumber(*n.minLength))
+	}
+	if n.maxLength != nil && length > *n.maxLength {
+		fail("maxLength", formatNumber(*n.maxLength), "must be at most %s characters long", formatNumber(*n.maxLength))
+	}
+	if n.pattern != nil && !n.pattern.MatchString(s) {
+		fail("pattern", n.pattern.String(), "must match %s", n.pattern)
+	}
+	if n.formatRule != nil && s != "" {
+		if err := n.formatRule(ctx, reflect.ValueOf(s), reflect.Value{}); err != nil && ctx.Err() == nil {
+			// Reported as the format keyword, with the code of the rule
+			e := ruleError(ptr, rule{name: "format", param: n.format}, reflect.ValueOf(s), false, err)
+			e.Rule, e.Param = "format", n.format
+			e.Message = fieldLabel(ptr) + " " + ruleErrorText(err)
+			*errs = append(*errs, e)
+		}
+	}
+}
+
+func (n *schemaNode) validateNumber(f float64, fail func(string, string, string, ...any)) {
+	if n.minimum != nil && f < *n.minimum {
+		fail("minimum", formatNumber(*n.minimum), "must be at least %s", formatNumber(*n.minimum))
+	}
+	if n.maximum != nil && f > *n.maximum {
+		fail("maximum", formatNumber(*n.maximum), "must be at most %s", formatNumber(*n.maximum))
+	}
+	if n.exclusiveMinimum != nil && f <= *n.exclusiveMinimum {
+		fail("exclusiveMinimum", formatNumber(*n.exclusiveMinimum), "must be greater than %s", formatNumber(*n.exclusiveMinimum))
+	}
+	if n.exclusiveMaximum != nil && f >= *n.exclusiveMaximum {
+		fail("exclusiveMaximum", formatNumber(*n.exclusiveMaximum), "must be less than %s", formatNumber(*n.exclusiveMaximum))
+	}
+}
+
+func (n *schemaNode) validateObject(ctx context.Context, obj map[string]any, ptr string, errs *ValidationErrors) {
+	for _, name := range n.required {
+		if _, ok := obj[name]; !ok {
+			field := ptr + "/" + escapePointer(name)
+			*errs = append(*errs, &ValidationError{
+				Field:   field,
+				Rule:    "required",
+				Code:    schemaCodes["required"],
+				Message: fieldLabel(field) + " is required",
+			})
+		}
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names) // report in a stable order
+
+	for _, name := range names {
+		field := ptr + "/" + escapePointer(name)
+		if sub, ok := n.properties[name]; ok {
+			sub.validate(ctx, obj[name], field, errs)
+		} else if n.additional != nil {
+			if n.additional.never {
+				*errs = append(*errs, &ValidationError{
+					Field:   field,
+					Rule:    "additionalProperties",
+					Code:    schemaCodes["additionalProperties"],
+					Message: fieldLabel(field) + " is not an allowed property",
+				})
+				continue
+			}
+			n.additional.validate(ctx, obj[name], field, errs)
+		}
+	}
+}
+
+// isJSONType reports whether inst is an instance of one of the
+// JSON Schema types
+func isJSONType(types []string, inst any) bool {
+	for _, t := range types {
+		switch v := inst.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case float64:
+			if t == "number" || t == "integer" && v == math.Trunc(v) && !math.IsInf(v, 0) {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case []any:
+			if t == "array" {
+				return true
+			}
+		case map[string]any:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsJSON(values []any, inst any) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, inst) {
+			return true
+		}
+	}
+	return false
+}
+
+// scalarValue returns inst for the Value of an error, leaving out objects
+// and arrays whose content is reported field by field
+func scalarValue(inst any) any {
+	switch inst.(type) {
+	case map[string]any, []any, nil:
+		return nil
+	}
+	return inst
+}
+
+func compactJSON(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// fieldLabel names a JSON Pointer in messages; the root has no name
+func fieldLabel(ptr string) string {
+	if ptr == "" {
+		return "value"
+	}
+	return ptr
+}
+
+func locationOf(loc string) string {
+	return "#" + loc
+}
+
+// escapePointer escapes a property name for use as a JSON Pointer token
+func escapePointer(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
+
+// resolvePointer looks up the JSON Pointer ptr in doc
+func resolvePointer(doc any, ptr string) (any, bool) {
+	if ptr == "" {
+		return doc, true
+	}
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, false
+	}
+	for _, token := range strings.Split(ptr[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch v := doc.(type) {
+		case map[string]any:
+			next, ok := v[token]
+			if !ok {
+				return nil, false
+			}
+			doc = next
+		case []any:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			doc = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
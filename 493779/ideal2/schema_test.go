@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// schemaFailures validates instance against schema and lists the failures
+// as "pointer:keyword"
+func schemaFailures(t *testing.T, schema, instance string) string {
+	t.Helper()
+	s, err := NewValidator().CompileSchema([]byte(schema))
+	if err != nil {
+		t.Fatalf("Failed to compile %s: %v", schema, err)
+	}
+	err = s.ValidateJSON([]byte(instance))
+	var verrs ValidationErrors
+	if err != nil && !errors.As(err, &verrs) {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+	var got []string
+	for _, e := range verrs {
+		got = append(got, e.Field+":"+e.Rule)
+	}
+	return strings.Join(got, " ")
+}
+
+func TestSchemaKeywords(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		instance string
+		expected string
+	}{
+		{"type", `{"type": "string"}`, `"a"`, ""},
+		{"type mismatch", `{"type": "string"}`, `1`, ":type"},
+		{"type list", `{"type": ["string", "null"]}`, `null`, ""},
+		{"integer", `{"type": "integer"}`, `1.0`, ""},
+		{"not an integer", `{"type": "integer"}`, `1.5`, ":type"},
+		{"boolean", `{"type": "boolean"}`, `"true"`, ":type"},
+		{"enum", `{"enum": ["a", 1, null]}`, `1`, ""},
+		{"not in enum", `{"enum": ["a", 1, null]}`, `"b"`, ":enum"},
+		{"const", `{"const": {"a": [1]}}`, `{"a": [1]}`, ""},
+		{"not const", `{"const": {"a": [1]}}`, `{"a": [2]}`, ":const"},
+		{"format email", `{"format": "email"}`, `"a@example.com"`, ""},
+		{"bad email", `{"format": "email"}`, `"a@"`, ":format"},
+		{"format date-time", `{"format": "date-time"}`, `"2024-02-29T10:00:00Z"`, ""},
+		{"bad date", `{"format": "date"}`, `"2024-02-30"`, ":format"},
+		{"format uri", `{"format": "uri"}`, `"ftp//x"`, ":format"},
+		{"unknown format", `{"format": "color"}`, `"red"`, ""},
+		{"format on a number", `{"format": "email"}`, `3`, ""},
+		{"pattern", `{"pattern": "^[a-z]+$"}`, `"abc"`, ""},
+		{"pattern mismatch", `{"pattern": "^[a-z]+$"}`, `"Abc"`, ":pattern"},
+		{"minLength counts runes", `{"minLength": 3}`, `"日本語"`, ""},
+		{"too short", `{"minLength": 3}`, `"ab"`, ":minLength"},
+		{"too long", `{"maxLength": 2}`, `"abc"`, ":maxLength"},
+		{"minimum", `{"minimum": 1}`, `1`, ""},
+		{"below minimum", `{"minimum": 1}`, `0.5`, ":minimum"},
+		{"above maximum", `{"maximum": 1}`, `2`, ":maximum"},
+		{"exclusiveMinimum", `{"exclusiveMinimum": 1}`, `1`, ":exclusiveMinimum"},
+		{"exclusiveMaximum", `{"exclusiveMaximum": 1}`, `1`, ":exclusiveMaximum"},
+		{"required", `{"required": ["a", "b"]}`, `{"a": 1}`, "/b:required"},
+		{"properties", `{"properties": {"a": {"type": "string"}}}`, `{"a": 1, "b": 2}`, "/a:type"},
+		{"additionalProperties false", `{"properties": {"a": {}}, "additionalProperties": false}`, `{"a": 1, "b": 2}`, "/b:additionalProperties"},
+		{"additionalProperties schema", `{"additionalProperties": {"type": "number"}}`, `{"a": 1, "b": "2"}`, "/b:type"},
+		{"items", `{"items": {"type": "number"}}`, `[1, "2", 3]`, "/1:type"},
+		{"minItems", `{"minItems": 2}`, `[1]`, ":minItems"},
+		{"maxItems", `{"maxItems": 1}`, `[1, 2]`, ":maxItems"},
+		{"oneOf", `{"oneOf": [{"type": "string"}, {"type": "number"}]}`, `1`, ""},
+		{"oneOf none", `{"oneOf": [{"type": "string"}, {"type": "number"}]}`, `true`, ":oneOf"},
+		{"oneOf both", `{"oneOf": [{"type": "number"}, {"minimum": 0}]}`, `1`, ":oneOf"},
+		{"false", `{"properties": {"a": false}}`, `{"a": 1}`, "/a:false"},
+		{"true", `true`, `{"a": 1}`, ""},
+		{"unknown keywords", `{"title": "x", "uniqueItems": true}`, `[1, 1]`, ""},
+		{"type stops the rest", `{"type": "string", "minLength": 2}`, `1`, ":type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := schemaFailures(t, tt.schema, tt.instance); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSchemaRef(t *testing.T) {
+	tree := `{
+		"$ref": "#/$defs/node",
+		"$defs": {
+			"node": {
+				"type": "object",
+				"required": ["name"],
+				"properties": {
+					"name": {"type": "string", "minLength": 1},
+					"children": {"type": "array", "items": {"$ref": "#/$defs/node"}}
+				}
+			}
+		}
+	}`
+	tests := []struct {
+		name     string
+		instance string
+		expected string
+	}{
+		{"Leaf", `{"name": "root"}`, ""},
+		{"Nested", `{"name": "root", "children": [{"name": "a", "children": [{"name": "b"}]}]}`, ""},
+		{"Deep failure", `{"name": "root", "children": [{"name": "a", "children": [{"name": ""}, {}]}]}`,
+			"/children/0/children/0/name:minLength /children/0/children/1/name:required"},
+		{"Wrong type deep down", `{"name": "root", "children": [{"name": "a", "children": "none"}]}`, "/children/0/children:type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := schemaFailures(t, tree, tt.instance); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSchemaPointers(t *testing.T) {
+	// Property names with "/" and "~" are escaped in the reported pointers
+	// and resolve when a $ref names them
+	schema := `{
+		"properties": {
+			"a/b": {"type": "number"},
+			"m~n": {"$ref": "#/$defs/x~1y"},
+			"list": {"items": {"$ref": "#/$defs/x~1y/properties/c~0d"}}
+		},
+		"$defs": {"x/y": {"type": "object", "properties": {"c~d": {"type": "string"}}, "required": ["c~d"]}}
+	}`
+	got := schemaFailures(t, schema, `{"a/b": "1", "m~n": {}, "list": ["ok", 2]}`)
+	expected := "/a~1b:type /list/1:type /m~0n/c~0d:required"
+	if got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	s, _ := NewValidator().CompileSchema([]byte(`{"type": "string"}`))
+	err := s.ValidateJSON([]byte(`1`))
+	if err == nil || err.Error() != ": value must be of type string" {
+		t.Errorf("Expected the root to be named value, got %v", err)
+	}
+}
+
+func TestSchemaCompileErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		expected string
+	}{
+		{"Not JSON", `{`, "schema: unexpected end of JSON input"},
+		{"Not a schema", `[1]`, "schema: #: expected object or boolean"},
+		{"Bad type", `{"type": 3}`, "schema: #/type: expected string or array of strings"},
+		{"Bad enum", `{"enum": "a"}`, "schema: #/enum: expected array"},
+		{"Bad pattern", `{"pattern": "("}`, "schema: #/pattern: error parsing regexp"},
+		{"Bad number", `{"properties": {"a": {"minLength": "2"}}}`, "schema: #/properties/a/minLength: expected number"},
+		{"Bad required", `{"required": [1]}`, "schema: #/required: expected array of strings"},
+		{"Empty oneOf", `{"oneOf": []}`, "schema: #/oneOf: expected non-empty array"},
+		{"Bad subschema", `{"items": 3}`, "schema: #/items: expected object or boolean"},
+		{"Escaped location", `{"properties": {"a/b": {"type": 1}}}`, "schema: #/properties/a~1b/type"},
+		{"Remote $ref", `{"$ref": "other.json#/a"}`, "schema: #/$ref: expected reference within the document"},
+		{"Dangling $ref", `{"$ref": "#/$defs/missing"}`, `schema: #/$ref: "#/$defs/missing" does not resolve`},
+		{"Bad pointer", `{"$ref": "#defs"}`, `schema: #/$ref: "#defs" does not resolve`},
+		{"Array index out of range", `{"oneOf": [true], "$ref": "#/oneOf/1"}`, `"#/oneOf/1" does not resolve`},
+		{"$ref to itself", `{"$defs": {"a": {"$ref": "#/$defs/a"}}, "$ref": "#/$defs/a"}`, "schema: #/$defs/a: $ref loops back to itself"},
+		{"$ref to the root", `{"$ref": "#"}`, "schema: #: $ref loops back to itself"},
+		{"$ref loop through oneOf", `{"$defs": {"a": {"oneOf": [{"$ref": "#/$defs/b"}]}, "b": {"$ref": "#/$defs/a"}}, "$ref": "#/$defs/b"}`, "$ref loops back to itself"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewValidator().CompileSchema([]byte(tt.schema))
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestSchemaRegisteredFormat(t *testing.T) {
+	v := NewValidator()
+	v.RegisterRule("even", func(value reflect.Value, _ string) bool { return len(value.String())%2 == 0 })
+	s, err := v.CompileSchema([]byte(`{"format": "even"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.ValidateJSON([]byte(`"abc"`))
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) || verrs[0].Rule != "format" || verrs[0].Param != "even" || verrs[0].Code != "invalid_even" {
+		t.Errorf("Expected a registered rule to check the format, got %#v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.ValidateCtx(ctx, "abc"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
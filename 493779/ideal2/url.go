@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// defaultPorts are dropped from normalized URLs
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+	"ftp":   "21",
+}
+
+// URLPolicy decides which absolute URLs are acceptable. The zero value
+// accepts http and https URLs on any port with a hostname or IP host.
+type URLPolicy struct {
+	Schemes      []string // allowed schemes, lowercase; http and https when empty
+	MinPort      int      // lowest explicit port allowed; 0 means 1
+	MaxPort      int      // highest explicit port allowed; 0 means 65535
+	DenyIP       bool     // reject IP-literal hosts
+	DenyUserinfo bool     // reject user:password@ in the authority
+	AllowHosts   []string // if set, only these hosts and their subdomains
+	DenyHosts    []string // these hosts and their subdomains are rejected
+}
+
+// ParseURL parses raw with net/url, checks it against the policy and
+// returns the URL in normalized form
+func (p URLPolicy) ParseURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	schemes := p.Schemes
+	if len(schemes) == 0 {
+		schemes = []string{"http", "https"}
+	}
+	if !slices.Contains(schemes, u.Scheme) {
+		return nil, fmt.Errorf("invalid URL %q: scheme %q not allowed", raw, u.Scheme)
+	}
+	if u.Opaque != "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q: missing host", raw)
+	}
+	if u.User != nil && p.DenyUserinfo {
+		return nil, fmt.Errorf("invalid URL %q: credentials not allowed", raw)
+	}
+
+	host, err := p.checkHost(u.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", raw, err)
+	}
+
+	port := u.Port()
+	if port != "" {
+		if err := p.checkPort(port); err != nil {
+			return nil, fmt.Errorf("invalid URL %q: %w", raw, err)
+		}
+		if defaultPorts[u.Scheme] == port {
+			port = ""
+		}
+	}
+
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	// Dot segments are removed from the escaped path, so that an encoded
+	// slash such as the one in /files/a%2Fb stays encoded
+	u.RawPath = normalizePath(u.EscapedPath())
+	u.Path, _ = url.PathUnescape(u.RawPath)
+	return u, nil
+}
+
+// NormalizeURL returns the canonical string form of raw under the policy
+func (p URLPolicy) NormalizeURL(raw string) (string, error) {
+	u, err := p.ParseURL(raw)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// checkHost validates a hostname or IP address and returns it in
+// canonical form: punycode for names, compressed form for IPv6
+func (p URLPolicy) checkHost(host string) (string, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if p.DenyIP {
+			return "", errors.New("IP address hosts not allowed")
+		}
+		return addr.WithZone("").String(), nil
+	}
+
+	ascii, err := DomainToASCII(host)
+	if err != nil {
+		return "", err
+	}
+	if len(p.AllowHosts) > 0 && !matchesHost(ascii, p.AllowHosts) {
+		return "", fmt.Errorf("host %q not allowed", ascii)
+	}
+	if matchesHost(ascii, p.DenyHosts) {
+		return "", fmt.Errorf("host %q denied", ascii)
+	}
+	return ascii, nil
+}
+
+// matchesHost reports whether host equals or is a subdomain of any of hosts
+func matchesHost(host string, hosts []string) bool {
+	for _, h := range hosts {
+		h = strings.ToLower(h)
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p URLPolicy) checkPort(port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	lo, hi := p.MinPort, p.MaxPort
+	if lo == 0 {
+		lo = 1
+	}
+	if hi == 0 {
+		hi = 65535
+	}
+	if n < lo || n > hi {
+		return fmt.Errorf("port %d outside %d-%d", n, lo, hi)
+	}
+	return nil
+}
+
+// normalizePath removes the dot segments of an escaped path; an empty path
+// becomes "/". Empty segments are kept, since //a and /a are different
+// resources to the server.
+func normalizePath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	return removeDotSegments(p)
+}
+
+// removeDotSegments implements the algorithm of RFC 3986 section 5.2.4
+func removeDotSegments(in string) string {
+	var out strings.Builder
+	removeLast := func() {
+		s := out.String()
+		out.Reset()
+		if i := strings.LastIndexByte(s, '/'); i >= 0 {
+			out.WriteString(s[:i])
+		}
+	}
+	for in != "" {
+		switch {
+		case strings.HasPrefix(in, "../"):
+			in = in[3:]
+		case strings.HasPrefix(in, "./"), strings.HasPrefix(in, "/./"):
+			in = in[2:]
+		case in == "/.":
+			in = "/"
+		case strings.HasPrefix(in, "/../"):
+			in = in[3:]
+			removeLast()
+		case in == "/..":
+			in = "/"
+			removeLast()
+		case in == "." || in == "..":
+			in = ""
+		default:
+			end := len(in)
+			if i := strings.IndexByte(in[1:], '/'); i >= 0 {
+				end = i + 1
+			}
+			out.WriteString(in[:end])
+			in = in[end:]
+		}
+	}
+	return out.String()
+}
+
+// validateURLRule checks a string against URLPolicy; the param may list
+// allowed schemes separated by "|", e.g. `validate:"url=https|wss"`
+func validateURLRule(value reflect.Value, param string) bool {
+	if value.Kind() != reflect.String {
+		return false
+	}
+	var policy URLPolicy
+	if param != "" {
+		policy.Schemes = strings.Split(strings.ToLower(param), "|")
+	}
+	_, err := policy.ParseURL(value.String())
+	return err == nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import "testing"
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected string
+	}{
+		{"HTTP://LocalHost:8080/a/../b", "http://localhost:8080/b"},
+		{"https://example.com:443", "https://example.com/"},
+		{"http://example.com/a/./b/../c/", "http://example.com/a/c/"},
+		{"http://example.com/a/b/..", "http://example.com/a/"},
+		{"http://example.com/../../a", "http://example.com/a"},
+		{"http://example.com/files/a%2Fb", "http://example.com/files/a%2Fb"},
+		{"http://example.com/files/a%2Fb/../c", "http://example.com/files/c"},
+		{"http://example.com//x//y", "http://example.com//x//y"},
+		{"http://example.com/a%20b?q=1#top", "http://example.com/a%20b?q=1#top"},
+		{"http://bücher.de/", "http://xn--bcher-kva.de/"},
+		{"http://[::FFFF:1.2.3.4]:80/", "http://[::ffff:1.2.3.4]/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := (URLPolicy{}).NormalizeURL(tt.raw)
+			if err != nil || got != tt.expected {
+				t.Errorf("Expected %s, got %s (%v)", tt.expected, got, err)
+			}
+		})
+	}
+}
+
+func TestRemoveDotSegments(t *testing.T) {
+	// The examples of RFC 3986 section 5.2.4 and the dot-segment cases of
+	// section 5.4
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/a/b/c/./../../g", "/a/g"},
+		{"mid/content=5/../6", "mid/6"},
+		{"/b/c/.", "/b/c/"},
+		{"/b/c/./", "/b/c/"},
+		{"/b/c/..", "/b/"},
+		{"/b/c/../", "/b/"},
+		{"/b/c/../g", "/b/g"},
+		{"/b/c/../..", "/"},
+		{"/b/c/../../g", "/g"},
+		{"/b/c/../../../g", "/g"},
+		{"/./g", "/g"},
+		{"/../g", "/g"},
+		{"/b/c/g.", "/b/c/g."},
+		{"/b/c/.g", "/b/c/.g"},
+		{"/b/c/g..", "/b/c/g.."},
+		{"/b/c/..g", "/b/c/..g"},
+		{"/b/c/./../g", "/b/g"},
+		{"/b/c/./g/.", "/b/c/g/"},
+		{"/b/c/g/./h", "/b/c/g/h"},
+		{"/b/c/g/../h", "/b/c/h"},
+		{"//a/../b", "//b"},
+	}
+	for _, tt := range tests {
+		if got := removeDotSegments(tt.path); got != tt.expected {
+			t.Errorf("Expected %s for %s, got %s", tt.expected, tt.path, got)
+		}
+	}
+}
+
+func TestURLPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy URLPolicy
+		raw    string
+		valid  bool
+	}{
+		{"Default", URLPolicy{}, "https://example.com/", true},
+		{"Scheme", URLPolicy{}, "ftp://example.com/", false},
+		{"Allowed scheme", URLPolicy{Schemes: []string{"ftp"}}, "ftp://example.com/", true},
+		{"No host", URLPolicy{}, "http:///path", false},
+		{"Opaque", URLPolicy{}, "http:example.com", false},
+		{"Userinfo", URLPolicy{DenyUserinfo: true}, "https://user:pw@example.com/", false},
+		{"IP", URLPolicy{DenyIP: true}, "https://127.0.0.1/", false},
+		{"Port", URLPolicy{MinPort: 1024}, "https://example.com:80/", false},
+		{"Allowed host", URLPolicy{AllowHosts: []string{"example.com"}}, "https://api.example.com/", true},
+		{"Other host", URLPolicy{AllowHosts: []string{"example.com"}}, "https://example.org/", false},
+		{"Denied host", URLPolicy{DenyHosts: []string{"example.com"}}, "https://API.Example.com/", false},
+		{"Bad label", URLPolicy{}, "https://-bad.example.com/", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.policy.ParseURL(tt.raw)
+			if (err == nil) != tt.valid {
+				t.Errorf("Expected valid = %v for %s, got %v", tt.valid, tt.raw, err)
+			}
+		})
+	}
+}
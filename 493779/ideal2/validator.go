@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// RuleFunc reports whether value satisfies a rule. param is the text after
+// "=" in the tag (e.g. "120" for "max=120") and is empty for bare rules.
+type RuleFunc func(value reflect.Value, param string) bool
+
+// FieldRuleFunc is a rule that also sees the struct holding the field, for
+// checks such as `validate:"after=StartDate"` that compare two fields.
+type FieldRuleFunc func(value, parent reflect.Value, param string) bool
+
+// Validator checks structs against the rules declared in their `validate`
+// struct tags, e.g. `validate:"required,email"` or `validate:"min=1,max=120"`.
+// Zero values are checked like any other, so "min=1" rejects 0; "required"
+// reports them as missing instead, and "omitempty" lets them through for
+// optional fields, e.g. `validate:"omitempty,url"`.
+type Validator struct {
+	tagName string
+	mu      sync.RWMutex // guards rules, which may be registered while in use
+	rules   map[string]RuleFactory
+	cache   sync.Map // reflect.Type -> []fieldRules
+}
+
+// fieldRules is the parsed form of one struct field's tag
+type fieldRules struct {
+	index     int
+	name      string
+	required  bool
+	omitempty bool
+	sensitive bool
+	rules     []rule
+	onZero    []rule // those of rules in zeroValueRules
+}
+
+// active returns the rules that apply to value. A zero value is reported
+// as missing if the field is required; if it is omitempty only the
+// zeroValueRules see it, and otherwise every rule does, so that "min=1"
+// rejects 0. A missing value, as passed to Var as nil, is treated as
+// omitempty since most rules cannot look at it.
+func (f *fieldRules) active(value reflect.Value) (rules []rule, missing bool) {
+	switch {
+	case value.IsValid() && !value.IsZero():
+		return f.rules, false
+	case f.required:
+		return nil, true
+	case f.omitempty || !value.IsValid():
+		return f.onZero, false
+	}
+	return f.rules, false
+}
+
+type rule struct {
+	name  string
+	param string
+	fn    Rule
+}
+
+// NewValidator returns a Validator with the built-in rules registered
+func NewValidator() *Validator {
+	v := &Validator{
+		tagName: "validate",
+		rules:   make(map[string]RuleFactory),
+	}
+	v.RegisterRule("email", validateEmailRule)
+	v.RegisterRule("number", matchString(numberPattern.MatchString))
+	v.RegisterRule("url", validateURLRule)
+	v.RegisterRule("phone", validatePhoneRule)
+	v.RegisterRule("date", validateDateRule)
+	v.RegisterFactory("min", sizeRule(func(have, want float64) bool { return have >= want }))
+	v.RegisterFactory("max", sizeRule(func(have, want float64) bool { return have <= want }))
+	v.RegisterFactory("len", sizeRule(func(have, want float64) bool { return have == want }))
+	v.RegisterRule("oneof", validateOneOfRule)
+	v.RegisterRule("future", validateFutureRule)
+	v.RegisterRule("notfuture", validateNotFutureRule)
+	v.RegisterFactory("within", withinRule)
+	v.RegisterFieldRule("after", v.validateAfterRule)
+	v.RegisterFieldRule("before", v.validateBeforeRule)
+	v.RegisterFactory("required_if", requiredIfRule)
+	v.RegisterFactory("required_unless", requiredUnlessRule)
+	v.RegisterFactory("equal_field", equalFieldRule)
+	v.RegisterFactory("not_equal_field", notEqualFieldRule)
+	return v
+}
+
+// RegisterRule adds or replaces the rule available under name
+func (v *Validator) RegisterRule(name string, fn RuleFunc) {
+	v.RegisterFieldRule(name, func(value, _ reflect.Value, param string) bool {
+		return fn(value, param)
+	})
+}
+
+// RegisterFieldRule adds or replaces a rule that needs the enclosing struct
+func (v *Validator) RegisterFieldRule(name string, fn FieldRuleFunc) {
+	v.RegisterFactory(name, boolRule(fn))
+}
+
+// Struct validates every tagged field of s, which must be a struct or a
+// pointer to one. It does not stop at the first failure: all failing fields
+// are returned together as ValidationErrors.
+func (v *Validator) Struct(s any) error {
+	return v.StructCtx(context.Background(), s)
+}
+
+// StructCtx is Struct with a context that is passed on to every rule, for
+// rules that look things up elsewhere. If ctx is done, its error is
+// returned instead of ValidationErrors.
+func (v *Validator) StructCtx(ctx context.Context, s any) error {
+	rv := reflect.ValueOf(s)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return errors.New("validate: nil pointer")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("validate: expected struct, got %s", rv.Kind())
+	}
+
+	var errs ValidationErrors
+	if err := v.walk(ctx, rv, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (v *Validator) walk(ctx context.Context, rv reflect.Value, prefix string, errs *ValidationErrors) error {
+	fields, err := v.fieldsOf(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		fv := rv.Field(f.index)
+		path := f.name
+		if prefix != "" {
+			path = prefix + "." + f.name
+		}
+
+		rules, missing := f.active(fv)
+		if missing {
+			*errs = append(*errs, newValidationError(path, "required", "", fv, f.sensitive))
+			continue
+		}
+		if err := checkRules(ctx, rules, fv, rv, path, f.sensitive, errs); err != nil {
+			return err
+		}
+
+		if fv.IsZero() {
+			continue
+		}
+		if err := v.descend(ctx, fv, path, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRules runs rules against one field and records their failures. It
+// only returns an error if ctx is done.
+func checkRules(ctx context.Context, rules []rule, fv, parent reflect.Value, path string, sensitive bool, errs *ValidationErrors) error {
+	for _, r := range rules {
+		err := r.check(ctx, fv, parent)
+		if err == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		*errs = append(*errs, ruleError(path, r, fv, sensitive, err))
+	}
+	return nil
+}
+
+// descend validates nested structs, pointers to structs and slices of them
+func (v *Validator) descend(ctx context.Context, fv reflect.Value, path string, errs *ValidationErrors) error {
+	for fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+
+	switch fv.Kind() {
+	case reflect.Struct:
+		if fv.Type() == reflect.TypeOf(time.Time{}) {
+			return nil
+		}
+		return v.walk(ctx, fv, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			if err := v.descend(ctx, fv.Index(i), fmt.Sprintf("%s[%d]", path, i), errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fieldsOf parses and caches the validation tags of t
+func (v *Validator) fieldsOf(t reflect.Type) ([]fieldRules, error) {
+	if cached, ok := v.cache.Load(t); ok {
+		return cached.([]fieldRules), nil
+	}
+
+	var fields []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		tag := sf.Tag.Get(v.tagName)
+		if tag == "-" {
+			continue
+		}
+		f, err := v.parseTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("%w on field %s.%s", err, t.Name(), sf.Name)
+		}
+		f.index = i
+		f.name = fieldName(sf)
+		fields = append(fields, f)
+	}
+
+	v.cache.Store(t, fields)
+	return fields, nil
+}
+
+// parseTag turns "required,min=1,max=120" into its rules. Params are
+// checked here, so a tag such as "min=abc" is rejected when its struct is
+// first validated rather than failing every value.
+func (v *Validator) parseTag(tag string) (fieldRules, error) {
+	var f fieldRules
+	for _, part := range strings.Split(tag, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, param, _ := strings.Cut(part, "=")
+		switch name {
+		case "required":
+			f.required = true
+			continue
+		case "omitempty":
+			f.omitempty = true
+			continue
+		case "sensitive":
+			f.sensitive = true
+			continue
+		}
+		factory, ok := v.ruleFactory(name)
+		if !ok {
+			return f, fmt.Errorf("validate: unknown rule %q", name)
+		}
+		fn, err := factory(param)
+		if err != nil {
+			return f, fmt.Errorf("%w in rule %q", err, name)
+		}
+		r := rule{name: name, param: param, fn: fn}
+		f.rules = append(f.rules, r)
+		if zeroValueRules[name] {
+			f.onZero = append(f.onZero, r)
+		}
+	}
+	f.rules = withDateLayouts(f.rules)
+	return f, nil
+}
+
+// Var validates a single value against tag, reporting failures under field.
+// It is the struct-free counterpart of Struct for one-off checks.
+func (v *Validator) Var(field string, value any, tag string) error {
+	f, err := v.parseTag(tag)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var errs ValidationErrors
+	fv := reflect.ValueOf(value)
+	if rules, missing := f.active(fv); missing {
+		errs = append(errs, newValidationError(field, "required", "", reflect.Value{}, f.sensitive))
+	} else {
+		checkRules(ctx, rules, fv, reflect.Value{}, field, f.sensitive, &errs)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// fieldName prefers the json name, then the name the field is bound from
+// in the request, so errors line up with what the client sent
+func fieldName(sf reflect.StructField) string {
+	if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	for _, key := range []string{"form", "query", "path", "header"} {
+		if name := sf.Tag.Get(key); name != "" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+// lookupField finds a sibling field by Go or json name for cross-field rules
+func lookupField(parent reflect.Value, name string) (reflect.Value, bool) {
+	if parent.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	t := parent.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.IsExported() && (sf.Name == name || fieldName(sf) == name) {
+			return parent.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// matchString adapts a string predicate into a rule; non-string values fail
+func matchString(match func(string) bool) RuleFunc {
+	return func(value reflect.Value, _ string) bool {
+		return value.Kind() == reflect.String && match(value.String())
+	}
+}
+
+// size returns the number a min/max/len rule compares against: the value
+// itself for numbers, the rune count for strings and the length otherwise
+func size(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), true
+	}
+	return 0, false
+}
+
+// sizeRule builds min, max and len, which compare the size of the value
+// with the number in their param
+func sizeRule(ok func(have, want float64) bool) RuleFactory {
+	return func(param string) (Rule, error) {
+		want, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return nil, fmt.Errorf("validate: expected a number, got %q", param)
+		}
+		return boolRule(func(value, _ reflect.Value, _ string) bool {
+			have, valid := size(value)
+			return valid && ok(have, want)
+		})(param)
+	}
+}
+
+// validateOneOfRule checks the value against a space-separated list,
+// e.g. `validate:"oneof=admin user guest"`
+func validateOneOfRule(value reflect.Value, param string) bool {
+	s := fmt.Sprint(value.Interface())
+	for _, option := range strings.Fields(param) {
+		if s == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// failures lists err's failures as "field:rule", or returns nil if err is
+// not ValidationErrors
+func failures(err error) []string {
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil
+	}
+	out := make([]string, len(verrs))
+	for i, e := range verrs {
+		out[i] = e.Field + ":" + e.Rule
+	}
+	return out
+}
+
+func TestValidatorZeroValues(t *testing.T) {
+	type Account struct {
+		Name     string   `json:"name" validate:"required"`
+		Age      int      `json:"age" validate:"min=1,max=120"`
+		Password string   `json:"password" validate:"min=8,sensitive"`
+		Website  string   `json:"website" validate:"omitempty,url"`
+		Nickname string   `json:"nickname" validate:"omitempty,min=3"`
+		Tags     []string `json:"tags" validate:"len=2"`
+	}
+	tests := []struct {
+		name     string
+		account  Account
+		expected string
+	}{
+		{"All set", Account{Name: "a", Age: 30, Password: "long enough", Nickname: "abc", Tags: []string{"x", "y"}}, ""},
+		{"Zero values", Account{}, "name:required age:min password:min tags:len"},
+		{"Omitted fields are still checked when set", Account{Name: "a", Age: 1, Password: "long enough", Website: "nope", Nickname: "ab", Tags: []string{"x", "y"}},
+			"website:url nickname:min"},
+		{"Sizes", Account{Name: "a", Age: 121, Password: "short", Tags: []string{"x"}}, "age:max password:min tags:len"},
+	}
+	v := NewValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(failures(v.Struct(tt.account)), " ")
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidatorSensitive(t *testing.T) {
+	type Login struct {
+		Password string `json:"password" validate:"min=8,sensitive"`
+	}
+	err := NewValidator().Struct(Login{Password: "hunter2"})
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) || len(verrs) != 1 || verrs[0].Value != redacted {
+		t.Errorf("Expected the password to be redacted, got %#v", err)
+	}
+}
+
+func TestValidatorNested(t *testing.T) {
+	type Address struct {
+		City string `json:"city" validate:"required"`
+	}
+	type Order struct {
+		Ship  *Address  `json:"ship"`
+		Stops []Address `json:"stops"`
+		Bill  Address   `json:"bill"`
+	}
+	order := Order{Ship: &Address{}, Stops: []Address{{City: "Paris"}, {}}}
+	expected := "ship.city:required stops[1].city:required"
+	if got := strings.Join(failures(NewValidator().Struct(&order)), " "); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestValidatorTagErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    any
+		expected string
+	}{
+		{"Unknown rule", struct {
+			N int `validate:"mni=1"`
+		}{}, `validate: unknown rule "mni" on field .N`},
+		{"Bad min", struct {
+			N int `validate:"min=abc"`
+		}{}, `validate: expected a number, got "abc" in rule "min" on field .N`},
+		{"Bad len", struct {
+			S string `validate:"len="`
+		}{}, `validate: expected a number, got "" in rule "len" on field .S`},
+		{"Bad condition", struct {
+			S string `validate:"required_if=Country"`
+		}{}, `expected field/value pairs, got "Country" in rule "required_if"`},
+		{"Not a struct", 3, "validate: expected struct, got int"},
+		{"Nil pointer", (*struct{})(nil), "validate: nil pointer"},
+	}
+	v := NewValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Struct(tt.value)
+			if err == nil || failures(err) != nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestValidatorVar(t *testing.T) {
+	tests := []struct {
+		value    any
+		tag      string
+		expected string
+	}{
+		{"", "required,email", "email:required"},
+		{nil, "required", "email:required"},
+		{nil, "min=1", ""},
+		{0, "min=1", "email:min"},
+		{0, "omitempty,min=1", ""},
+		{"", "email", "email:email"},
+		{"", "omitempty,email", ""},
+		{"a@example.com", "email,max=5", "email:max"},
+		{"admin", "oneof=admin user", ""},
+		{"root", "oneof=admin user", "email:oneof"},
+	}
+	v := NewValidator()
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			err := v.Var("email", tt.value, tt.tag)
+			if got := strings.Join(failures(err), " "); got != tt.expected || (err == nil) != (tt.expected == "") {
+				t.Errorf("Expected %q for %#v, got %v", tt.expected, tt.value, err)
+			}
+		})
+	}
+	if err := v.Var("n", 1, "max=ten"); err == nil || failures(err) != nil {
+		t.Errorf("Expected a bad param to reject the tag, got %v", err)
+	}
+}
+
+func TestValidatorContext(t *testing.T) {
+	v := NewValidator()
+	v.Register("slow", func(ctx context.Context, _, _ reflect.Value) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := v.StructCtx(ctx, struct {
+		S string `validate:"slow"`
+	}{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+// Package sanitizer makes untrusted input safe to write out: encoders for
+// the contexts a value can be written to, and an allow-list Policy for
+// user-supplied HTML.
+package sanitizer
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Encoders for the sinks untrusted input commonly ends up in. Rather than
+// trying to make one string safe everywhere, as a1.go once did by
+// stripping tags and escaping quotes, each encoder is only correct for its
+// own context: pick the one matching where the value is written, at the
+// moment it is written.
+//
+// There is deliberately no SQL encoder. Values reach SQL through
+// placeholders (db.Query("... WHERE id = ?", id)), never through escaping.
+
+// isAlnum reports whether c is an ASCII letter or digit, which every
+// encoder below passes through unchanged
+func isAlnum(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// HTMLBody encodes s for use as text content between HTML tags. Only the
+// five characters significant in that context are replaced.
+func HTMLBody(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '&':
+			b.WriteString("&amp;")
+		case '<':
+			b.WriteString("&lt;")
+		case '>':
+			b.WriteString("&gt;")
+		case '"':
+			b.WriteString("&#34;")
+		case '\'':
+			b.WriteString("&#39;")
+		case 0:
+			b.WriteRune(utf8.RuneError)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// HTMLAttr encodes s for use as an HTML attribute value. Every ASCII
+// character other than letters and digits becomes a numeric character
+// reference, so the result is safe even in an unquoted attribute. It does
+// not make URLs safe: check the scheme of href/src values separately.
+func HTMLAttr(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case isAlnum(r), r >= utf8.RuneSelf && r != utf8.RuneError:
+			b.WriteRune(r)
+		case r == 0 || r == utf8.RuneError:
+			b.WriteString("&#xfffd;")
+		default:
+			fmt.Fprintf(&b, "&#x%x;", r)
+		}
+	}
+	return b.String()
+}
+
+// JSString encodes s for use inside a quoted JavaScript string literal,
+// including one embedded in an HTML <script> block or event handler.
+// Non-alphanumeric ASCII becomes \xHH and the line terminators U+2028 and
+// U+2029 become \uHHHH, so neither quotes nor "</script>" survive.
+func JSString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case isAlnum(r), r == ' ', r == ',', r == '.', r == '_':
+			b.WriteRune(r)
+		case r < utf8.RuneSelf:
+			fmt.Fprintf(&b, "\\x%02x", r)
+		case r == '\u2028' || r == '\u2029' || r == utf8.RuneError:
+			fmt.Fprintf(&b, "\\u%04x", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// URLComponent percent-encodes s for use as a single path segment or query
+// value. Only RFC 3986 unreserved characters are left as they are.
+func URLComponent(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isAlnum(rune(c)) || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// CSS encodes s for use inside a quoted CSS string or identifier, using
+// the \HH escape followed by a space so the next character cannot be read
+// as part of the hex sequence. It cannot make arbitrary property values
+// such as url(...) or expression(...) safe.
+func CSS(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case isAlnum(r), r >= utf8.RuneSelf && r != utf8.RuneError:
+			b.WriteRune(r)
+		case r == 0 || r == utf8.RuneError:
+			b.WriteString(`\fffd `)
+		default:
+			fmt.Fprintf(&b, `\%x `, r)
+		}
+	}
+	return b.String()
+}
+
+// ShellArg quotes s as a single POSIX shell word. The value is wrapped in
+// single quotes, inside which the shell interprets nothing; an embedded
+// single quote closes the quoting, adds an escaped quote and reopens it.
+// Quoting does not stop a value that starts with "-" being read as an
+// option; put "--" before it. Prefer exec.Command with separate arguments,
+// which needs no quoting at all.
+func ShellArg(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range s {
+		if !(isAlnum(r) || strings.ContainsRune("-_./:=@%+,", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
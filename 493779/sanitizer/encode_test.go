@@ -0,0 +1,154 @@
+package sanitizer
+
+import (
+	"net/url"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// encoderCorpus holds inputs aimed at breaking out of each sink
+var encoderCorpus = []string{
+	"",
+	"plain",
+	`"quoted" and 'single'`,
+	"<script>alert('XSS');</script>",
+	"</script><script>alert(1)</script>",
+	"' OR '1'='1' --",
+	`\'; alert(1); //`,
+	"javascript:alert(1)",
+	"a&b=c#d?e/f%20g",
+	"line\nbreak\rcarriage\ttab",
+	"\u2028\u2029line separators",
+	"nul\x00byte",
+	"invalid\xffutf8",
+	"$(rm -rf /) `id` ${HOME} | & ; > <",
+	"-rf",
+	"expression(alert(1)); } body { background: url(x)",
+	"日本語 テキスト 😀",
+}
+
+func TestHTMLBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Plain text", "Hello", "Hello"},
+		{"Markup", "<b>x</b>", "&lt;b&gt;x&lt;/b&gt;"},
+		{"Quotes and ampersand", `"a" & 'b'`, "&#34;a&#34; &amp; &#39;b&#39;"},
+		{"Unicode", "こんにちは", "こんにちは"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := HTMLBody(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+
+	for _, input := range encoderCorpus {
+		if result := HTMLBody(input); strings.ContainsAny(result, `<>"'`) {
+			t.Errorf("HTMLBody(%q) = %q contains markup characters", input, result)
+		}
+	}
+}
+
+func TestHTMLAttr(t *testing.T) {
+	if result := HTMLAttr(`x" onmouseover="y`); result != "x&#x22;&#x20;onmouseover&#x3d;&#x22;y" {
+		t.Errorf("Expected attribute breakout to be encoded, got %s", result)
+	}
+
+	for _, input := range encoderCorpus {
+		result := HTMLAttr(input)
+		if strings.ContainsAny(result, " \t\n\r\"'<>=`/\x00") {
+			t.Errorf("HTMLAttr(%q) = %q contains characters that end an attribute", input, result)
+		}
+	}
+}
+
+func TestJSString(t *testing.T) {
+	if result := JSString("</script>"); result != `\x3c\x2fscript\x3e` {
+		t.Errorf("Expected \\x3c\\x2fscript\\x3e, got %s", result)
+	}
+
+	for _, input := range encoderCorpus {
+		result := JSString(input)
+		if strings.ContainsAny(result, "\"'`<>\n\r\u2028\u2029") {
+			t.Errorf("JSString(%q) = %q contains string terminators", input, result)
+		}
+	}
+}
+
+func TestURLComponent(t *testing.T) {
+	if result := URLComponent("a b/c?d=e&f"); result != "a%20b%2Fc%3Fd%3De%26f" {
+		t.Errorf("Expected a%%20b%%2Fc%%3Fd%%3De%%26f, got %s", result)
+	}
+
+	for _, input := range encoderCorpus {
+		result := URLComponent(input)
+		if strings.ContainsAny(result, "/?#&=+ :;") {
+			t.Errorf("URLComponent(%q) = %q contains URL delimiters", input, result)
+		}
+		decoded, err := url.PathUnescape(result)
+		if err != nil || decoded != input {
+			t.Errorf("URLComponent(%q) does not round-trip: got %q, %v", input, decoded, err)
+		}
+	}
+}
+
+func TestCSS(t *testing.T) {
+	if result := CSS("a;b"); result != `a\3b b` {
+		t.Errorf(`Expected a\3b b, got %s`, result)
+	}
+
+	for _, input := range encoderCorpus {
+		if result := CSS(input); strings.ContainsAny(result, "\"'();{}<>:\n") {
+			t.Errorf("CSS(%q) = %q contains CSS delimiters", input, result)
+		}
+	}
+}
+
+func TestShellArg(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Empty", "", "''"},
+		{"Safe word", "file-1.txt", "file-1.txt"},
+		{"Spaces", "a b", "'a b'"},
+		{"Single quote", "it's", `'it'\''s'`},
+		{"Command substitution", "$(id)", "'$(id)'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ShellArg(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+
+	// The shell must hand every quoted value back unchanged
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	for _, input := range encoderCorpus {
+		if strings.ContainsRune(input, 0) {
+			continue // NUL cannot appear in an argument
+		}
+		out, err := exec.Command(sh, "-c", "printf '%s' "+ShellArg(input)).Output()
+		if err != nil {
+			t.Errorf("sh rejected ShellArg(%q) = %q: %v", input, ShellArg(input), err)
+			continue
+		}
+		if string(out) != input {
+			t.Errorf("ShellArg(%q) round-tripped to %q", input, out)
+		}
+	}
+}
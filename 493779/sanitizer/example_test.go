@@ -0,0 +1,45 @@
+package sanitizer_test
+
+import (
+	"fmt"
+	"net/url"
+
+	"snippets/493779/sanitizer"
+)
+
+func Example() {
+	// The query string from 493779/a1.go, with the ";" escaped as
+	// url.ParseQuery requires
+	queryString := "user=admin&password=secret&action=<script>alert('XSS')%3B</script>"
+
+	// Parse the query string
+	parsedQuery, err := url.ParseQuery(queryString)
+	if err != nil {
+		fmt.Println("Error parsing query string:", err)
+		return
+	}
+
+	// Encode the value for the sink it is written to, not once up front
+	action := parsedQuery.Get("action")
+	fmt.Println("HTML body:     ", sanitizer.HTMLBody(action))
+	fmt.Println("HTML attribute:", sanitizer.HTMLAttr(action))
+	fmt.Println("JS string:     ", sanitizer.JSString(action))
+	fmt.Println("URL component: ", sanitizer.URLComponent(action))
+	fmt.Println("Shell argument:", sanitizer.ShellArg(action))
+	fmt.Println("CSS:           ", sanitizer.CSS(action))
+	// Output:
+	// HTML body:      &lt;script&gt;alert(&#39;XSS&#39;);&lt;/script&gt;
+	// HTML attribute: &#x3c;script&#x3e;alert&#x28;&#x27;XSS&#x27;&#x29;&#x3b;&#x3c;&#x2f;script&#x3e;
+	// JS string:      \x3cscript\x3ealert\x28\x27XSS\x27\x29\x3b\x3c\x2fscript\x3e
+	// URL component:  %3Cscript%3Ealert%28%27XSS%27%29%3B%3C%2Fscript%3E
+	// Shell argument: '<script>alert('\''XSS'\'');</script>'
+	// CSS:            \3c script\3e alert\28 \27 XSS\27 \29 \3b \3c \2f script\3e
+}
+
+func ExamplePolicy_Sanitize() {
+	// Rich text goes through an allow-list policy instead
+	policy := sanitizer.UGCPolicy()
+	comment := `<p onclick="steal()">Nice <b>post</b>! <a href="javascript:alert(1)">x</a> <a href="/docs">docs</a><script>alert(1)</script></p>`
+	fmt.Println(policy.Sanitize(comment))
+	// Output: <p>Nice <b>post</b>! <a>x</a> <a href="/docs" rel="nofollow">docs</a></p>
+}
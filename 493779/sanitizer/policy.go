@@ -0,0 +1,263 @@
+package sanitizer
+
+import (
+	"html"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Policy is an allow-list HTML sanitizer in the spirit of bluemonday, as
+// used by sanitizeInput in 493779/b2.go, without the dependency. Anything
+// not explicitly allowed is removed: disallowed tags are dropped while
+// their text is kept, except for elements such as <script> whose content
+// is dropped too. Policies are built once and are safe for concurrent use
+// by Sanitize afterwards.
+type Policy struct {
+	elements       map[string]bool
+	attrs          map[string]map[string]bool // element -> attribute; "" means any element
+	urlSchemes     []string
+	allowRelative  bool
+	requireParse   bool
+	addNoFollow    bool
+	dropContentsOf map[string]bool
+}
+
+// urlAttrs are attributes whose value is fetched or navigated to
+var urlAttrs = map[string]bool{
+	"href":       true,
+	"src":        true,
+	"cite":       true,
+	"action":     true,
+	"formaction": true,
+	"poster":     true,
+	"background": true,
+	"longdesc":   true,
+}
+
+// voidElements never have content or an end tag
+var voidElements = map[string]bool{
+	"area": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// NewPolicy returns an empty policy that strips all markup and keeps text
+func NewPolicy() *Policy {
+	return &Policy{
+		elements:   make(map[string]bool),
+		attrs:      make(map[string]map[string]bool),
+		urlSchemes: []string{"http", "https", "mailto"},
+		dropContentsOf: map[string]bool{
+			"script": true, "style": true, "iframe": true, "object": true,
+			"noscript": true, "template": true, "textarea": true, "title": true,
+			"xmp": true, "noembed": true, "noframes": true, "plaintext": true,
+		},
+	}
+}
+
+// UGCPolicy returns a policy for typical user-generated content: basic
+// formatting, lists, quotes, code, links and images
+func UGCPolicy() *Policy {
+	p := NewPolicy()
+	p.AllowElements("p", "br", "b", "i", "em", "strong", "u", "s", "sub", "sup",
+		"ul", "ol", "li", "blockquote", "code", "pre", "hr",
+		"h1", "h2", "h3", "h4", "h5", "h6", "span", "div", "a", "img")
+	p.AllowAttrs("href", "title").OnElements("a")
+	p.AllowAttrs("src", "alt", "width", "height").OnElements("img")
+	p.AllowAttrs("title").Globally()
+	p.AllowRelativeURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	return p
+}
+
+// AllowElements permits the named elements, without attributes
+func (p *Policy) AllowElements(names ...string) *Policy {
+	for _, name := range names {
+		p.elements[strings.ToLower(name)] = true
+	}
+	return p
+}
+
+// AllowURLSchemes replaces the schemes permitted in URL attributes
+func (p *Policy) AllowURLSchemes(schemes ...string) *Policy {
+	p.urlSchemes = p.urlSchemes[:0]
+	for _, s := range schemes {
+		p.urlSchemes = append(p.urlSchemes, strings.ToLower(s))
+	}
+	return p
+}
+
+// AllowRelativeURLs permits URL attributes without a scheme
+func (p *Policy) AllowRelativeURLs(allow bool) *Policy {
+	p.allowRelative = allow
+	return p
+}
+
+// RequireParseableURLs makes kept URL attributes the net/url
+// re-serialization of what was checked. Unparseable URLs are dropped
+// either way, since their scheme cannot be checked.
+func (p *Policy) RequireParseableURLs(require bool) *Policy {
+	p.requireParse = require
+	return p
+}
+
+// RequireNoFollowOnLinks adds rel="nofollow" to every kept <a href>
+func (p *Policy) RequireNoFollowOnLinks(require bool) *Policy {
+	p.addNoFollow = require
+	return p
+}
+
+// AttrBuilder completes an AllowAttrs call
+type AttrBuilder struct {
+	policy *Policy
+	names  []string
+}
+
+// AllowAttrs starts allowing attributes; finish with OnElements or Globally
+func (p *Policy) AllowAttrs(names ...string) *AttrBuilder {
+	return &AttrBuilder{policy: p, names: names}
+}
+
+// OnElements allows the attributes on the given elements, and allows the
+// elements themselves
+func (b *AttrBuilder) OnElements(elements ...string) *Policy {
+	for _, el := range elements {
+		b.allow(strings.ToLower(el))
+		b.policy.elements[strings.ToLower(el)] = true
+	}
+	return b.policy
+}
+
+// Globally allows the attributes on every allowed element
+func (b *AttrBuilder) Globally() *Policy {
+	b.allow("")
+	return b.policy
+}
+
+func (b *AttrBuilder) allow(element string) {
+	attrs := b.policy.attrs[element]
+	if attrs == nil {
+		attrs = make(map[string]bool)
+		b.policy.attrs[element] = attrs
+	}
+	for _, name := range b.names {
+		name = strings.ToLower(name)
+		// Event handlers and inline styles are never allowed
+		if strings.HasPrefix(name, "on") || name == "style" {
+			continue
+		}
+		attrs[name] = true
+	}
+}
+
+func (p *Policy) attrAllowed(element, attr string) bool {
+	return p.attrs[element][attr] || p.attrs[""][attr]
+}
+
+// Sanitize returns s with everything not allowed by the policy removed.
+// The output is well-formed: allowed elements are closed in order and all
+// text and attribute values are re-encoded.
+func (p *Policy) Sanitize(s string) string {
+	var out strings.Builder
+	var open []string
+	skipUntil := ""
+
+	for _, tok := range tokenize(s) {
+		if skipUntil != "" {
+			if tok.kind == endTagToken && tok.name == skipUntil {
+				skipUntil = ""
+			}
+			continue
+		}
+
+		switch tok.kind {
+		case textToken:
+			out.WriteString(HTMLBody(html.UnescapeString(tok.data)))
+
+		case startTagToken:
+			if p.dropContentsOf[tok.name] {
+				if !tok.selfClosing {
+					skipUntil = tok.name
+				}
+				continue
+			}
+			if !p.elements[tok.name] {
+				continue
+			}
+			p.writeStartTag(&out, tok)
+			if !voidElements[tok.name] {
+				open = append(open, tok.name)
+			}
+
+		case endTagToken:
+			i := slices.Index(open, tok.name)
+			if i < 0 {
+				continue
+			}
+			// Close anything left open inside this element as well
+			for j := len(open) - 1; j >= i; j-- {
+				out.WriteString("</" + open[j] + ">")
+			}
+			open = open[:i]
+		}
+	}
+
+	for j := len(open) - 1; j >= 0; j-- {
+		out.WriteString("</" + open[j] + ">")
+	}
+	return out.String()
+}
+
+func (p *Policy) writeStartTag(out *strings.Builder, tok token) {
+	out.WriteString("<" + tok.name)
+	hasHref := false
+	seen := make(map[string]bool)
+	for _, a := range tok.attrs {
+		if seen[a.name] || !p.attrAllowed(tok.name, a.name) {
+			continue
+		}
+		seen[a.name] = true
+
+		value := html.UnescapeString(a.value)
+		if urlAttrs[a.name] {
+			var ok bool
+			if value, ok = p.checkURL(value); !ok {
+				continue
+			}
+			hasHref = hasHref || a.name == "href"
+		}
+		if a.name == "rel" && p.addNoFollow && tok.name == "a" {
+			continue
+		}
+		out.WriteString(" " + a.name + `="` + HTMLBody(value) + `"`)
+	}
+	if p.addNoFollow && tok.name == "a" && hasHref {
+		out.WriteString(` rel="nofollow"`)
+	}
+	out.WriteString(">")
+}
+
+// checkURL applies the scheme allow-list to a URL attribute value. Control
+// characters and whitespace are stripped first, as browsers ignore them
+// ("java\tscript:" is still javascript:).
+func (p *Policy) checkURL(value string) (string, bool) {
+	value = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, value)
+
+	u, err := url.Parse(value)
+	if err != nil {
+		// The scheme of a URL we cannot parse cannot be checked either
+		return "", false
+	}
+	if p.requireParse {
+		value = u.String()
+	}
+	if u.Scheme == "" {
+		return value, p.allowRelative && u.Host == ""
+	}
+	return value, slices.Contains(p.urlSchemes, strings.ToLower(u.Scheme))
+}
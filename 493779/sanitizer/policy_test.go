@@ -0,0 +1,126 @@
+package sanitizer
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+)
+
+// loadCorpus reads the adversarial inputs in testdata/xss.txt
+func loadCorpus(t testing.TB) []string {
+	t.Helper()
+	f, err := os.Open("testdata/xss.txt")
+	if err != nil {
+		t.Fatalf("Failed to open corpus: %v", err)
+	}
+	defer f.Close()
+
+	var corpus []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		corpus = append(corpus, line)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Failed to read corpus: %v", err)
+	}
+	return corpus
+}
+
+func TestPolicySanitize(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   *Policy
+		input    string
+		expected string
+	}{
+		{"Plain text", NewPolicy(), "Hello, World!", "Hello, World!"},
+		{"Text is re-encoded", NewPolicy(), "a < b && c > d", "a &lt; b &amp;&amp; c &gt; d"},
+		{"Entities are normalized", NewPolicy(), "caf&eacute; &amp; &#60;", "café &amp; &lt;"},
+		{"Tags stripped, text kept", NewPolicy(), "<b>bold</b> text", "bold text"},
+		{"Script content dropped", NewPolicy(), "a<script>alert(1)</script>b", "ab"},
+		{"Comments dropped", NewPolicy(), "a<!-- hidden -->b", "ab"},
+		{"Allowed element kept", NewPolicy().AllowElements("b"), "<B>bold</B>", "<b>bold</b>"},
+		{"Attributes need allowing", NewPolicy().AllowElements("p"), `<p class="x">t</p>`, "<p>t</p>"},
+		{"Unclosed elements closed", UGCPolicy(), "<p><b>text", "<p><b>text</b></p>"},
+		{"Misnested elements closed", UGCPolicy(), "<b><i>text</b></i>", "<b><i>text</i></b>"},
+		{"Stray end tags dropped", UGCPolicy(), "</p>text</b>", "text"},
+		{"Void elements", UGCPolicy(), "a<br>b<br/>c", "a<br>b<br>c"},
+		{"Safe link kept", UGCPolicy(), `<a href="https://example.com/?a=1&amp;b=2">x</a>`, `<a href="https://example.com/?a=1&amp;b=2" rel="nofollow">x</a>`},
+		{"Relative link kept", UGCPolicy(), `<a href="/docs">x</a>`, `<a href="/docs" rel="nofollow">x</a>`},
+		{"Protocol-relative link dropped", UGCPolicy(), `<a href="//evil.example">x</a>`, `<a>x</a>`},
+		{"Relative link needs allowing", NewPolicy().AllowAttrs("href").OnElements("a"), `<a href="/docs">x</a>`, `<a>x</a>`},
+		{"JavaScript link dropped", UGCPolicy(), `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"Encoded JavaScript link dropped", UGCPolicy(), `<a href="&#106;avascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"Whitespace-split scheme dropped", UGCPolicy(), "<a href=\"java\tscript:alert(1)\">x</a>", `<a>x</a>`},
+		{"Event handlers never allowed", NewPolicy().AllowAttrs("onclick", "title").OnElements("p"), `<p onclick="x()" title="t">y</p>`, `<p title="t">y</p>`},
+		{"Quote breakout re-encoded", UGCPolicy(), `<p title='a" onmouseover="alert(1)'>x</p>`, `<p title="a&#34; onmouseover=&#34;alert(1)">x</p>`},
+		{"Existing rel replaced", UGCPolicy(), `<a href="/x" rel="opener">x</a>`, `<a href="/x" rel="nofollow">x</a>`},
+		{"Unterminated tag dropped", UGCPolicy(), `text<a href="x`, "text"},
+		{"Lone angle bracket", NewPolicy(), "1 < 2", "1 &lt; 2"},
+		{"Raw text end tag prefix", NewPolicy(), "<script>a</scripts>b</script>c", "c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.policy.Sanitize(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestPolicyCorpus(t *testing.T) {
+	policy := UGCPolicy()
+	for _, input := range loadCorpus(t) {
+		result := policy.Sanitize(input)
+
+		for _, tok := range tokenize(result) {
+			switch tok.kind {
+			case startTagToken, endTagToken:
+				if !policy.elements[tok.name] {
+					t.Errorf("Sanitize(%q) = %q: disallowed element <%s>", input, result, tok.name)
+				}
+			}
+			for _, a := range tok.attrs {
+				if !policy.attrAllowed(tok.name, a.name) && !(a.name == "rel" && tok.name == "a") {
+					t.Errorf("Sanitize(%q) = %q: disallowed attribute %s", input, result, a.name)
+				}
+				value := strings.ToLower(a.value)
+				for _, scheme := range []string{"javascript:", "vbscript:", "data:"} {
+					if strings.Contains(value, scheme) {
+						t.Errorf("Sanitize(%q) = %q: %s URL survived", input, result, scheme)
+					}
+				}
+			}
+		}
+
+		// Sanitized output must be a fixed point of the policy
+		if again := policy.Sanitize(result); again != result {
+			t.Errorf("Sanitize is not idempotent for %q: %q then %q", input, result, again)
+		}
+	}
+}
+
+func FuzzPolicySanitize(f *testing.F) {
+	for _, input := range loadCorpus(f) {
+		f.Add(input)
+	}
+	policy := UGCPolicy()
+	f.Fuzz(func(t *testing.T, input string) {
+		result := policy.Sanitize(input)
+		if again := policy.Sanitize(result); again != result {
+			t.Errorf("Sanitize is not idempotent for %q: %q then %q", input, result, again)
+		}
+		for _, tok := range tokenize(result) {
+			if tok.kind != textToken && !policy.elements[tok.name] {
+				t.Errorf("Sanitize(%q) = %q: disallowed element <%s>", input, result, tok.name)
+			}
+		}
+	})
+}
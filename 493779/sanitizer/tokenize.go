@@ -0,0 +1,216 @@
+package sanitizer
+
+import "strings"
+
+type tokenKind int
+
+const (
+	textToken tokenKind = iota
+	startTagToken
+	endTagToken
+)
+
+type attribute struct {
+	name  string
+	value string
+}
+
+type token struct {
+	kind        tokenKind
+	name        string // lowercase tag name
+	attrs       []attribute
+	selfClosing bool
+	data        string // raw text for textToken
+}
+
+// rawTextElements hold text that is not parsed for markup until their end
+// tag, as in the HTML tokenizer's RAWTEXT and RCDATA states
+var rawTextElements = map[string]bool{
+	"script": true, "style": true, "xmp": true, "iframe": true, "noembed": true,
+	"noframes": true, "textarea": true, "title": true,
+}
+
+// tokenize splits s into text, start tag and end tag tokens. Comments,
+// doctypes and processing instructions are discarded, as is an unfinished
+// tag at the end of the input. It follows the HTML tokenizer closely enough
+// that every tag a browser would see is reported as a tag, so nothing can
+// be smuggled through as text.
+func tokenize(s string) []token {
+	var toks []token
+	text := 0 // start of pending text
+
+	flush := func(end int) {
+		if end > text {
+			toks = append(toks, token{kind: textToken, data: s[text:end]})
+		}
+	}
+
+	for i := 0; i < len(s); {
+		if s[i] != '<' || i+1 >= len(s) {
+			i++
+			continue
+		}
+
+		next := s[i+1]
+		switch {
+		case isASCIILetter(next):
+			flush(i)
+			tok, end := parseTag(s, i+1, startTagToken)
+			i, text = end, end
+			if end > len(s) {
+				return toks
+			}
+			toks = append(toks, tok)
+			if rawTextElements[tok.name] && !tok.selfClosing {
+				i = skipRawText(s, i, tok.name, &toks)
+				text = i
+			}
+
+		case next == '/' && i+2 < len(s) && isASCIILetter(s[i+2]):
+			flush(i)
+			tok, end := parseTag(s, i+2, endTagToken)
+			i, text = end, end
+			if end > len(s) {
+				return toks
+			}
+			toks = append(toks, tok)
+
+		case next == '!' && strings.HasPrefix(s[i:], "<!--"):
+			flush(i)
+			end := strings.Index(s[i+4:], "-->")
+			if end < 0 {
+				return toks
+			}
+			i = i + 4 + end + 3
+			text = i
+
+		case next == '!' || next == '?' || next == '/':
+			// Doctype, bogus comment or "</>": discarded up to the next ">"
+			flush(i)
+			end := strings.IndexByte(s[i:], '>')
+			if end < 0 {
+				return toks
+			}
+			i += end + 1
+			text = i
+
+		default:
+			i++
+		}
+	}
+	flush(len(s))
+	return toks
+}
+
+// skipRawText emits the content of a raw text element as a single text
+// token and returns the position of its end tag
+func skipRawText(s string, i int, name string, toks *[]token) int {
+	lower := asciiLower(s[i:])
+	end := strings.Index(lower, "</"+name)
+	for end >= 0 {
+		// The end tag name must not continue, "</scripts" does not count
+		after := i + end + 2 + len(name)
+		if after >= len(s) || !isTagNameChar(s[after]) {
+			break
+		}
+		next := strings.Index(lower[end+1:], "</"+name)
+		if next < 0 {
+			end = -1
+			break
+		}
+		end += next + 1
+	}
+	if end < 0 {
+		end = len(s) - i
+	}
+	if end > 0 {
+		*toks = append(*toks, token{kind: textToken, data: s[i : i+end]})
+	}
+	return i + end
+}
+
+// parseTag parses the tag whose name starts at s[i]. It returns a position
+// past the end of the input if the tag is not terminated.
+func parseTag(s string, i int, kind tokenKind) (token, int) {
+	tok := token{kind: kind}
+	start := i
+	for i < len(s) && isTagNameChar(s[i]) {
+		i++
+	}
+	tok.name = asciiLower(s[start:i])
+
+	for {
+		for i < len(s) && (isSpace(s[i]) || s[i] == '/') {
+			if s[i] == '/' && i+1 < len(s) && s[i+1] == '>' {
+				tok.selfClosing = true
+			}
+			i++
+		}
+		if i >= len(s) {
+			return tok, len(s) + 1
+		}
+		if s[i] == '>' {
+			return tok, i + 1
+		}
+
+		// Attribute name
+		start = i
+		i++ // the first character may be "=", as in the HTML tokenizer
+		for i < len(s) && !isSpace(s[i]) && s[i] != '/' && s[i] != '>' && s[i] != '=' {
+			i++
+		}
+		attr := attribute{name: asciiLower(s[start:i])}
+
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isSpace(s[i]) {
+				i++
+			}
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				quote := s[i]
+				end := strings.IndexByte(s[i+1:], quote)
+				if end < 0 {
+					return tok, len(s) + 1
+				}
+				attr.value = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start = i
+				for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				attr.value = s[start:i]
+			}
+		}
+		if kind == startTagToken {
+			tok.attrs = append(tok.attrs, attr)
+		}
+	}
+}
+
+// asciiLower lowercases ASCII letters only, so that byte offsets into the
+// result stay valid for s even when s is not valid UTF-8
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isTagNameChar(c byte) bool {
+	return !isSpace(c) && c != '/' && c != '>'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a CircuitBreaker
+type BreakerState int
+
+const (
+	// StateClosed lets calls through and counts their failures
+	StateClosed BreakerState = iota
+	// StateOpen rejects calls with ErrCircuitOpen until the cool-down ends
+	StateOpen
+	// StateHalfOpen lets a few probe calls through to test the dependency
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// ErrCircuitOpen is returned, wrapped with the breaker's name, for calls
+// rejected without reaching the dependency. It is not retryable, so a
+// RetryPolicy wrapped around a breaker stops at once instead of waiting
+// out its attempts.
+var ErrCircuitOpen error = circuitOpenError{}
+
+type circuitOpenError struct{}
+
+func (circuitOpenError) Error() string   { return "circuit breaker is open" }
+func (circuitOpenError) Retryable() bool { return false }
+
+// BreakerEvent reports a state change of a CircuitBreaker
+type BreakerEvent struct {
+	Name string
+	From BreakerState
+	To   BreakerState
+	At   time.Time
+	Err  error // the failure that tripped the breaker, if any
+}
+
+// CircuitBreaker stops calls to a failing dependency so that it is not
+// flooded with retries while it recovers. It trips open after
+// ConsecutiveFailures failures in a row or, once MinRequests calls have
+// been seen in the current Window, when the share of failures reaches
+// FailureRatio. After CoolDown it lets Probes calls through; if they all
+// succeed it closes again, otherwise it reopens.
+//
+// To count every attempt and stop retrying once it trips, put the breaker
+// inside the retry:
+//
+//	policy.Wrap(breaker.Wrap(callback))
+//
+// The zero value is ready to use; configure it before first use.
+type CircuitBreaker struct {
+	Name                string
+	ConsecutiveFailures int           // 0 means 5
+	FailureRatio        float64       // 0 disables the ratio condition
+	MinRequests         int           // 0 means 10
+	Window              time.Duration // 0 means 1 minute
+	CoolDown            time.Duration // 0 means 30 seconds
+	Probes              int           // 0 means 1
+
+	// IsFailure decides which errors count against the dependency; nil
+	// counts every error except the caller's own cancellation
+	IsFailure func(error) bool
+
+	// OnStateChange, if set, is called after every transition, outside the
+	// breaker's lock
+	OnStateChange func(BreakerEvent)
+
+	// Clock times the window and cool-down; nil means the system clock
+	Clock Clock
+
+	mu          sync.Mutex
+	state       BreakerState
+	windowStart time.Time
+	requests    int
+	failures    int
+	consecutive int
+	openedAt    time.Time
+	probing     int    // probes in flight
+	probed      int    // successful probes
+	generation  uint64 // incremented on every transition
+}
+
+// Do calls fn if the breaker allows it and records the outcome
+func (b *CircuitBreaker) Do(ctx context.Context, fn func(context.Context) error) error {
+	generation, err := b.allow()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// A panicking call is a failure; the panic goes on to the caller
+		if v := recover(); v != nil {
+			b.record(generation, fmt.Errorf("panic: %v", v))
+			panic(v)
+		}
+	}()
+	err = fn(ctx)
+	b.record(generation, err)
+	return err
+}
+
+// Wrap returns a callback for ProcessItems that goes through the breaker
+func (b *CircuitBreaker) Wrap(callback func(context.Context, string) error) func(context.Context, string) error {
+	return func(ctx context.Context, item string) error {
+		return b.Do(ctx, func(ctx context.Context) error {
+			return callback(ctx, item)
+		})
+	}
+}
+
+// State returns the current state, moving from open to half-open if the
+// cool-down has ended
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	event := b.coolDownEnded(clockOr(b.Clock).Now())
+	state := b.state
+	b.mu.Unlock()
+	b.emit(event)
+	return state
+}
+
+// allow admits a call, returning the generation it was admitted in
+func (b *CircuitBreaker) allow() (uint64, error) {
+	b.mu.Lock()
+	now := clockOr(b.Clock).Now()
+	event := b.coolDownEnded(now)
+
+	var err error
+	switch b.state {
+	case StateOpen:
+		err = fmt.Errorf("%s: %w", b.name(), ErrCircuitOpen)
+	case StateHalfOpen:
+		if b.probing+b.probed >= b.probes() {
+			err = fmt.Errorf("%s: %w", b.name(), ErrCircuitOpen)
+		} else {
+			b.probing++
+		}
+	case StateClosed:
+		if b.windowStart.IsZero() || now.Sub(b.windowStart) >= b.window() {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+	}
+	generation := b.generation
+	b.mu.Unlock()
+	b.emit(event)
+	return generation, err
+}
+
+// record counts the outcome of a call. Calls admitted before the latest
+// transition no longer say anything about the current state and are
+// ignored.
+func (b *CircuitBreaker) record(generation uint64, err error) {
+	failed := err != nil && b.isFailure(err)
+
+	b.mu.Lock()
+	now := clockOr(b.Clock).Now()
+	var event *BreakerEvent
+	if generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+	switch b.state {
+	case StateHalfOpen:
+		// An error that is not a failure, such as the caller's own
+		// cancellation, says nothing either way: it frees the probe slot
+		// for another call without counting as a success
+		b.probing--
+		if failed {
+			event = b.transition(StateOpen, now, err)
+		} else if err == nil {
+			if b.probed++; b.probed >= b.probes() {
+				event = b.transition(StateClosed, now, nil)
+			}
+		}
+	case StateClosed:
+		b.requests++
+		if failed {
+			b.failures++
+			b.consecutive++
+		} else {
+			b.consecutive = 0
+		}
+		if b.consecutive >= b.consecutiveFailures() || b.ratioExceeded() {
+			event = b.transition(StateOpen, now, err)
+		}
+	}
+	b.mu.Unlock()
+	b.emit(event)
+}
+
+func (b *CircuitBreaker) ratioExceeded() bool {
+	minRequests := b.MinRequests
+	if minRequests <= 0 {
+		minRequests = 10
+	}
+	return b.FailureRatio > 0 && b.requests >= minRequests &&
+		float64(b.failures)/float64(b.requests) >= b.FailureRatio
+}
+
+// coolDownEnded moves an open breaker to half-open once CoolDown has passed
+func (b *CircuitBreaker) coolDownEnded(now time.Time) *BreakerEvent {
+	if b.state != StateOpen || now.Sub(b.openedAt) < b.coolDown() {
+		return nil
+	}
+	return b.transition(StateHalfOpen, now, nil)
+}
+
+// transition changes state and resets the counters of the new state. The
+// caller holds b.mu and emits the returned event after releasing it.
+func (b *CircuitBreaker) transition(to BreakerState, now time.Time, cause error) *BreakerEvent {
+	event := &BreakerEvent{Name: b.name(), From: b.state, To: to, At: now, Err: cause}
+	b.state = to
+	b.generation++
+	b.probing, b.probed = 0, 0
+	b.requests, b.failures, b.consecutive = 0, 0, 0
+	b.windowStart = now
+	if to == StateOpen {
+		b.openedAt = now
+	}
+	return event
+}
+
+func (b *CircuitBreaker) emit(event *BreakerEvent) {
+	if event != nil && b.OnStateChange != nil {
+		b.OnStateChange(*event)
+	}
+}
+
+func (b *CircuitBreaker) isFailure(err error) bool {
+	if b.IsFailure != nil {
+		return b.IsFailure(err)
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+func (b *CircuitBreaker) name() string {
+	if b.Name == "" {
+		return "circuit breaker"
+	}
+	return b.Name
+}
+
+func (b *CircuitBreaker) consecutiveFailures() int {
+	if b.ConsecutiveFailures <= 0 {
+		return 5
+	}
+	return b.ConsecutiveFailures
+}
+
+func (b *CircuitBreaker) window() time.Duration {
+	if b.Window <= 0 {
+		return time.Minute
+	}
+	return b.Window
+}
+
+func (b *CircuitBreaker) coolDown() time.Duration {
+	if b.CoolDown <= 0 {
+		return 30 * time.Second
+	}
+	return b.CoolDown
+}
+
+func (b *CircuitBreaker) probes() int {
+	if b.Probes <= 0 {
+		return 1
+	}
+	return b.Probes
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	clock := NewFakeClock()
+	var events []BreakerEvent
+	breaker := &CircuitBreaker{
+		ConsecutiveFailures: 2,
+		CoolDown:            time.Minute,
+		Clock:               clock,
+		OnStateChange:       func(e BreakerEvent) { events = append(events, e) },
+	}
+	script := &Script{Clock: clock, Fail: 2}
+	call := breaker.Wrap(script.Callback)
+
+	for range 3 {
+		call(context.Background(), "item")
+	}
+	if breaker.State() != StateOpen || script.Calls() != 2 {
+		t.Fatalf("Expected the breaker to open after 2 failures, got %s after %d calls", breaker.State(), script.Calls())
+	}
+
+	clock.Advance(59 * time.Second)
+	if err := call(context.Background(), "item"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen during the cool-down, got %v", err)
+	}
+
+	clock.Advance(time.Second)
+	if err := call(context.Background(), "item"); err != nil {
+		t.Errorf("Expected the probe to succeed, got %v", err)
+	}
+	if breaker.State() != StateClosed {
+		t.Errorf("Expected the breaker to close after the probe, got %s", breaker.State())
+	}
+
+	var transitions []BreakerState
+	for _, e := range events {
+		transitions = append(transitions, e.To)
+	}
+	if len(transitions) != 3 || transitions[0] != StateOpen || transitions[1] != StateHalfOpen || transitions[2] != StateClosed {
+		t.Errorf("Expected open, half-open, closed, got %v", transitions)
+	}
+	if events[1].At.Sub(events[0].At) != time.Minute {
+		t.Errorf("Expected half-open one cool-down after opening, got %v", events[1].At.Sub(events[0].At))
+	}
+}
+
+func TestBreakerCancelledProbeIsNotASuccess(t *testing.T) {
+	clock := NewFakeClock()
+	breaker := &CircuitBreaker{ConsecutiveFailures: 1, CoolDown: time.Minute, Probes: 2, Clock: clock}
+	failure := errors.New("down")
+	breaker.Do(context.Background(), func(context.Context) error { return failure })
+	clock.Advance(time.Minute)
+
+	cancelled := func(context.Context) error { return context.Canceled }
+	for i := range 3 {
+		if err := breaker.Do(context.Background(), cancelled); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected cancelled probe %d to reach the dependency, got %v", i+1, err)
+		}
+	}
+	if breaker.State() != StateHalfOpen {
+		t.Fatalf("Expected cancelled probes to leave the breaker half-open, got %s", breaker.State())
+	}
+
+	succeed := func(context.Context) error { return nil }
+	breaker.Do(context.Background(), succeed)
+	if breaker.State() != StateHalfOpen {
+		t.Errorf("Expected one success of two probes to leave the breaker half-open, got %s", breaker.State())
+	}
+	breaker.Do(context.Background(), succeed)
+	if breaker.State() != StateClosed {
+		t.Errorf("Expected two successful probes to close the breaker, got %s", breaker.State())
+	}
+}
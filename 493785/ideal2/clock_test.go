@@ -0,0 +1,133 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// FakeClock is a Clock that only moves when Advance is called, so tests of
+// waits and timeouts run instantly and always the same way
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func NewFakeClock() *FakeClock {
+	return &FakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, when: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.ch <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d and fires the timers that are due,
+// earliest first
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].when.Before(c.timers[j].when) })
+	var pending []*fakeTimer
+	for _, t := range c.timers {
+		if t.when.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.ch <- c.now
+	}
+	c.timers = pending
+}
+
+// Timers returns the number of timers waiting to fire
+func (c *FakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// WaitForTimers waits until n timers are waiting to fire, i.e. until the
+// code under test has reached its waits, and fails the test after a
+// second of real time
+func (c *FakeClock) WaitForTimers(t *testing.T, n int) {
+	t.Helper()
+	waitFor(t, func() bool { return c.Timers() == n }, "%d pending timer(s), have %d", n, c.Timers())
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	when  time.Time
+	ch    chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, other := range t.clock.timers {
+		if other == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// waitFor polls cond until it holds, for state that goroutines reach
+// asynchronously, and fails the test after a second of real time
+func waitFor(t *testing.T, cond func() bool, format string, args ...any) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for "+format, args...)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFakeClockFiresDueTimers(t *testing.T) {
+	clock := NewFakeClock()
+	start := clock.Now()
+	early := clock.NewTimer(time.Second)
+	late := clock.NewTimer(3 * time.Second)
+
+	clock.Advance(2 * time.Second)
+	select {
+	case at := <-early.C():
+		if at.Sub(start) != 2*time.Second {
+			t.Errorf("Expected the timer to fire at +2s, got %v", at.Sub(start))
+		}
+	default:
+		t.Errorf("Expected the 1s timer to have fired")
+	}
+	select {
+	case <-late.C():
+		t.Errorf("Expected the 3s timer not to have fired yet")
+	default:
+	}
+
+	if !late.Stop() {
+		t.Errorf("Expected Stop to report the pending timer as stopped")
+	}
+	if clock.Timers() != 0 {
+		t.Errorf("Expected no pending timers, got %d", clock.Timers())
+	}
+}
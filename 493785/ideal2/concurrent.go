@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ConcurrentOptions configures ProcessItemsConcurrent and StreamItems
+type ConcurrentOptions struct {
+	// Workers is the number of callbacks run at once; 0 means GOMAXPROCS.
+	// Network-bound callbacks usually want many more.
+	Workers int
+
+	// ItemTimeout bounds each callback through its context; 0 means none
+	ItemTimeout time.Duration
+
+	// Ordered delivers results in the order of items rather than as they
+	// complete. Later results wait for earlier slow ones.
+	Ordered bool
+
+	// CollectAll processes every item even after failures. Otherwise the
+	// first failure cancels the items still running and stops the rest.
+	CollectAll bool
+}
+
+// Result is the outcome of the callback for one item
+type Result struct {
+	Index int
+	Item  string
+	Err   error
+}
+
+// ItemError is the failure of the item at Index
+type ItemError struct {
+	Index int
+	Item  string
+	Err   error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("error processing item %d (%s): %v", e.Index, e.Item, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// ProcessItemsConcurrent is ProcessItems with bounded parallelism. Without
+// CollectAll it returns the first *ItemError; with it, it returns every
+// failure as an errors.Join of *ItemError in item order, or nil. Callback
+// panics are recovered into *PanicError rather than crashing the process.
+func ProcessItemsConcurrent(ctx context.Context, items []string, callback func(context.Context, string) error, opts ConcurrentOptions) error {
+	var errs []error
+	results := StreamItems(ctx, items, callback, opts)
+	for r := range results {
+		if r.Err == nil {
+			continue
+		}
+		err := &ItemError{Index: r.Index, Item: r.Item, Err: r.Err}
+		if !opts.CollectAll {
+			// The stream has cancelled the other items; let them finish
+			go func() {
+				for range results {
+				}
+			}()
+			return err
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return ctx.Err()
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].(*ItemError).Index < errs[j].(*ItemError).Index
+	})
+	return errors.Join(errs...)
+}
+
+// StreamItems runs callback over items concurrently and delivers one Result
+// per item processed on the returned channel, which is closed when all are
+// done. The caller must drain the channel. Without CollectAll, items not
+// yet started when one fails get no Result, nor do those cut short with
+// context.Canceled by the cancellation that failure causes.
+func StreamItems(ctx context.Context, items []string, callback func(context.Context, string) error, opts ConcurrentOptions) <-chan Result {
+	out := make(chan Result)
+	go func() {
+		defer close(out)
+		ctx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+
+		workers := opts.Workers
+		if workers <= 0 {
+			workers = runtime.GOMAXPROCS(0)
+		}
+		workers = min(workers, len(items))
+
+		jobs := make(chan int)
+		results := make(chan Result)
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					results <- Result{Index: i, Item: items[i], Err: runItem(ctx, callback, items[i], opts.ItemTimeout)}
+				}
+			}()
+		}
+		go func() {
+			defer close(jobs)
+			for i := range items {
+				select {
+				case jobs <- i:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+
+		// Ordered delivery holds results back until the ones before them
+		// have been delivered
+		pending := make(map[int]Result)
+		next := 0
+		for r := range results {
+			if r.Err != nil && !opts.CollectAll {
+				// Only the first failure stops the stream; the items it
+				// interrupts are not failures of their own
+				if errors.Is(r.Err, context.Canceled) && context.Cause(ctx) == errStopped {
+					continue
+				}
+				cancel(errStopped)
+			}
+			if !opts.Ordered {
+				out <- r
+				continue
+			}
+			pending[r.Index] = r
+			for {
+				r, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				out <- r
+				next++
+			}
+		}
+
+		// After a cancellation there are gaps; deliver what is left in order
+		rest := make([]Result, 0, len(pending))
+		for _, r := range pending {
+			rest = append(rest, r)
+		}
+		sort.Slice(rest, func(i, j int) bool { return rest[i].Index < rest[j].Index })
+		for _, r := range rest {
+			out <- r
+		}
+	}()
+	return out
+}
+
+// errStopped is the cause StreamItems cancels its items with when one fails
+var errStopped = errors.New("stopped after a failure")
+
+// runItem calls callback for one item, under its own timeout if one is set
+func runItem(ctx context.Context, callback func(context.Context, string) error, item string, timeout time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return SafeCall(ctx, callback, item)
+}
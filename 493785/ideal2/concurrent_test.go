@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStreamItemsOrdered(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e", "f"}
+	// Later items finish first, so only the ordering puts them back
+	callback := func(_ context.Context, item string) error {
+		time.Sleep(time.Duration('f'-item[0]) * time.Millisecond)
+		return nil
+	}
+	var got []string
+	for r := range StreamItems(context.Background(), items, callback, ConcurrentOptions{Workers: len(items), Ordered: true}) {
+		got = append(got, fmt.Sprintf("%d:%s", r.Index, r.Item))
+	}
+	expected := "0:a 1:b 2:c 3:d 4:e 5:f"
+	if strings.Join(got, " ") != expected {
+		t.Errorf("Expected %s, got %s", expected, strings.Join(got, " "))
+	}
+}
+
+func TestProcessItemsConcurrentReportsFirstFailure(t *testing.T) {
+	boom := errors.New("boom")
+	for _, ordered := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ordered=%v", ordered), func(t *testing.T) {
+			// "slow" is still running when "fail" fails and is cancelled
+			// by it; the cancellation is not the error to report
+			started := make(chan struct{})
+			callback := func(ctx context.Context, item string) error {
+				if item == "slow" {
+					close(started)
+					<-ctx.Done()
+					return ctx.Err()
+				}
+				<-started
+				return boom
+			}
+			err := ProcessItemsConcurrent(context.Background(), []string{"slow", "fail"}, callback, ConcurrentOptions{Workers: 2, Ordered: ordered})
+			var itemErr *ItemError
+			if !errors.As(err, &itemErr) || itemErr.Index != 1 || !errors.Is(err, boom) {
+				t.Errorf("Expected the failure of item 1, got %v", err)
+			}
+		})
+	}
+}
+
+func TestProcessItemsConcurrentCollectAll(t *testing.T) {
+	var mu sync.Mutex
+	var calls int
+	callback := func(_ context.Context, item string) error {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		if item == "b" || item == "d" {
+			return fmt.Errorf("bad %s", item)
+		}
+		return nil
+	}
+	err := ProcessItemsConcurrent(context.Background(), []string{"a", "b", "c", "d", "e"}, callback, ConcurrentOptions{Workers: 3, CollectAll: true})
+	expected := "error processing item 1 (b): bad b\nerror processing item 3 (d): bad d"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, got %v", expected, err)
+	}
+	if calls != 5 {
+		t.Errorf("Expected every item to be processed, got %d calls", calls)
+	}
+
+	if err := ProcessItemsConcurrent(context.Background(), []string{"a", "c"}, callback, ConcurrentOptions{CollectAll: true}); err != nil {
+		t.Errorf("Expected nil, got %v", err)
+	}
+}
+
+func TestProcessItemsConcurrentItemTimeout(t *testing.T) {
+	callback := func(ctx context.Context, item string) error {
+		if item == "slow" {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}
+	ctx := context.Background()
+	err := ProcessItemsConcurrent(ctx, []string{"fast", "slow", "fast"}, callback, ConcurrentOptions{ItemTimeout: 10 * time.Millisecond, CollectAll: true})
+	var itemErr *ItemError
+	if !errors.As(err, &itemErr) || itemErr.Index != 1 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected item 1 to time out, got %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Errorf("Expected the caller's context to be left alone, got %v", ctx.Err())
+	}
+}
+
+func TestProcessItemsConcurrentRecoversPanics(t *testing.T) {
+	callback := func(_ context.Context, item string) error {
+		if item == "b" {
+			panic("bad item")
+		}
+		return nil
+	}
+	err := ProcessItemsConcurrent(context.Background(), []string{"a", "b", "c"}, callback, ConcurrentOptions{Workers: 2})
+	var p *PanicError
+	if !errors.As(err, &p) || p.Item != "b" || p.Value != "bad item" || len(p.Stack) == 0 {
+		t.Errorf("Expected a PanicError for b, got %v", err)
+	}
+	var itemErr *ItemError
+	if !errors.As(err, &itemErr) || itemErr.Index != 1 {
+		t.Errorf("Expected the panic as the failure of item 1, got %v", err)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DeadLetter records an item that failed for good, so it can be inspected
+// and replayed later
+type DeadLetter struct {
+	Item         string    `json:"item"`
+	Index        int       `json:"index"`
+	Error        string    `json:"error"`
+	Kind         string    `json:"kind"`
+	Attempts     int       `json:"attempts"`
+	FirstAttempt time.Time `json:"first_attempt"`
+	FailedAt     time.Time `json:"failed_at"`
+}
+
+// DeadLetterSink stores dead letters
+type DeadLetterSink interface {
+	Write(ctx context.Context, letter DeadLetter) error
+}
+
+// FileDeadLetterSink appends dead letters to a file as JSON lines. It is
+// safe for concurrent use.
+type FileDeadLetterSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenDeadLetterFile opens path for appending, creating it if needed
+func OpenDeadLetterFile(path string) (*FileDeadLetterSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileDeadLetterSink{file: f}, nil
+}
+
+// Write appends letter and syncs it to disk, so that a crash right after
+// does not lose it
+func (s *FileDeadLetterSink) Write(_ context.Context, letter DeadLetter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+func (s *FileDeadLetterSink) Close() error {
+	return s.file.Close()
+}
+
+// ReadDeadLetters reads the dead letters written to path
+func ReadDeadLetters(path string) ([]DeadLetter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		letters = append(letters, letter)
+	}
+	return letters, scanner.Err()
+}
+
+// Checkpoint records how far a run over a list of items got
+type Checkpoint struct {
+	Next     int    `json:"next"`      // index of the first item not yet handled
+	LastItem string `json:"last_item"` // items[Next-1], to detect a changed list
+}
+
+// CheckpointStore persists the Checkpoint of a run
+type CheckpointStore interface {
+	// Load returns the saved checkpoint, or the zero Checkpoint if none
+	Load(ctx context.Context) (Checkpoint, error)
+	Save(ctx context.Context, cp Checkpoint) error
+}
+
+// FileCheckpointStore keeps the checkpoint in a JSON file, replaced
+// atomically on every save
+type FileCheckpointStore struct {
+	Path string
+}
+
+func (s FileCheckpointStore) Load(context.Context) (Checkpoint, error) {
+	var cp Checkpoint
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return cp, err
+	}
+	err = json.Unmarshal(data, &cp)
+	return cp, err
+}
+
+func (s FileCheckpointStore) Save(_ context.Context, cp Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+// ResumeOptions configures ProcessItemsResumable
+type ResumeOptions struct {
+	// DeadLetters receives the items that fail. When nil, the run stops at
+	// the first failure as ProcessItems does, and a rerun starts again
+	// from the failed item.
+	DeadLetters DeadLetterSink
+
+	// Checkpoints, if set, makes a rerun skip the items already handled
+	Checkpoints CheckpointStore
+}
+
+// ProcessItemsResumable is ProcessItems for long runs. Failed items are
+// sent to the dead-letter sink and processing goes on; after each item the
+// checkpoint is advanced, so a rerun over the same items after a crash or
+// cancellation resumes where the last one stopped. A run that gets through
+// every item resets the checkpoint, so the next run starts from the top.
+func ProcessItemsResumable(ctx context.Context, items []string, callback func(context.Context, string) error, opts ResumeOptions) error {
+	start := 0
+	if opts.Checkpoints != nil {
+		cp, err := opts.Checkpoints.Load(ctx)
+		if err != nil {
+			return fmt.Errorf("loading checkpoint: %w", err)
+		}
+		if cp.Next > len(items) || cp.Next > 0 && items[cp.Next-1] != cp.LastItem {
+			return fmt.Errorf("checkpoint at item %d (%s) does not match the items", cp.Next, cp.LastItem)
+		}
+		start = cp.Next
+	}
+
+	for i := start; i < len(items); i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		item := items[i]
+		first := time.Now()
+		err := SafeCall(ctx, callback, item)
+		if err != nil {
+			if opts.DeadLetters == nil || ctx.Err() != nil {
+				return fmt.Errorf("error processing item %s: %w", item, err)
+			}
+			letter := DeadLetter{
+				Item:         item,
+				Index:        i,
+				Error:        err.Error(),
+				Kind:         KindOf(err).String(),
+				Attempts:     attemptsOf(err),
+				FirstAttempt: first,
+				FailedAt:     time.Now(),
+			}
+			if werr := opts.DeadLetters.Write(ctx, letter); werr != nil {
+				return fmt.Errorf("writing dead letter for item %s (%w): %w", item, err, werr)
+			}
+		}
+		if opts.Checkpoints != nil {
+			if err := opts.Checkpoints.Save(ctx, Checkpoint{Next: i + 1, LastItem: item}); err != nil {
+				return fmt.Errorf("saving checkpoint: %w", err)
+			}
+		}
+	}
+	if opts.Checkpoints != nil {
+		if err := opts.Checkpoints.Save(ctx, Checkpoint{}); err != nil {
+			return fmt.Errorf("resetting checkpoint: %w", err)
+		}
+	}
+	return nil
+}
+
+// attemptsOf returns how many attempts a RetryPolicy made before err, or 1
+func attemptsOf(err error) int {
+	var retryErr *RetryError
+	if errors.As(err, &retryErr) {
+		return len(retryErr.Attempts)
+	}
+	return 1
+}
+
+// ReplayDeadLetters runs callback again for every dead letter in path. The
+// file is then replaced with the letters of the items that failed again,
+// so replaying until it is empty drains it. It returns how many items
+// succeeded and how many failed.
+func ReplayDeadLetters(ctx context.Context, path string, callback func(context.Context, string) error) (replayed, failed int, err error) {
+	letters, err := ReadDeadLetters(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	tmp := path + ".replay"
+	os.Remove(tmp) // left over from an interrupted replay
+	sink, err := OpenDeadLetterFile(tmp)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.Remove(tmp)
+	closed := false
+	defer func() {
+		if !closed {
+			sink.Close()
+		}
+	}()
+
+	for i, letter := range letters {
+		if err := ctx.Err(); err != nil {
+			// Keep the letters not yet replayed
+			for _, rest := range letters[i:] {
+				if err := sink.Write(ctx, rest); err != nil {
+					return replayed, failed, err
+				}
+			}
+			break
+		}
+		cbErr := SafeCall(ctx, callback, letter.Item)
+		if cbErr == nil {
+			replayed++
+			continue
+		}
+		failed++
+		letter.Error = cbErr.Error()
+		letter.Kind = KindOf(cbErr).String()
+		letter.Attempts += attemptsOf(cbErr)
+		letter.FailedAt = time.Now()
+		if err := sink.Write(ctx, letter); err != nil {
+			return replayed, failed, err
+		}
+	}
+
+	closed = true
+	if err := sink.Close(); err != nil {
+		return replayed, failed, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return replayed, failed, err
+	}
+	return replayed, failed, ctx.Err()
+}
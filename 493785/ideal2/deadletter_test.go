@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessItemsResumableWritesDeadLetters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	sink, err := OpenDeadLetterFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	retried := RetryPolicy{MaxAttempts: 2, InitialDelay: time.Millisecond}.Wrap((&Script{Fail: 10}).Callback)
+	callback := func(ctx context.Context, item string) error {
+		switch item {
+		case "flaky":
+			return retried(ctx, item)
+		case "bad":
+			return &ProcessError{Kind: KindPermanent, Item: item, Message: "rejected"}
+		}
+		return nil
+	}
+	err = ProcessItemsResumable(context.Background(), []string{"a", "flaky", "b", "bad"}, callback, ResumeOptions{DeadLetters: sink})
+	if err != nil {
+		t.Fatalf("Expected the failures to go to the sink, got %v", err)
+	}
+
+	letters, err := ReadDeadLetters(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 2 {
+		t.Fatalf("Expected 2 dead letters, got %+v", letters)
+	}
+	tests := []struct {
+		item     string
+		index    int
+		kind     string
+		attempts int
+	}{
+		{"flaky", 1, "temporary", 2},
+		{"bad", 3, "permanent", 1},
+	}
+	for i, tt := range tests {
+		l := letters[i]
+		if l.Item != tt.item || l.Index != tt.index || l.Kind != tt.kind || l.Attempts != tt.attempts {
+			t.Errorf("Expected %s at %d, %s after %d attempts, got %+v", tt.item, tt.index, tt.kind, tt.attempts, l)
+		}
+		if l.Error == "" || l.FailedAt.Before(l.FirstAttempt) {
+			t.Errorf("Expected the error and times of %s, got %+v", tt.item, l)
+		}
+	}
+
+	// Without a sink the first failure stops the run
+	err = ProcessItemsResumable(context.Background(), []string{"a", "bad", "b"}, callback, ResumeOptions{})
+	if !errors.Is(err, KindPermanent) || !strings.Contains(err.Error(), "error processing item bad") {
+		t.Errorf("Expected the failure of bad, got %v", err)
+	}
+}
+
+func TestProcessItemsResumableResumesFromCheckpoint(t *testing.T) {
+	store := FileCheckpointStore{Path: filepath.Join(t.TempDir(), "checkpoint.json")}
+	items := []string{"a", "b", "c", "d"}
+
+	// The first run is cancelled once b has been handled
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var seen []string
+	callback := func(_ context.Context, item string) error {
+		seen = append(seen, item)
+		if item == "b" {
+			cancel()
+		}
+		return nil
+	}
+	if err := ProcessItemsResumable(ctx, items, callback, ResumeOptions{Checkpoints: store}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if cp, err := store.Load(context.Background()); err != nil || cp != (Checkpoint{Next: 2, LastItem: "b"}) {
+		t.Fatalf("Expected a checkpoint after b, got %+v (%v)", cp, err)
+	}
+
+	// A changed list is refused rather than resumed at the wrong place
+	err := ProcessItemsResumable(context.Background(), []string{"x", "y", "z"}, callback, ResumeOptions{Checkpoints: store})
+	if err == nil || !strings.Contains(err.Error(), "does not match the items") {
+		t.Errorf("Expected a mismatched checkpoint to be refused, got %v", err)
+	}
+
+	seen = nil
+	if err := ProcessItemsResumable(context.Background(), items, callback, ResumeOptions{Checkpoints: store}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(seen, " ") != "c d" {
+		t.Errorf("Expected the rerun to resume at c, got %v", seen)
+	}
+	if cp, err := store.Load(context.Background()); err != nil || cp != (Checkpoint{}) {
+		t.Errorf("Expected the completed run to reset the checkpoint, got %+v (%v)", cp, err)
+	}
+
+	// So the next run starts from the top
+	seen = nil
+	if err := ProcessItemsResumable(context.Background(), items, callback, ResumeOptions{Checkpoints: store}); err != nil || len(seen) != 4 {
+		t.Errorf("Expected every item to be processed again, got %v (%v)", seen, err)
+	}
+}
+
+func TestReplayDeadLettersDrains(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	sink, err := OpenDeadLetterFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, item := range []string{"a", "b", "c"} {
+		if err := sink.Write(context.Background(), DeadLetter{Item: item, Index: i, Attempts: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink.Close()
+
+	// b fails on its first replay only
+	failures := map[string]int{"b": 1}
+	callback := func(_ context.Context, item string) error {
+		if failures[item] > 0 {
+			failures[item]--
+			return &ProcessError{Kind: KindTemporary, Item: item, Message: "still down"}
+		}
+		return nil
+	}
+	tests := []struct {
+		replayed int
+		failed   int
+		left     []string
+	}{
+		{2, 1, []string{"b"}},
+		{1, 0, nil},
+		{0, 0, nil},
+	}
+	for i, tt := range tests {
+		replayed, failed, err := ReplayDeadLetters(context.Background(), path, callback)
+		if err != nil {
+			t.Fatalf("Replay %d: %v", i+1, err)
+		}
+		if replayed != tt.replayed || failed != tt.failed {
+			t.Errorf("Replay %d: expected %d replayed and %d failed, got %d and %d", i+1, tt.replayed, tt.failed, replayed, failed)
+		}
+		letters, err := ReadDeadLetters(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var left []string
+		for _, l := range letters {
+			left = append(left, l.Item)
+		}
+		if strings.Join(left, " ") != strings.Join(tt.left, " ") {
+			t.Errorf("Replay %d: expected %v left, got %v", i+1, tt.left, left)
+		}
+		if i == 0 && (letters[0].Attempts != 2 || letters[0].Kind != "temporary") {
+			t.Errorf("Expected the failed replay to be recorded, got %+v", letters[0])
+		}
+	}
+	if _, err := os.Stat(path + ".replay"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the replay file to be removed, got %v", err)
+	}
+}
+
+func TestReplayDeadLettersKeepsUnreplayedOnCancel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	sink, err := OpenDeadLetterFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range []string{"a", "b", "c"} {
+		sink.Write(context.Background(), DeadLetter{Item: item})
+	}
+	sink.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	callback := func(_ context.Context, item string) error {
+		cancel()
+		return nil
+	}
+	replayed, _, err := ReplayDeadLetters(ctx, path, callback)
+	if !errors.Is(err, context.Canceled) || replayed != 1 {
+		t.Fatalf("Expected 1 replayed before the cancellation, got %d (%v)", replayed, err)
+	}
+	letters, _ := ReadDeadLetters(path)
+	if len(letters) != 2 || letters[0].Item != "b" || letters[1].Item != "c" {
+		t.Errorf("Expected b and c to be kept, got %+v", letters)
+	}
+}
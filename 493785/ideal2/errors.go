@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// ErrorKind classifies a processing failure for retry decisions and
+// alerting. It is itself an error, so errors.Is(err, KindTemporary) asks
+// whether err is a temporary ProcessError.
+type ErrorKind int
+
+const (
+	// KindInternal is a bug or an unclassified failure
+	KindInternal ErrorKind = iota
+	// KindTemporary may go away on its own and is worth retrying
+	KindTemporary
+	// KindPermanent will fail the same way every time
+	KindPermanent
+	// KindTimeout ran out of time; it is retried like KindTemporary
+	KindTimeout
+	// KindCanceled was stopped by the caller
+	KindCanceled
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindInternal:
+		return "internal"
+	case KindTemporary:
+		return "temporary"
+	case KindPermanent:
+		return "permanent"
+	case KindTimeout:
+		return "timeout"
+	case KindCanceled:
+		return "canceled"
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(k))
+}
+
+func (k ErrorKind) Error() string {
+	return k.String()
+}
+
+// ProcessError is the failure of a callback for one item
+type ProcessError struct {
+	Kind    ErrorKind
+	Item    string // the item being processed, if known
+	Attempt int    // 1-based attempt number, 0 if unknown
+	Message string
+	Err     error          // the underlying cause, if any
+	Fields  map[string]any // extra context for logs, e.g. "status": 503
+}
+
+// Error renders the failure for humans, e.g.
+// "ProcessError: upstream refused (kind: temporary, item: a, attempt: 2, status: 503, cause: EOF)"
+func (e *ProcessError) Error() string {
+	details := []string{"kind: " + e.Kind.String()}
+	if e.Item != "" {
+		details = append(details, "item: "+e.Item)
+	}
+	if e.Attempt > 0 {
+		details = append(details, fmt.Sprintf("attempt: %d", e.Attempt))
+	}
+	for _, key := range e.fieldKeys() {
+		details = append(details, fmt.Sprintf("%s: %v", key, e.Fields[key]))
+	}
+	if e.Err != nil {
+		details = append(details, fmt.Sprintf("cause: %v", e.Err))
+	}
+	return fmt.Sprintf("ProcessError: %s (%s)", e.Message, strings.Join(details, ", "))
+}
+
+func (e *ProcessError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the error's kind, and matches timeouts and cancellations to
+// the context errors, so that errors.Is(err, context.DeadlineExceeded)
+// holds for a KindTimeout error whatever its cause.
+func (e *ProcessError) Is(target error) bool {
+	switch target {
+	case context.DeadlineExceeded:
+		return e.Kind == KindTimeout
+	case context.Canceled:
+		return e.Kind == KindCanceled
+	}
+	kind, ok := target.(ErrorKind)
+	return ok && kind == e.Kind
+}
+
+// As extracts the kind: errors.As(err, &kind) with a kind ErrorKind
+func (e *ProcessError) As(target any) bool {
+	if kind, ok := target.(*ErrorKind); ok {
+		*kind = e.Kind
+		return true
+	}
+	return false
+}
+
+// Temporary reports whether the failure may go away on its own, so that
+// RetryPolicy retries it
+func (e *ProcessError) Temporary() bool {
+	return e.Kind == KindTemporary || e.Kind == KindTimeout
+}
+
+// Timeout reports whether the failure is a timeout
+func (e *ProcessError) Timeout() bool {
+	return e.Kind == KindTimeout
+}
+
+// LogValue renders the error as a group of attributes for log/slog:
+//
+//	logger.Error("processing failed", "error", err)
+//
+// logs kind, item, attempt, message, cause and the fields separately.
+func (e *ProcessError) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("kind", e.Kind.String()),
+		slog.String("message", e.Message),
+	}
+	if e.Item != "" {
+		attrs = append(attrs, slog.String("item", e.Item))
+	}
+	if e.Attempt > 0 {
+		attrs = append(attrs, slog.Int("attempt", e.Attempt))
+	}
+	if e.Err != nil {
+		attrs = append(attrs, slog.String("cause", e.Err.Error()))
+	}
+	if len(e.Fields) > 0 {
+		fields := make([]any, 0, len(e.Fields))
+		for _, key := range e.fieldKeys() {
+			fields = append(fields, slog.Any(key, e.Fields[key]))
+		}
+		attrs = append(attrs, slog.Group("fields", fields...))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func (e *ProcessError) fieldKeys() []string {
+	keys := make([]string, 0, len(e.Fields))
+	for key := range e.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// KindOf classifies any error: panics are KindInternal, otherwise it is the
+// kind of the first ProcessError in the chain, or else a kind inferred from
+// context errors and from Temporary() and Retryable() methods.
+// Unclassified errors are KindInternal.
+func KindOf(err error) ErrorKind {
+	var kind ErrorKind
+	var p *PanicError
+	switch {
+	case errors.As(err, &p):
+		return KindInternal
+	case errors.As(err, &kind):
+		return kind
+	case errors.Is(err, context.DeadlineExceeded):
+		return KindTimeout
+	case errors.Is(err, context.Canceled):
+		return KindCanceled
+	}
+	var r interface{ Retryable() bool }
+	if errors.As(err, &r) {
+		if r.Retryable() {
+			return KindTemporary
+		}
+		return KindPermanent
+	}
+	var t interface{ Temporary() bool }
+	if errors.As(err, &t) && t.Temporary() {
+		return KindTemporary
+	}
+	return KindInternal
+}
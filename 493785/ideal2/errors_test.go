@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessErrorIs(t *testing.T) {
+	tests := []struct {
+		kind     ErrorKind
+		target   error
+		expected bool
+	}{
+		{KindTemporary, KindTemporary, true},
+		{KindTemporary, KindPermanent, false},
+		{KindTimeout, context.DeadlineExceeded, true},
+		{KindTemporary, context.DeadlineExceeded, false},
+		{KindCanceled, context.Canceled, true},
+		{KindTimeout, context.Canceled, false},
+		{KindPermanent, io.EOF, true}, // through the cause
+		{KindPermanent, io.ErrUnexpectedEOF, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s is %v", tt.kind, tt.target), func(t *testing.T) {
+			err := &ProcessError{Kind: tt.kind, Message: "failed", Err: io.EOF}
+			if got := errors.Is(err, tt.target); got != tt.expected {
+				t.Errorf("Expected errors.Is = %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestProcessErrorAs(t *testing.T) {
+	cause := &PanicError{Item: "a", Value: "boom"}
+	var err error = fmt.Errorf("wrapped: %w", &ProcessError{Kind: KindTimeout, Item: "a", Message: "slow", Err: cause})
+
+	var kind ErrorKind
+	if !errors.As(err, &kind) || kind != KindTimeout {
+		t.Errorf("Expected the kind timeout, got %v", kind)
+	}
+	var p *ProcessError
+	if !errors.As(err, &p) || p.Item != "a" {
+		t.Errorf("Expected the ProcessError, got %v", err)
+	}
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr != cause {
+		t.Errorf("Expected the cause through Unwrap, got %v", panicErr)
+	}
+	if errors.Unwrap(p) != cause {
+		t.Errorf("Expected Unwrap to return the cause, got %v", errors.Unwrap(p))
+	}
+	if !p.Temporary() || !p.Timeout() {
+		t.Errorf("Expected a timeout to be temporary")
+	}
+}
+
+func TestProcessErrorFormat(t *testing.T) {
+	err := &ProcessError{
+		Kind:    KindTemporary,
+		Item:    "a",
+		Attempt: 2,
+		Message: "upstream refused",
+		Err:     io.EOF,
+		Fields:  map[string]any{"status": 503, "host": "api"},
+	}
+	expected := "ProcessError: upstream refused (kind: temporary, item: a, attempt: 2, host: api, status: 503, cause: EOF)"
+	if err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	logger.Error("processing failed", "error", err)
+	expected = `level=ERROR msg="processing failed" error.kind=temporary error.message="upstream refused" error.item=a error.attempt=2 error.cause=EOF error.fields.host=api error.fields.status=503`
+	if got := strings.TrimSpace(buf.String()); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+
+	buf.Reset()
+	logger.Error("processing failed", "error", &ProcessError{Message: "bug"})
+	expected = `level=ERROR msg="processing failed" error.kind=internal error.message=bug`
+	if got := strings.TrimSpace(buf.String()); got != expected {
+		t.Errorf("Expected the empty details to be left out, got %s", got)
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected ErrorKind
+	}{
+		{"ProcessError", &ProcessError{Kind: KindPermanent}, KindPermanent},
+		{"Wrapped", fmt.Errorf("x: %w", &ProcessError{Kind: KindTemporary}), KindTemporary},
+		{"Panic", &PanicError{Value: &ProcessError{Kind: KindTemporary}}, KindInternal},
+		{"Deadline", fmt.Errorf("x: %w", context.DeadlineExceeded), KindTimeout},
+		{"Canceled", context.Canceled, KindCanceled},
+		{"Retryable", retryable(true), KindTemporary},
+		{"Not retryable", retryable(false), KindPermanent},
+		{"Unclassified", io.EOF, KindInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KindOf(tt.err); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+	if s := ErrorKind(9).String(); s != "ErrorKind(9)" {
+		t.Errorf("Expected ErrorKind(9), got %s", s)
+	}
+}
+
+type retryable bool
+
+func (r retryable) Error() string   { return "retryable" }
+func (r retryable) Retryable() bool { return bool(r) }
+
+func TestProcessErrorThroughWrappers(t *testing.T) {
+	permanent := &ProcessError{Kind: KindPermanent, Message: "rejected", Err: io.EOF}
+
+	// A RetryPolicy wraps the attempts in a *RetryError
+	retried := RetryPolicy{MaxAttempts: 2, InitialDelay: time.Millisecond}.Wrap((&Script{Fail: 10}).Callback)
+	err := retried(context.Background(), "a")
+	var retryErr *RetryError
+	var p *ProcessError
+	var kind ErrorKind
+	if !errors.As(err, &retryErr) || !errors.As(err, &p) || !errors.As(err, &kind) {
+		t.Fatalf("Expected a RetryError of ProcessErrors, got %v", err)
+	}
+	if !errors.Is(err, KindTemporary) || kind != KindTemporary || p.Attempt != 1 || KindOf(err) != KindTemporary {
+		t.Errorf("Expected the temporary first attempt, got %v", err)
+	}
+
+	// ProcessItems and the resumable runs wrap the failure of an item
+	for _, run := range []func(context.Context, []string, func(context.Context, string) error) error{
+		ProcessItems,
+		func(ctx context.Context, items []string, callback func(context.Context, string) error) error {
+			return ProcessItemsResumable(ctx, items, callback, ResumeOptions{})
+		},
+		func(ctx context.Context, items []string, callback func(context.Context, string) error) error {
+			return ProcessItemsResumable(ctx, items, callback, ResumeOptions{DeadLetters: failingSink{}})
+		},
+	} {
+		err := run(context.Background(), []string{"a"}, func(context.Context, string) error { return permanent })
+		if !errors.Is(err, permanent) || !errors.Is(err, KindPermanent) || !errors.Is(err, io.EOF) || !errors.As(err, &p) || p != permanent {
+			t.Errorf("Expected the ProcessError through %v", err)
+		}
+	}
+}
+
+// failingSink refuses every dead letter
+type failingSink struct{}
+
+func (failingSink) Write(context.Context, DeadLetter) error {
+	return errors.New("disk full")
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// hedgeSamples is how many recent latencies a Hedge keeps for its estimate
+const hedgeSamples = 100
+
+// Hedge cuts tail latency against replicated backends: when a call has not
+// succeeded after a delay, a second attempt of the same callback is started
+// alongside it. The first success wins and the other attempt is cancelled
+// through its context. A failure does not trigger the hedge; put a
+// RetryPolicy around the Hedge for that:
+//
+//	policy.Wrap(hedge.Wrap(callback))
+//
+// Hedging doubles the load on slow items, so the callback must be safe to
+// run twice for the same item. The zero value hedges at the p95 latency of
+// recent calls.
+type Hedge struct {
+	// Delay before the second attempt; 0 means the p95 of the latencies of
+	// recent successful calls
+	Delay time.Duration
+
+	// InitialDelay is used instead of the estimate until MinSamples calls
+	// have succeeded; 0 means 100ms
+	InitialDelay time.Duration
+	MinSamples   int // 0 means 20
+
+	// OnHedge, if set, is called when the second attempt is started
+	OnHedge func(item string, delay time.Duration)
+
+	// Clock times the delay and the latencies; nil means the system clock
+	Clock Clock
+
+	mu        sync.Mutex
+	latencies []time.Duration // ring buffer of the last hedgeSamples
+	next      int
+}
+
+// Wrap returns a callback for ProcessItems that hedges slow calls
+func (h *Hedge) Wrap(callback func(context.Context, string) error) func(context.Context, string) error {
+	return func(ctx context.Context, item string) error {
+		return h.Do(ctx, item, callback)
+	}
+}
+
+// Do calls callback for item, hedging it if it is slow. It returns nil as
+// soon as either attempt succeeds; if both fail it returns the first
+// failure. If ctx is done first, its error is returned without waiting for
+// callbacks that ignore it.
+func (h *Hedge) Do(ctx context.Context, item string, callback func(context.Context, string) error) error {
+	callCtx, cancel := context.WithCancel(ctx)
+	defer cancel() // cancels the attempt that lost
+
+	clock := clockOr(h.Clock)
+	start := clock.Now()
+	results := make(chan error, 2) // buffered so the loser can still finish
+	launch := func() {
+		go func() {
+			results <- SafeCall(callCtx, callback, item)
+		}()
+	}
+
+	delay := h.delay()
+	timer := clock.NewTimer(delay)
+	defer timer.Stop()
+
+	launch()
+	running, hedged := 1, false
+	var firstErr error
+	for {
+		select {
+		case err := <-results:
+			running--
+			if err == nil {
+				h.observe(clock.Now().Sub(start))
+				return nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			if running == 0 {
+				return firstErr
+			}
+		case <-timer.C():
+			if !hedged {
+				hedged = true
+				running++
+				if h.OnHedge != nil {
+					h.OnHedge(item, delay)
+				}
+				launch()
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// delay returns how long to wait before hedging
+func (h *Hedge) delay() time.Duration {
+	if h.Delay > 0 {
+		return h.Delay
+	}
+	minSamples := h.MinSamples
+	if minSamples <= 0 {
+		minSamples = 20
+	}
+
+	h.mu.Lock()
+	sorted := append([]time.Duration(nil), h.latencies...)
+	h.mu.Unlock()
+	if len(sorted) < minSamples {
+		if h.InitialDelay > 0 {
+			return h.InitialDelay
+		}
+		return 100 * time.Millisecond
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)*95/100]
+}
+
+// observe records the latency of a successful call, measured from the
+// start of Do so that a hedge that wins does not hide a slow backend
+func (h *Hedge) observe(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.latencies) < hedgeSamples {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+	h.latencies[h.next] = latency
+	h.next = (h.next + 1) % hedgeSamples
+}
+
+// Fallback returns a callback that calls primary and, when it fails with a
+// permanent error (see KindOf), calls alternate for the same item instead,
+// e.g. a secondary backend or a degraded answer from a cache. Temporary
+// failures are returned as they are, for a RetryPolicy to retry. An open
+// CircuitBreaker counts as permanent, so
+//
+//	Fallback(breaker.Wrap(primary), alternate)
+//
+// serves from alternate while the primary's breaker is open.
+func Fallback(primary, alternate func(context.Context, string) error) func(context.Context, string) error {
+	return func(ctx context.Context, item string) error {
+		err := SafeCall(ctx, primary, item)
+		if err == nil || KindOf(err) != KindPermanent {
+			return err
+		}
+		if altErr := SafeCall(ctx, alternate, item); altErr != nil {
+			return fmt.Errorf("fallback for item %s failed: %w (primary: %w)", item, altErr, err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHedgeFirstSuccessWins(t *testing.T) {
+	clock := NewFakeClock()
+	script := &Script{Clock: clock, BlockFirst: 1}
+	hedge := &Hedge{Delay: 50 * time.Millisecond, Clock: clock}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- hedge.Do(context.Background(), "item", script.Callback)
+	}()
+	clock.WaitForTimers(t, 1)
+	clock.Advance(50 * time.Millisecond)
+
+	if err := <-result; err != nil {
+		t.Fatalf("Expected the hedge to succeed, got %v", err)
+	}
+	if offsets := script.Offsets(); len(offsets) != 2 || offsets[1] != 50*time.Millisecond {
+		t.Errorf("Expected a second call after 50ms, got calls at %v", offsets)
+	}
+	waitFor(t, func() bool { return script.Canceled() == 1 }, "the slow call to be cancelled")
+}
+
+func TestHedgeNotNeededForFastCalls(t *testing.T) {
+	clock := NewFakeClock()
+	script := &Script{Clock: clock}
+	hedge := &Hedge{Clock: clock}
+
+	if err := hedge.Do(context.Background(), "item", script.Callback); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if script.Calls() != 1 || clock.Timers() != 0 {
+		t.Errorf("Expected 1 call and no pending timer, got %d calls and %d timers", script.Calls(), clock.Timers())
+	}
+}
+
+func TestHedgeDelayEstimate(t *testing.T) {
+	hedge := &Hedge{InitialDelay: time.Second, MinSamples: 10}
+	if d := hedge.delay(); d != time.Second {
+		t.Errorf("Expected the initial delay before enough samples, got %v", d)
+	}
+	for i := 1; i <= 100; i++ {
+		hedge.observe(time.Duration(i) * time.Millisecond)
+	}
+	if d := hedge.delay(); d != 96*time.Millisecond {
+		t.Errorf("Expected the p95 of 1ms..100ms, got %v", d)
+	}
+}
+
+func TestFallbackOnPermanentError(t *testing.T) {
+	primary := &Script{Fail: 1, Err: &ProcessError{Kind: KindPermanent, Message: "gone"}}
+	alternate := &Script{}
+	if err := Fallback(primary.Callback, alternate.Callback)(context.Background(), "item"); err != nil {
+		t.Errorf("Expected the alternate to succeed, got %v", err)
+	}
+	if alternate.Calls() != 1 {
+		t.Errorf("Expected 1 alternate call, got %d", alternate.Calls())
+	}
+
+	// Temporary failures are left for a retry
+	primary = &Script{Fail: 1}
+	alternate = &Script{}
+	if err := Fallback(primary.Callback, alternate.Callback)(context.Background(), "item"); !errors.Is(err, KindTemporary) {
+		t.Errorf("Expected the temporary error, got %v", err)
+	}
+	if alternate.Calls() != 0 {
+		t.Errorf("Expected no alternate call, got %d", alternate.Calls())
+	}
+}
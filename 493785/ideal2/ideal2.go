@@ -3,23 +3,16 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"time"
 )
 
-type ProcessError struct {
-	message string
-	cause   error
-}
-
-func (e *ProcessError) Error() string {
-	if e.cause != nil {
-		return fmt.Sprintf("ProcessError: %s (cause: %v)", e.message, e.cause)
-	}
-	return fmt.Sprintf("ProcessError: %s", e.message)
-}
-
 func ProcessItems(ctx context.Context, items []string, callback func(context.Context, string) error) error {
 	for _, item := range items {
 		err := callback(ctx, item)
@@ -35,10 +28,17 @@ func ExampleCallback(ctx context.Context, item string) error {
 	switch item {
 	case "temporary-error":
 		// Simulate a temporary error that can be retried
-		return &ProcessError{message: "Temporary error occurred", cause: errors.New("retryable error")}
+		return &ProcessError{Kind: KindTemporary, Item: item, Attempt: Attempt(ctx), Message: "Temporary error occurred", Err: errors.New("retryable error")}
 	case "permanent-error":
 		// Simulate a permanent error that cannot be retried
-		return &ProcessError{message: "Permanent error occurred", cause: errors.New("non-retryable error")}
+		return &ProcessError{
+			Kind:    KindPermanent,
+			Item:    item,
+			Attempt: Attempt(ctx),
+			Message: "Permanent error occurred",
+			Err:     errors.New("non-retryable error"),
+			Fields:  map[string]any{"status": 422},
+		}
 	case "unexpected-error":
 		// Simulate an unexpected error
 		panic("Unexpected error occurred")
@@ -48,46 +48,174 @@ func ExampleCallback(ctx context.Context, item string) error {
 	}
 }
 
-func retry(ctx context.Context, fn func(context.Context) error, attempts int, delay time.Duration) error {
-	for i := 0; i < attempts; i++ {
-		err := fn(ctx)
-		if err == nil {
-			return nil
-		}
-
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			log.Printf("Retrying in %v: %v", delay, err)
-			time.Sleep(delay)
-		}
+func main() {
+	deadLetters := flag.String("dead-letters", filepath.Join(os.TempDir(), "ideal2-dead-letters.jsonl"), "JSON lines file failed items are written to")
+	checkpoint := flag.String("checkpoint", filepath.Join(os.TempDir(), "ideal2-checkpoint.json"), "file recording how far processing got")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [replay]\n", os.Args[0])
+		flag.PrintDefaults()
 	}
+	flag.Parse()
 
-	return fmt.Errorf("exhausted retry attempts")
-}
-
-func main() {
 	items := []string{"good", "temporary-error", "permanent-error", "unexpected-error", "good"}
 
 	// Set up a logger with structured output
 	logger := log.New(log.Writer(), "", log.LstdFlags|log.Lshortfile)
 
-	// Wrap the ExampleCallback with a retry mechanism
-	retryableCallback := func(ctx context.Context, item string) error {
-		return retry(ctx, func(ctx context.Context) error {
-			return ExampleCallback(ctx, item)
-		}, 3, 1*time.Second)
+	// Wrap the ExampleCallback with a retry mechanism; only temporary
+	// errors are retried
+	policy := RetryPolicy{
+		MaxAttempts:  3,
+		InitialDelay: 200 * time.Millisecond,
+		MaxDelay:     2 * time.Second,
+		Backoff:      DecorrelatedJitter,
+		MaxElapsed:   5 * time.Second,
+		OnRetry: func(attempt int, err error, delay time.Duration) {
+			logger.Printf("Attempt %d failed, retrying in %v: %v", attempt, delay, err)
+		},
+	}
+	retryableCallback := policy.Wrap(ExampleCallback)
+
+	// "replay" runs the dead letters of earlier runs through the same callback
+	if flag.Arg(0) == "replay" {
+		replayed, failed, err := ReplayDeadLetters(context.Background(), *deadLetters, retryableCallback)
+		if err != nil {
+			logger.Fatalf("Replay failed: %v", err)
+		}
+		logger.Printf("Replayed %d item(s), %d still failing in %s", replayed, failed, *deadLetters)
+		return
 	}
 
 	// Process items with retry
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	err := ProcessItems(ctx, items, retryableCallback)
+	// Panicking callbacks are recovered; the item is set aside instead of
+	// crashing the job
+	runner := &Runner{
+		Policy: Quarantine,
+		OnQuarantine: func(p *PanicError) {
+			logger.Printf("Quarantined item %s: %v", p.Item, p.Value)
+		},
+	}
+
+	err := runner.ProcessItems(ctx, items, retryableCallback)
 	if err != nil {
 		logger.Printf("Processing failed: %v", err)
 	} else {
 		logger.Println("Processing completed successfully.")
 	}
-}
\ No newline at end of file
+
+	err = runner.ProcessItems(ctx, []string{"good", "unexpected-error", "good"}, retryableCallback)
+	if err != nil {
+		logger.Printf("Processing failed: %v", err)
+	} else {
+		logger.Printf("Processing completed, quarantined: %v", runner.Quarantined())
+	}
+
+	// Independent items can run in parallel; every failure is collected
+	err = ProcessItemsConcurrent(ctx, items, ExampleCallback, ConcurrentOptions{
+		Workers:     4,
+		ItemTimeout: time.Second,
+		CollectAll:  true,
+	})
+	if err != nil {
+		logger.Printf("Concurrent processing failed:\n%v", err)
+	} else {
+		logger.Println("Concurrent processing completed successfully.")
+	}
+
+	// A breaker inside the retry stops calls to a failing downstream
+	// instead of multiplying them
+	breaker := &CircuitBreaker{
+		Name:                "downstream",
+		ConsecutiveFailures: 2,
+		CoolDown:            time.Second,
+		OnStateChange: func(e BreakerEvent) {
+			logger.Printf("Circuit %s: %s -> %s", e.Name, e.From, e.To)
+		},
+	}
+	flaky := []string{"temporary-error", "temporary-error", "good"}
+	err = ProcessItemsConcurrent(ctx, flaky, policy.Wrap(breaker.Wrap(ExampleCallback)), ConcurrentOptions{
+		Workers:    1,
+		CollectAll: true,
+	})
+	if err != nil {
+		logger.Printf("Processing behind the breaker failed:\n%v", err)
+	}
+
+	// Long runs dead-letter failed items and keep going; a rerun resumes
+	// after the last item handled
+	sink, err := OpenDeadLetterFile(*deadLetters)
+	if err != nil {
+		logger.Fatalf("Opening dead-letter file: %v", err)
+	}
+	defer sink.Close()
+	err = ProcessItemsResumable(ctx, items, retryableCallback, ResumeOptions{
+		DeadLetters: sink,
+		Checkpoints: FileCheckpointStore{Path: *checkpoint},
+	})
+	if err != nil {
+		logger.Printf("Resumable processing stopped: %v", err)
+	} else {
+		logger.Printf("Resumable processing done; failed items are in %s, run with \"replay\" to retry them", *deadLetters)
+	}
+
+	// A callback that ignores its context is abandoned at the deadline
+	// instead of hanging the worker; the leaked goroutine stays visible
+	guard := &HardTimeout{
+		Timeout: 200 * time.Millisecond,
+		OnLeakDone: func(call LeakedCall, err error) {
+			logger.Printf("Abandoned call for %s returned after %v", call.Item, time.Since(call.Started).Round(time.Millisecond))
+		},
+	}
+	hanging := func(ctx context.Context, item string) error {
+		time.Sleep(500 * time.Millisecond) // ignores ctx, like a stuck SDK call
+		return nil
+	}
+	err = guard.Do(ctx, "slow-item", hanging)
+	logger.Printf("Hanging callback: %v; still running: %d", err, len(guard.Leaked()))
+
+	// Slow calls are hedged with a second attempt, and items the primary
+	// rejects for good are served by an alternate
+	hedge := &Hedge{
+		Delay: 50 * time.Millisecond,
+		OnHedge: func(item string, delay time.Duration) {
+			logger.Printf("No answer for %s after %v, hedging", item, delay)
+		},
+	}
+	var calls atomic.Int32
+	replica := func(ctx context.Context, item string) error {
+		if calls.Add(1) == 1 {
+			// The first replica asked is stuck in its tail
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return ExampleCallback(ctx, item)
+	}
+	alternate := func(ctx context.Context, item string) error {
+		fmt.Printf("Serving %s from the fallback\n", item)
+		return nil
+	}
+	callback := Fallback(policy.Wrap(hedge.Wrap(replica)), alternate)
+	if err := ProcessItems(ctx, []string{"good", "permanent-error"}, callback); err != nil {
+		logger.Printf("Hedged processing failed: %v", err)
+	}
+
+	// Errors are classified by kind rather than by their text, and log as
+	// structured attributes
+	structured := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	for _, item := range []string{"temporary-error", "permanent-error"} {
+		err := ExampleCallback(ctx, item)
+		var perr *ProcessError
+		if errors.As(err, &perr) {
+			structured.Error("item failed", "retryable", errors.Is(err, KindTemporary), "error", perr)
+		}
+	}
+
+	// Give the abandoned call time to report back before exiting
+	time.Sleep(400 * time.Millisecond)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff selects how the delay between attempts grows
+type Backoff int
+
+const (
+	// Exponential waits InitialDelay * Multiplier^(attempt-1)
+	Exponential Backoff = iota
+	// ExponentialJitter waits a random duration up to the exponential delay
+	// ("full jitter"), so that clients failing together retry apart
+	ExponentialJitter
+	// DecorrelatedJitter waits a random duration between InitialDelay and
+	// three times the previous delay, which spreads retries like
+	// ExponentialJitter while growing more smoothly
+	DecorrelatedJitter
+)
+
+// Reasons a RetryPolicy gives up; the RetryError wraps one of them
+var (
+	ErrAttemptsExhausted = errors.New("attempts exhausted")
+	ErrRetryTimeExceeded = errors.New("maximum retry time exceeded")
+	ErrNotRetryable      = errors.New("error is not retryable")
+)
+
+// RetryPolicy decides whether and when a failed operation is attempted
+// again. The zero value makes 3 attempts with exponential backoff starting
+// at 100ms.
+type RetryPolicy struct {
+	MaxAttempts  int           // 0 means 3
+	InitialDelay time.Duration // 0 means 100ms
+	MaxDelay     time.Duration // cap on a single wait; 0 means 30s
+	Multiplier   float64       // growth per attempt; 0 means 2
+	Backoff      Backoff
+
+	// MaxElapsed bounds the total time spent, including waits. No attempt
+	// is started that would begin after it. 0 means no limit.
+	MaxElapsed time.Duration
+
+	// Retryable classifies errors; nil means IsRetryable
+	Retryable func(error) bool
+
+	// OnRetry, if set, is called before each wait, e.g. for logging
+	OnRetry func(attempt int, err error, delay time.Duration)
+
+	// Clock times the waits and MaxElapsed; nil means the system clock
+	Clock Clock
+}
+
+// RetryError is returned when a RetryPolicy gives up. It wraps the error of
+// every attempt and the reason for stopping, so errors.Is and errors.As
+// see all of them.
+type RetryError struct {
+	Attempts []error // in order, one per attempt made
+	Reason   error   // ErrAttemptsExhausted, ErrRetryTimeExceeded, ErrNotRetryable or the context's error
+}
+
+func (e *RetryError) Error() string {
+	last := e.Attempts[len(e.Attempts)-1]
+	return fmt.Sprintf("retry: %v after %d attempt(s): %v", e.Reason, len(e.Attempts), last)
+}
+
+func (e *RetryError) Unwrap() []error {
+	return append(append([]error(nil), e.Attempts...), e.Reason)
+}
+
+// Last returns the error of the final attempt
+func (e *RetryError) Last() error {
+	return e.Attempts[len(e.Attempts)-1]
+}
+
+// IsRetryable is the default classifier. An error is retried unless it is
+// a cancellation or the first error in its chain implementing Retryable()
+// bool or Temporary() bool reports false. Timeouts, context.DeadlineExceeded
+// among them, are retried: a slow attempt may well be followed by a fast
+// one. Do stops anyway once its own context is done.
+func IsRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var r interface{ Retryable() bool }
+	if errors.As(err, &r) {
+		return r.Retryable()
+	}
+	var t interface{ Temporary() bool }
+	if errors.As(err, &t) {
+		return t.Temporary()
+	}
+	return true
+}
+
+// Do calls fn until it succeeds or the policy gives up. Waits end early if
+// ctx is done. The returned error is nil or a *RetryError.
+func (p RetryPolicy) Do(ctx context.Context, fn func(context.Context) error) error {
+	p = p.withDefaults()
+	start := p.Clock.Now()
+	var attempts []error
+	var delay time.Duration
+
+	for attempt := 1; ; attempt++ {
+		err := fn(context.WithValue(ctx, attemptKey{}, attempt))
+		if err == nil {
+			return nil
+		}
+		attempts = append(attempts, err)
+
+		if ctx.Err() != nil {
+			return &RetryError{Attempts: attempts, Reason: ctx.Err()}
+		}
+		if !p.Retryable(err) {
+			return &RetryError{Attempts: attempts, Reason: ErrNotRetryable}
+		}
+		if attempt >= p.MaxAttempts {
+			return &RetryError{Attempts: attempts, Reason: ErrAttemptsExhausted}
+		}
+		delay = p.next(attempt, delay)
+		if p.MaxElapsed > 0 && p.Clock.Now().Sub(start)+delay > p.MaxElapsed {
+			return &RetryError{Attempts: attempts, Reason: ErrRetryTimeExceeded}
+		}
+
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, err, delay)
+		}
+		if err := sleep(ctx, p.Clock, delay); err != nil {
+			return &RetryError{Attempts: attempts, Reason: err}
+		}
+	}
+}
+
+type attemptKey struct{}
+
+// Attempt returns the 1-based number of the attempt RetryPolicy.Do is
+// making with ctx, or 0 outside of a retry, for ProcessError.Attempt
+func Attempt(ctx context.Context) int {
+	n, _ := ctx.Value(attemptKey{}).(int)
+	return n
+}
+
+// Wrap returns a callback for ProcessItems that retries callback per item
+func (p RetryPolicy) Wrap(callback func(context.Context, string) error) func(context.Context, string) error {
+	return func(ctx context.Context, item string) error {
+		return p.Do(ctx, func(ctx context.Context) error {
+			return callback(ctx, item)
+		})
+	}
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = 100 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 30 * time.Second
+	}
+	if p.Multiplier <= 0 {
+		p.Multiplier = 2
+	}
+	if p.Retryable == nil {
+		p.Retryable = IsRetryable
+	}
+	p.Clock = clockOr(p.Clock)
+	return p
+}
+
+// next returns the wait after the given failed attempt; prev is the
+// previous wait, which decorrelated jitter builds on
+func (p RetryPolicy) next(attempt int, prev time.Duration) time.Duration {
+	var d time.Duration
+	switch p.Backoff {
+	case DecorrelatedJitter:
+		if prev < p.InitialDelay {
+			prev = p.InitialDelay
+		}
+		upper := min(3*float64(prev), float64(p.MaxDelay))
+		d = p.InitialDelay + time.Duration(rand.Float64()*(upper-float64(p.InitialDelay)))
+	default:
+		exp := float64(p.InitialDelay) * math.Pow(p.Multiplier, float64(attempt-1))
+		d = time.Duration(min(exp, float64(p.MaxDelay)))
+		if p.Backoff == ExponentialJitter {
+			d = time.Duration(rand.Int64N(int64(d) + 1))
+		}
+	}
+	return min(d, p.MaxDelay)
+}
+
+// sleep waits for d or until ctx is done, whichever comes first
+func sleep(ctx context.Context, clock Clock, d time.Duration) error {
+	t := clock.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
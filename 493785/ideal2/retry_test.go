@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// runRetry starts policy.Wrap(script.Callback) for one item and returns
+// the channel its result arrives on
+func runRetry(ctx context.Context, policy RetryPolicy, script *Script) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- SafeCall(ctx, policy.Wrap(script.Callback), "item")
+	}()
+	return result
+}
+
+func TestRetrySucceedsAfterBackoff(t *testing.T) {
+	clock := NewFakeClock()
+	script := &Script{Clock: clock, Fail: 3}
+	policy := RetryPolicy{MaxAttempts: 4, InitialDelay: 100 * time.Millisecond, Clock: clock}
+
+	result := runRetry(context.Background(), policy, script)
+	for _, wait := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		clock.WaitForTimers(t, 1)
+		clock.Advance(wait)
+	}
+
+	if err := <-result; err != nil {
+		t.Fatalf("Expected success on the fourth attempt, got %v", err)
+	}
+	want := []time.Duration{0, 100 * time.Millisecond, 300 * time.Millisecond, 700 * time.Millisecond}
+	if got := script.Offsets(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected attempts at %v, got %v", want, got)
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	clock := NewFakeClock()
+	script := &Script{Clock: clock, Fail: 10}
+	policy := RetryPolicy{MaxAttempts: 3, InitialDelay: time.Second, MaxDelay: 1500 * time.Millisecond, Clock: clock}
+
+	result := runRetry(context.Background(), policy, script)
+	for _, wait := range []time.Duration{time.Second, 1500 * time.Millisecond} {
+		clock.WaitForTimers(t, 1)
+		clock.Advance(wait)
+	}
+
+	err := <-result
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) || !errors.Is(err, ErrAttemptsExhausted) {
+		t.Fatalf("Expected a RetryError for exhausted attempts, got %v", err)
+	}
+	if len(retryErr.Attempts) != 3 || script.Calls() != 3 {
+		t.Errorf("Expected 3 attempts, got %d errors and %d calls", len(retryErr.Attempts), script.Calls())
+	}
+	if !errors.Is(err, KindTemporary) {
+		t.Errorf("Expected the attempts to be temporary ProcessErrors, got %v", err)
+	}
+}
+
+func TestRetryStopsOnPermanentError(t *testing.T) {
+	clock := NewFakeClock()
+	permanent := &ProcessError{Kind: KindPermanent, Message: "rejected"}
+	script := &Script{Clock: clock, Fail: 10, Err: permanent}
+
+	err := <-runRetry(context.Background(), RetryPolicy{Clock: clock}, script)
+	if !errors.Is(err, ErrNotRetryable) || !errors.Is(err, permanent) {
+		t.Errorf("Expected the permanent error not to be retried, got %v", err)
+	}
+	if script.Calls() != 1 {
+		t.Errorf("Expected 1 call, got %d", script.Calls())
+	}
+}
+
+func TestRetryStopsOnPanic(t *testing.T) {
+	clock := NewFakeClock()
+	script := &Script{Clock: clock, Fail: 10, PanicOn: 2}
+
+	result := runRetry(context.Background(), RetryPolicy{MaxAttempts: 5, Clock: clock}, script)
+	clock.WaitForTimers(t, 1)
+	clock.Advance(100 * time.Millisecond)
+
+	// The panic goes through the retry to the SafeCall around it
+	err := <-result
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Errorf("Expected the panic to end the retries, got %v", err)
+	}
+	if script.Calls() != 2 {
+		t.Errorf("Expected 2 calls, got %d", script.Calls())
+	}
+}
+
+func TestRetryMaxElapsed(t *testing.T) {
+	clock := NewFakeClock()
+	script := &Script{Clock: clock, Fail: 10}
+	policy := RetryPolicy{MaxAttempts: 10, InitialDelay: time.Second, MaxElapsed: 2500 * time.Millisecond, Clock: clock}
+
+	result := runRetry(context.Background(), policy, script)
+	clock.WaitForTimers(t, 1)
+	clock.Advance(time.Second)
+
+	// The next wait of 2s would end after MaxElapsed, so there is none
+	err := <-result
+	if !errors.Is(err, ErrRetryTimeExceeded) {
+		t.Errorf("Expected ErrRetryTimeExceeded, got %v", err)
+	}
+	if script.Calls() != 2 {
+		t.Errorf("Expected 2 calls, got %d", script.Calls())
+	}
+}
+
+func TestRetryCanceledWhileWaiting(t *testing.T) {
+	clock := NewFakeClock()
+	script := &Script{Clock: clock, Fail: 10}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	result := runRetry(ctx, RetryPolicy{Clock: clock}, script)
+	clock.WaitForTimers(t, 1)
+	cancel()
+
+	err := <-result
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the wait to end with the cancellation, got %v", err)
+	}
+	if script.Calls() != 1 {
+		t.Errorf("Expected 1 call, got %d", script.Calls())
+	}
+	if clock.Timers() != 0 {
+		t.Errorf("Expected the wait's timer to be stopped, %d pending", clock.Timers())
+	}
+}
+
+func TestRetryBackoffDelays(t *testing.T) {
+	policy := RetryPolicy{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second}.withDefaults()
+	var prev time.Duration
+	var got []time.Duration
+	for attempt := 1; attempt <= 5; attempt++ {
+		prev = policy.next(attempt, prev)
+		got = append(got, prev)
+	}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected exponential delays %v, got %v", want, got)
+	}
+
+	for _, backoff := range []Backoff{ExponentialJitter, DecorrelatedJitter} {
+		policy.Backoff = backoff
+		prev = 0
+		for attempt := 1; attempt <= 20; attempt++ {
+			prev = policy.next(attempt, prev)
+			if prev < 0 || prev > policy.MaxDelay {
+				t.Errorf("Backoff %d: delay %v outside [0, %v]", backoff, prev, policy.MaxDelay)
+			}
+		}
+	}
+}
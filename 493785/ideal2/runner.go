@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError is a callback panic converted into an error
+type PanicError struct {
+	Item  string
+	Value any    // the value passed to panic
+	Stack []byte // stack trace of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic processing item %s: %v", e.Item, e.Value)
+}
+
+// Unwrap returns the panic value if it is an error, as for panic(err)
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Retryable reports false: a panic is a bug, not a transient fault
+func (e *PanicError) Retryable() bool {
+	return false
+}
+
+// PanicPolicy decides what a Runner does when a callback panics
+type PanicPolicy int
+
+const (
+	// FailFast stops processing and returns the PanicError
+	FailFast PanicPolicy = iota
+	// SkipAndRecord moves on to the next item; the panics are returned
+	// together once all items have been processed
+	SkipAndRecord
+	// Quarantine moves on to the next item and sets the panicking item
+	// aside: it is handed to Runner.OnQuarantine and skipped by every
+	// later run of the same Runner
+	Quarantine
+)
+
+// Runner runs callbacks over items like ProcessItems, but a panicking
+// callback no longer takes down the process. A Runner is safe for
+// concurrent use.
+type Runner struct {
+	Policy PanicPolicy
+
+	// OnQuarantine, if set, is called for each item quarantined, e.g. to
+	// persist it for inspection
+	OnQuarantine func(*PanicError)
+
+	mu          sync.Mutex
+	panics      []*PanicError
+	quarantined map[string]*PanicError
+}
+
+// SafeCall calls callback for item, returning a *PanicError if it panics
+func SafeCall(ctx context.Context, callback func(context.Context, string) error, item string) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Item: item, Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return callback(ctx, item)
+}
+
+// ProcessItems calls callback for each item in turn, stopping at the first
+// error as ProcessItems does. Panics are handled according to r.Policy.
+func (r *Runner) ProcessItems(ctx context.Context, items []string, callback func(context.Context, string) error) error {
+	var skipped []error
+	err := ProcessItems(ctx, items, func(ctx context.Context, item string) error {
+		if r.isQuarantined(item) {
+			return nil
+		}
+		err := SafeCall(ctx, callback, item)
+		var p *PanicError
+		if !errors.As(err, &p) {
+			return err
+		}
+
+		r.record(p)
+		switch r.Policy {
+		case SkipAndRecord:
+			skipped = append(skipped, p)
+			return nil
+		case Quarantine:
+			r.quarantine(p)
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return errors.Join(skipped...)
+}
+
+// Panics returns every panic recovered so far, in order
+func (r *Runner) Panics() []*PanicError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*PanicError(nil), r.panics...)
+}
+
+// Quarantined returns the items set aside under the Quarantine policy
+func (r *Runner) Quarantined() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	items := make([]string, 0, len(r.quarantined))
+	for _, p := range r.panics {
+		if r.quarantined[p.Item] == p {
+			items = append(items, p.Item)
+		}
+	}
+	return items
+}
+
+func (r *Runner) record(p *PanicError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.panics = append(r.panics, p)
+}
+
+func (r *Runner) quarantine(p *PanicError) {
+	r.mu.Lock()
+	if r.quarantined == nil {
+		r.quarantined = make(map[string]*PanicError)
+	}
+	r.quarantined[p.Item] = p
+	r.mu.Unlock()
+
+	if r.OnQuarantine != nil {
+		r.OnQuarantine(p)
+	}
+}
+
+func (r *Runner) isQuarantined(item string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.quarantined[item]
+	return ok
+}
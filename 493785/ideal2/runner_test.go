@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// panicOn returns a callback that panics with value for the items given
+// and records every item it is called for
+func panicOn(value any, seen *[]string, items ...string) func(context.Context, string) error {
+	return func(_ context.Context, item string) error {
+		*seen = append(*seen, item)
+		for _, bad := range items {
+			if item == bad {
+				panic(value)
+			}
+		}
+		return nil
+	}
+}
+
+func TestSafeCall(t *testing.T) {
+	var seen []string
+	err := SafeCall(context.Background(), panicOn("boom", &seen, "a"), "a")
+	var p *PanicError
+	if !errors.As(err, &p) || p.Item != "a" || p.Value != "boom" || len(p.Stack) == 0 {
+		t.Fatalf("Expected a PanicError for a, got %v", err)
+	}
+	if err.Error() != "panic processing item a: boom" || p.Retryable() {
+		t.Errorf("Expected a non-retryable panic error, got %q", err)
+	}
+
+	// panic(err) is unwrapped to err
+	err = SafeCall(context.Background(), panicOn(io.ErrUnexpectedEOF, &seen, "a"), "a")
+	if !errors.Is(err, io.ErrUnexpectedEOF) || KindOf(err) != KindInternal {
+		t.Errorf("Expected an internal error wrapping the panic value, got %v", err)
+	}
+
+	// Errors and successes pass through untouched
+	failure := errors.New("failed")
+	if err := SafeCall(context.Background(), func(context.Context, string) error { return failure }, "a"); err != failure {
+		t.Errorf("Expected %v, got %v", failure, err)
+	}
+	if err := SafeCall(context.Background(), panicOn("boom", &seen), "a"); err != nil {
+		t.Errorf("Expected nil, got %v", err)
+	}
+}
+
+func TestProcessItemsReraisesPanics(t *testing.T) {
+	var seen []string
+	defer func() {
+		if v := recover(); v != "boom" {
+			t.Errorf("Expected the panic to reach the caller, got %v", v)
+		}
+		if strings.Join(seen, " ") != "a b" {
+			t.Errorf("Expected processing to stop at b, got %v", seen)
+		}
+	}()
+	ProcessItems(context.Background(), []string{"a", "b", "c"}, panicOn("boom", &seen, "b"))
+	t.Errorf("Expected ProcessItems to panic")
+}
+
+func TestRunnerPanicPolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   PanicPolicy
+		seen     string // the items the callback was called for
+		panics   int    // the panics recorded
+		expected string // the error returned
+	}{
+		{"FailFast", FailFast, "a b", 1, "error processing item b: panic processing item b: boom"},
+		{"SkipAndRecord", SkipAndRecord, "a b c d", 2, "panic processing item b: boom\npanic processing item d: boom"},
+		{"Quarantine", Quarantine, "a b c d", 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen []string
+			runner := &Runner{Policy: tt.policy}
+			err := runner.ProcessItems(context.Background(), []string{"a", "b", "c", "d"}, panicOn("boom", &seen, "b", "d"))
+			if got := strings.Join(seen, " "); got != tt.seen {
+				t.Errorf("Expected calls for %s, got %s", tt.seen, got)
+			}
+			if (err == nil && tt.expected != "") || (err != nil && err.Error() != tt.expected) {
+				t.Errorf("Expected %q, got %v", tt.expected, err)
+			}
+			var p *PanicError
+			if err != nil && !errors.As(err, &p) {
+				t.Errorf("Expected a PanicError in %v", err)
+			}
+			if panics := runner.Panics(); len(panics) != tt.panics {
+				t.Errorf("Expected %d panics recorded, got %d", tt.panics, len(panics))
+			}
+		})
+	}
+}
+
+func TestRunnerQuarantine(t *testing.T) {
+	var quarantined []string
+	runner := &Runner{Policy: Quarantine, OnQuarantine: func(p *PanicError) {
+		quarantined = append(quarantined, p.Item)
+	}}
+	var seen []string
+	callback := panicOn("boom", &seen, "b")
+	if err := runner.ProcessItems(context.Background(), []string{"a", "b", "c"}, callback); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(quarantined, " ") != "b" || strings.Join(runner.Quarantined(), " ") != "b" {
+		t.Errorf("Expected b to be quarantined, got %v and %v", quarantined, runner.Quarantined())
+	}
+
+	// Later runs skip the quarantined item
+	seen = nil
+	if err := runner.ProcessItems(context.Background(), []string{"b", "c"}, callback); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(seen, " ") != "c" || len(runner.Panics()) != 1 {
+		t.Errorf("Expected only c to be called, got %v with %d panics", seen, len(runner.Panics()))
+	}
+}
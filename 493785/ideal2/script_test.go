@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Script is a fake callback that behaves as scripted, call by call. The
+// checks are made in this order: the call numbered PanicOn panics, the
+// first BlockFirst calls block until their context is done, and the first
+// Fail calls return Err. Every other call succeeds.
+type Script struct {
+	Clock      Clock // records the time of each call; nil means the system clock
+	PanicOn    int   // 1-based; 0 never panics
+	BlockFirst int
+	Fail       int
+	Err        error // nil means a temporary *ProcessError
+
+	mu       sync.Mutex
+	calls    []time.Time
+	canceled int
+}
+
+// Callback has the signature ProcessItems and the wrappers expect
+func (s *Script) Callback(ctx context.Context, item string) error {
+	s.mu.Lock()
+	s.calls = append(s.calls, clockOr(s.Clock).Now())
+	call := len(s.calls)
+	s.mu.Unlock()
+
+	if call == s.PanicOn {
+		panic(fmt.Sprintf("scripted panic on call %d", call))
+	}
+	if call <= s.BlockFirst {
+		<-ctx.Done()
+		s.mu.Lock()
+		s.canceled++
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+	if call <= s.Fail {
+		if s.Err != nil {
+			return s.Err
+		}
+		return &ProcessError{Kind: KindTemporary, Item: item, Attempt: Attempt(ctx), Message: fmt.Sprintf("scripted failure %d", call)}
+	}
+	return nil
+}
+
+// Calls returns the number of calls made so far
+func (s *Script) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.calls)
+}
+
+// Offsets returns the time of each call relative to the first
+func (s *Script) Offsets() []time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offsets := make([]time.Duration, len(s.calls))
+	for i, at := range s.calls {
+		offsets[i] = at.Sub(s.calls[0])
+	}
+	return offsets
+}
+
+// Canceled returns the number of blocked calls released by cancellation
+func (s *Script) Canceled() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.canceled
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TimeoutError is returned when a callback overruns its hard deadline
+type TimeoutError struct {
+	Item    string
+	Limit   time.Duration // the configured timeout
+	Elapsed time.Duration // time from the start of the call until it was abandoned
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("callback for item %s timed out after %v (limit %v)", e.Item, e.Elapsed.Round(time.Millisecond), e.Limit)
+}
+
+// Timeout reports true, as net.Error does
+func (e *TimeoutError) Timeout() bool { return true }
+
+// Temporary reports true: the next attempt may be faster
+func (e *TimeoutError) Temporary() bool { return true }
+
+// Is makes errors.Is(err, context.DeadlineExceeded) hold, so timeouts are
+// recognised the same way whether or not the callback honoured ctx
+func (e *TimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// LeakedCall is a callback that was abandoned at its deadline but has not
+// returned yet
+type LeakedCall struct {
+	Item      string
+	Started   time.Time
+	Abandoned time.Time
+}
+
+// HardTimeout enforces a deadline on callbacks that do not honour their
+// context, such as third-party SDK calls. The callback runs in its own
+// goroutine; at the deadline the caller gets a *TimeoutError at once and
+// the goroutine is left to finish on its own. Such goroutines are tracked
+// until they return, so leaks show up in Leaked instead of only in memory
+// profiles. The zero value has no deadline; set Timeout before first use.
+type HardTimeout struct {
+	Timeout time.Duration
+
+	// OnLeak, if set, is called when a call is abandoned, and OnLeakDone
+	// when an abandoned call finally returns with its result
+	OnLeak     func(LeakedCall)
+	OnLeakDone func(call LeakedCall, err error)
+
+	// Clock times the deadline; nil means the system clock. The callback's
+	// context keeps a real deadline, which a fake clock does not move.
+	Clock Clock
+
+	mu     sync.Mutex
+	leaked map[*LeakedCall]struct{}
+}
+
+// Wrap returns a callback for ProcessItems that enforces the deadline
+func (h *HardTimeout) Wrap(callback func(context.Context, string) error) func(context.Context, string) error {
+	return func(ctx context.Context, item string) error {
+		return h.Do(ctx, item, callback)
+	}
+}
+
+// Do calls callback for item and waits at most Timeout for it. The
+// callback's context is cancelled at the deadline too, for callbacks that
+// do honour it. If ctx is done first, its error is returned and the call
+// is abandoned the same way.
+func (h *HardTimeout) Do(ctx context.Context, item string, callback func(context.Context, string) error) error {
+	if h.Timeout <= 0 {
+		return SafeCall(ctx, callback, item)
+	}
+
+	clock := clockOr(h.Clock)
+	start := clock.Now()
+	callCtx, cancel := context.WithTimeout(ctx, h.Timeout)
+	done := make(chan error, 1) // buffered so an abandoned call can still finish
+	call := &LeakedCall{Item: item, Started: start}
+	var abandoned bool
+	var mu sync.Mutex
+
+	go func() {
+		err := SafeCall(callCtx, callback, item)
+		cancel()
+		mu.Lock()
+		defer mu.Unlock()
+		if abandoned {
+			h.release(call, err)
+		}
+		done <- err
+	}()
+
+	timer := clock.NewTimer(h.Timeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C():
+		err = &TimeoutError{Item: item, Limit: h.Timeout, Elapsed: clock.Now().Sub(start)}
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	mu.Lock()
+	select {
+	case result := <-done:
+		// Finished while we were giving up; nothing leaked
+		mu.Unlock()
+		cancel()
+		return result
+	default:
+	}
+	abandoned = true
+	call.Abandoned = clock.Now()
+	h.track(call)
+	mu.Unlock()
+	// Only now, so that a callback returning on cancellation is still
+	// reported as the timeout it is
+	cancel()
+	return err
+}
+
+// Leaked returns the abandoned calls still running, oldest first
+func (h *HardTimeout) Leaked() []LeakedCall {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	calls := make([]LeakedCall, 0, len(h.leaked))
+	for call := range h.leaked {
+		calls = append(calls, *call)
+	}
+	sort.Slice(calls, func(i, j int) bool { return calls[i].Started.Before(calls[j].Started) })
+	return calls
+}
+
+func (h *HardTimeout) track(call *LeakedCall) {
+	h.mu.Lock()
+	if h.leaked == nil {
+		h.leaked = make(map[*LeakedCall]struct{})
+	}
+	h.leaked[call] = struct{}{}
+	h.mu.Unlock()
+
+	if h.OnLeak != nil {
+		h.OnLeak(*call)
+	}
+}
+
+func (h *HardTimeout) release(call *LeakedCall, err error) {
+	h.mu.Lock()
+	delete(h.leaked, call)
+	h.mu.Unlock()
+
+	if h.OnLeakDone != nil {
+		h.OnLeakDone(*call, err)
+	}
+}
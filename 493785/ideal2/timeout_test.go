@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHardTimeoutAbandonsBlockedCallback(t *testing.T) {
+	clock := NewFakeClock()
+	script := &Script{Clock: clock, BlockFirst: 1}
+	leakDone := make(chan error, 1)
+	guard := &HardTimeout{
+		Timeout:    time.Minute,
+		Clock:      clock,
+		OnLeakDone: func(_ LeakedCall, err error) { leakDone <- err },
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- guard.Do(context.Background(), "item", script.Callback)
+	}()
+	clock.WaitForTimers(t, 1)
+	clock.Advance(time.Minute)
+
+	err := <-result
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a TimeoutError, got %v", err)
+	}
+	if timeoutErr.Limit != time.Minute || timeoutErr.Elapsed != time.Minute {
+		t.Errorf("Expected limit and elapsed of 1m, got %v and %v", timeoutErr.Limit, timeoutErr.Elapsed)
+	}
+
+	// The callback sees the cancellation and returns, which ends the leak
+	if err := <-leakDone; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the abandoned call to return context.Canceled, got %v", err)
+	}
+	if n := len(guard.Leaked()); n != 0 {
+		t.Errorf("Expected no leaked calls, got %d", n)
+	}
+}
+
+func TestHardTimeoutReportsLeaks(t *testing.T) {
+	release := make(chan struct{})
+	guard := &HardTimeout{Timeout: 10 * time.Millisecond}
+	ignoresCtx := func(context.Context, string) error {
+		<-release
+		return nil
+	}
+
+	for _, item := range []string{"a", "b"} {
+		if err := guard.Do(context.Background(), item, ignoresCtx); KindOf(err) != KindTimeout {
+			t.Errorf("Expected a timeout for %s, got %v", item, err)
+		}
+	}
+	leaked := guard.Leaked()
+	if len(leaked) != 2 || leaked[0].Item != "a" || leaked[1].Item != "b" {
+		t.Fatalf("Expected calls a and b to be leaked, got %+v", leaked)
+	}
+
+	close(release)
+	waitFor(t, func() bool { return len(guard.Leaked()) == 0 }, "the leaked calls to return")
+}
+
+func TestHardTimeoutPassesResultThrough(t *testing.T) {
+	script := &Script{Fail: 1}
+	guard := &HardTimeout{Timeout: time.Minute, Clock: NewFakeClock()}
+
+	if err := guard.Do(context.Background(), "item", script.Callback); !errors.Is(err, KindTemporary) {
+		t.Errorf("Expected the callback's own error, got %v", err)
+	}
+	if err := guard.Do(context.Background(), "item", script.Callback); err != nil {
+		t.Errorf("Expected success, got %v", err)
+	}
+	if n := len(guard.Leaked()); n != 0 {
+		t.Errorf("Expected no leaked calls, got %d", n)
+	}
+}
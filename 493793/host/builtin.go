@@ -0,0 +1,96 @@
+// builtin.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ExamplePlugin is the plugin from 493793/b1
+type ExamplePlugin struct{}
+
+func (p *ExamplePlugin) Process(data string) string {
+	return data + " processed"
+}
+
+// HelloPlugin greets its input, as HelloWorld in 493793/ideal2 does
+type HelloPlugin struct{}
+
+func (p *HelloPlugin) Process(data string) string {
+	return p.HelloWorld(data)
+}
+
+func (p *HelloPlugin) HelloWorld(name string) string {
+	return fmt.Sprintf("Hello, %s!", name)
+}
+
+// FuncPlugin adapts a plain function to Plugin
+type FuncPlugin func(string) string
+
+func (f FuncPlugin) Process(data string) string {
+	return f(data)
+}
+
+// PrefixPlugin puts config "text" in front of every line
+type PrefixPlugin struct {
+	text string
+}
+
+func (p *PrefixPlugin) Init(config Config) error {
+	p.text = config.String("text", "> ")
+	return nil
+}
+
+func (p *PrefixPlugin) Process(data string) string {
+	lines := strings.Split(data, "\n")
+	for i, line := range lines {
+		lines[i] = p.text + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ReplacePlugin replaces every config "old" with config "new"
+type ReplacePlugin struct {
+	replacer *strings.Replacer
+}
+
+func (p *ReplacePlugin) Init(config Config) error {
+	old := config.String("old", "")
+	if old == "" {
+		return errors.New(`config "old" is required`)
+	}
+	p.replacer = strings.NewReplacer(old, config.String("new", ""))
+	return nil
+}
+
+func (p *ReplacePlugin) Process(data string) string {
+	return p.replacer.Replace(data)
+}
+
+// RegisterBuiltins registers the plugins that ship with the host
+func RegisterBuiltins(r *Registry) {
+	r.MustRegister(Registration{Name: "example", Version: "1.0.0", New: func() Plugin { return &ExamplePlugin{} }})
+	r.MustRegister(Registration{Name: "hello", Version: "1.0.0", New: func() Plugin { return &HelloPlugin{} }, Manifest: &Manifest{
+		Format: ManifestFormat, Name: "hello", Version: "1.0.0", HostAPI: "^1.1",
+		Capabilities: []string{"process", "hello"},
+	}})
+	r.MustRegister(Registration{Name: "upper", Version: "1.0.0", New: func() Plugin { return FuncPlugin(strings.ToUpper) }})
+	r.MustRegister(Registration{Name: "lower", Version: "1.0.0", New: func() Plugin { return FuncPlugin(strings.ToLower) }})
+	r.MustRegister(Registration{Name: "trim", Version: "1.0.0", New: func() Plugin { return FuncPlugin(strings.TrimSpace) }})
+	r.MustRegister(Registration{Name: "prefix", Version: "1.0.0", New: func() Plugin { return &PrefixPlugin{} }, Manifest: &Manifest{
+		Format: ManifestFormat, Name: "prefix", Version: "1.0.0", HostAPI: "^1.0",
+		Capabilities: []string{"process", "init"},
+		Config: map[string]ConfigField{
+			"text": {Type: "string", Default: "> ", Description: "put in front of every line"},
+		},
+	}})
+	r.MustRegister(Registration{Name: "replace", Version: "1.0.0", New: func() Plugin { return &ReplacePlugin{} }, Manifest: &Manifest{
+		Format: ManifestFormat, Name: "replace", Version: "1.0.0", HostAPI: "^1.0",
+		Capabilities: []string{"process", "init"},
+		Config: map[string]ConfigField{
+			"old": {Type: "string", Required: true, Description: "the text to replace"},
+			"new": {Type: "string", Default: "", Description: "what to replace it with"},
+		},
+	}})
+}
@@ -0,0 +1,49 @@
+// conformance_test.go
+package main
+
+import (
+	"os"
+	"testing"
+
+	"snippets/493793/host/plugintest"
+)
+
+// configured makes the plugins of factory for the plugintest kit,
+// initialised with config as the host would
+func configured(factory Factory, config Config) plugintest.Factory {
+	return func() plugintest.Plugin {
+		in := &Instance{Name: "plugin", Plugin: factory()}
+		if err := initPlugin(in, config); err != nil {
+			panic(err)
+		}
+		return in.Plugin
+	}
+}
+
+func TestBuiltinsConform(t *testing.T) {
+	configs := map[string]Config{
+		"replace": {"old": "o", "new": "0"},
+		"prefix":  {"text": "» "},
+	}
+	registry := &Registry{}
+	RegisterBuiltins(registry)
+	for _, reg := range registry.List() {
+		t.Run(reg.Name, func(t *testing.T) {
+			suite := plugintest.Conformance{}
+			if reg.Name == "example" {
+				suite.Golden = "testdata/example.golden.json"
+			}
+			suite.Run(t, configured(reg.New, configs[reg.Name]))
+		})
+	}
+}
+
+func TestRemotePluginConforms(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugintest.Run(t, func() plugintest.Plugin {
+		return &RemotePlugin{Path: exe, Env: []string{"HOST_TEST_SERVE=upper"}}
+	})
+}
@@ -0,0 +1,3 @@
+module snippets/493793/host
+
+go 1.22
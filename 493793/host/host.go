@@ -0,0 +1,415 @@
+// host.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Spec selects a registered plugin and configures it. Discover reads specs
+// from a directory with one JSON file per plugin, e.g. prefix.json:
+//
+//	{"name": "prefix", "version": "1.0.0", "config": {"text": "> "}}
+//
+// A spec with Exec names an out-of-process plugin instead, run as a
+// RemotePlugin:
+//
+//	{"name": "shout", "exec": "./shout", "args": ["--fast"]}
+//
+// A spec with a Sandbox runs the plugin out of process with resource
+// limits; a built-in plugin is then served by a copy of the host.
+//
+// Manifest names the manifest of an out-of-process plugin, which Load
+// checks before starting it:
+//
+//	{"name": "shout", "exec": "./shout", "manifest": "shout.manifest.json"}
+type Spec struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"` // "" means the latest registered, or whatever the executable reports
+	Config  Config `json:"config,omitempty"`
+
+	Exec string   `json:"exec,omitempty"` // relative to the spec's directory if it contains a slash
+	Args []string `json:"args,omitempty"`
+
+	Sandbox  *Sandbox `json:"sandbox,omitempty"`
+	Manifest string   `json:"manifest,omitempty"` // relative to the spec's directory
+}
+
+// Discover reads the *.json plugin specs in dir, in file name order
+func Discover(dir string) ([]Spec, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var specs []Spec
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var spec Spec
+		if err := json.Unmarshal(data, &spec); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if spec.Name == "" {
+			return nil, fmt.Errorf("%s: plugin name is missing", path)
+		}
+		if strings.ContainsRune(spec.Exec, '/') && !filepath.IsAbs(spec.Exec) {
+			spec.Exec = filepath.Join(dir, spec.Exec)
+		}
+		if spec.Manifest != "" && !filepath.IsAbs(spec.Manifest) {
+			spec.Manifest = filepath.Join(dir, spec.Manifest)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// Instance is a loaded, initialised plugin
+type Instance struct {
+	Name    string
+	Version string
+	Plugin  Plugin
+
+	// Negotiated is what the host agreed with the plugin's manifest; nil
+	// if it has none
+	Negotiated *Negotiation
+}
+
+// Process runs the plugin on data
+func (in *Instance) Process(ctx context.Context, data string) (string, error) {
+	return process(ctx, in.Name, in.Plugin, data)
+}
+
+// Host loads plugins from a Registry and manages their lifecycle: Init in
+// dependency order, Process in chains, and Close in reverse order
+type Host struct {
+	Registry *Registry
+
+	mu     sync.Mutex
+	loaded []*Instance // in the order they were initialised
+	byName map[string]*Instance
+}
+
+func NewHost(registry *Registry) *Host {
+	return &Host{Registry: registry, byName: make(map[string]*Instance)}
+}
+
+// Load initialises the plugins in specs, and the plugins they require
+// that are not loaded yet, dependencies first. Plugins with a manifest
+// are negotiated with first (see Negotiate), and their configuration is
+// checked against it. If one fails, it and those initialised by this call
+// are closed again and none are loaded.
+func (h *Host) Load(specs []Spec) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	regs, configs, err := h.resolve(specs)
+	if err != nil {
+		return err
+	}
+	order, err := dependencyOrder(regs)
+	if err != nil {
+		return err
+	}
+
+	negotiated := make(map[string]*Negotiation)
+	for _, reg := range order {
+		n, config, err := negotiate(reg, configs[reg.Name])
+		if err != nil {
+			return err
+		}
+		negotiated[reg.Name], configs[reg.Name] = n, config
+	}
+
+	var started []*Instance
+	for _, reg := range order {
+		in := &Instance{Name: reg.Name, Version: reg.Version, Plugin: reg.New(), Negotiated: negotiated[reg.Name]}
+		// A plugin whose Init fails may hold resources all the same, such
+		// as a process it started, so it is closed with the others
+		err := initPlugin(in, configs[reg.Name])
+		if err == nil {
+			err = checkRemote(in)
+		}
+		if err == nil {
+			err = checkCapabilities(in)
+		}
+		if err != nil {
+			closeAll(append(started, in))
+			return err
+		}
+		started = append(started, in)
+	}
+	for _, in := range started {
+		h.loaded = append(h.loaded, in)
+		h.byName[in.Name] = in
+	}
+	return nil
+}
+
+// resolve looks up the registrations of specs, in order, followed by
+// those of their missing dependencies. The caller holds h.mu.
+func (h *Host) resolve(specs []Spec) ([]Registration, map[string]Config, error) {
+	var regs []Registration
+	configs := make(map[string]Config)
+	var missing []string
+	for _, spec := range specs {
+		if _, ok := h.byName[spec.Name]; ok {
+			return nil, nil, fmt.Errorf("plugin %s is already loaded", spec.Name)
+		}
+		if _, ok := configs[spec.Name]; ok {
+			return nil, nil, fmt.Errorf("plugin %s is listed twice", spec.Name)
+		}
+		reg, err := h.lookup(spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		regs = append(regs, reg)
+		configs[spec.Name] = spec.Config
+		missing = append(missing, reg.Requires...)
+	}
+
+	// Dependencies not listed are loaded at their latest version with no
+	// configuration
+	for len(missing) > 0 {
+		name := missing[0]
+		missing = missing[1:]
+		if _, ok := configs[name]; ok {
+			continue
+		}
+		if _, ok := h.byName[name]; ok {
+			continue
+		}
+		reg, err := h.Registry.Lookup(name, "")
+		if err != nil {
+			return nil, nil, fmt.Errorf("resolving dependencies: %w", err)
+		}
+		regs = append(regs, reg)
+		configs[name] = nil
+		missing = append(missing, reg.Requires...)
+	}
+	return regs, configs, nil
+}
+
+// lookup returns the registration for spec, making one for an
+// out-of-process plugin
+func (h *Host) lookup(spec Spec) (Registration, error) {
+	if spec.Manifest != "" && spec.Exec == "" {
+		return Registration{}, fmt.Errorf("plugin %s: only out-of-process plugins take a manifest file", spec.Name)
+	}
+	if spec.Exec == "" && spec.Sandbox == nil {
+		return h.Registry.Lookup(spec.Name, spec.Version)
+	}
+	path, args, version := spec.Exec, spec.Args, spec.Version
+	var requires []string
+	var manifest *Manifest
+	if spec.Manifest != "" {
+		m, err := LoadManifest(spec.Manifest)
+		if err != nil {
+			return Registration{}, err
+		}
+		switch {
+		case m.Name != spec.Name:
+			return Registration{}, fmt.Errorf("plugin %s: manifest %s is for plugin %s", spec.Name, spec.Manifest, m.Name)
+		case version != "" && CompareVersions(m.Version, version) != 0:
+			return Registration{}, fmt.Errorf("plugin %s: manifest %s is for version %s, not %s", spec.Name, spec.Manifest, m.Version, version)
+		}
+		manifest, version = m, m.Version
+	}
+	if path == "" {
+		reg, err := h.Registry.Lookup(spec.Name, spec.Version)
+		if err != nil {
+			return Registration{}, err
+		}
+		if path, err = os.Executable(); err != nil {
+			return Registration{}, err
+		}
+		args, version, requires, manifest = []string{"serve", reg.Name, reg.Version}, reg.Version, reg.Requires, reg.Manifest
+	}
+	if version == "" {
+		version = "0.0.0" // replaced with the version from the handshake
+	}
+	return Registration{
+		Name:     spec.Name,
+		Version:  version,
+		New:      func() Plugin { return &RemotePlugin{Path: path, Args: args, Sandbox: spec.Sandbox} },
+		Requires: requires,
+		Manifest: manifest,
+	}, nil
+}
+
+// dependencyOrder sorts regs so that every plugin comes after the plugins
+// it requires, keeping their order otherwise. Requirements outside regs
+// are taken to be loaded already.
+func dependencyOrder(regs []Registration) ([]Registration, error) {
+	byName := make(map[string]Registration, len(regs))
+	for _, reg := range regs {
+		byName[reg.Name] = reg
+	}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var order []Registration
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		reg, ok := byName[name]
+		if !ok || state[name] == done {
+			return nil
+		}
+		path = append(path, name)
+		if state[name] == visiting {
+			return fmt.Errorf("dependency cycle: %s", strings.Join(path, " -> "))
+		}
+		state[name] = visiting
+		for _, dep := range reg.Requires {
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		order = append(order, reg)
+		return nil
+	}
+	for _, reg := range regs {
+		if err := visit(reg.Name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+func initPlugin(in *Instance, config Config) error {
+	initializer, ok := in.Plugin.(Initializer)
+	if !ok {
+		return nil
+	}
+	if err := guard(in.Name, func() error { return initializer.Init(config) }); err != nil {
+		return fmt.Errorf("initialising plugin %s %s: %w", in.Name, in.Version, err)
+	}
+	return nil
+}
+
+// checkRemote compares what an out-of-process plugin reported in its
+// handshake with its spec, and takes its version from the handshake
+func checkRemote(in *Instance) error {
+	remote, ok := in.Plugin.(*RemotePlugin)
+	if !ok {
+		return nil
+	}
+	info := remote.Info()
+	switch {
+	case info.Name != in.Name:
+		return fmt.Errorf("plugin %s reports its name as %s", in.Name, info.Name)
+	case in.Version != "0.0.0" && CompareVersions(info.Version, in.Version) != 0:
+		return fmt.Errorf("plugin %s is version %s, not %s", in.Name, info.Version, in.Version)
+	}
+	in.Version = info.Version
+	return nil
+}
+
+// closeAll closes instances in reverse order and joins the errors
+func closeAll(instances []*Instance) error {
+	var errs []error
+	for i := len(instances) - 1; i >= 0; i-- {
+		in := instances[i]
+		closer, ok := in.Plugin.(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := guard(in.Name, closer.Close); err != nil {
+			errs = append(errs, fmt.Errorf("closing plugin %s %s: %w", in.Name, in.Version, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Loaded returns the loaded plugins in the order they were initialised
+func (h *Host) Loaded() []*Instance {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]*Instance(nil), h.loaded...)
+}
+
+// Chain returns the named loaded plugins as a chain, or all of them in
+// load order, which respects their dependencies, if no names are given
+func (h *Host) Chain(names ...string) (Chain, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(names) == 0 {
+		return append(Chain(nil), h.loaded...), nil
+	}
+	chain := make(Chain, 0, len(names))
+	for _, name := range names {
+		in, ok := h.byName[name]
+		if !ok {
+			return nil, fmt.Errorf("plugin %s is not loaded", name)
+		}
+		chain = append(chain, in)
+	}
+	return chain, nil
+}
+
+// Close closes every loaded plugin, in reverse load order
+func (h *Host) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	err := closeAll(h.loaded)
+	h.loaded = nil
+	h.byName = make(map[string]*Instance)
+	return err
+}
+
+// Chain runs plugins one after the other, each on the output of the one
+// before it
+type Chain []*Instance
+
+// StageError is the failure of one plugin in a Chain
+type StageError struct {
+	Stage   int // 0-based position in the chain
+	Plugin  string
+	Version string
+	Err     error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("chain stage %d (%s %s): %v", e.Stage, e.Plugin, e.Version, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// Process feeds data through the chain and returns the output of the last
+// plugin. It stops at the first failure with a *StageError.
+func (c Chain) Process(ctx context.Context, data string) (string, error) {
+	for i, in := range c {
+		out, err := in.Process(ctx, data)
+		if err != nil {
+			return "", &StageError{Stage: i, Plugin: in.Name, Version: in.Version, Err: err}
+		}
+		data = out
+	}
+	return data, nil
+}
+
+// Names returns the names of the plugins in the chain, in order
+func (c Chain) Names() []string {
+	names := make([]string, len(c))
+	for i, in := range c {
+		names[i] = in.Name
+	}
+	return names
+}
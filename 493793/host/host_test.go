@@ -0,0 +1,286 @@
+// host_test.go
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lifecyclePlugin records its Init and Close calls in a shared log
+type lifecyclePlugin struct {
+	name    string
+	log     *[]string
+	initErr error
+}
+
+func (p *lifecyclePlugin) Init(config Config) error {
+	*p.log = append(*p.log, "init "+p.name)
+	return p.initErr
+}
+
+func (p *lifecyclePlugin) Process(data string) string {
+	return data + "|" + p.name
+}
+
+func (p *lifecyclePlugin) Close() error {
+	*p.log = append(*p.log, "close "+p.name)
+	return nil
+}
+
+func newTestRegistry(log *[]string, deps map[string][]string) *Registry {
+	registry := &Registry{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		registry.MustRegister(Registration{
+			Name:     name,
+			Version:  "1.0.0",
+			Requires: deps[name],
+			New:      func() Plugin { return &lifecyclePlugin{name: name, log: log} },
+		})
+	}
+	return registry
+}
+
+func TestRegistryVersions(t *testing.T) {
+	registry := &Registry{}
+	for _, version := range []string{"1.2.0", "1.10.0", "1.9.1", "2.0.0-beta.1"} {
+		registry.MustRegister(Registration{Name: "upper", Version: version, New: func() Plugin { return FuncPlugin(strings.ToUpper) }})
+	}
+
+	tests := []struct {
+		name     string
+		version  string
+		expected string
+	}{
+		{"Latest", "", "2.0.0-beta.1"},
+		{"Exact", "1.9.1", "1.9.1"},
+		{"With v prefix", "v1.10.0", "1.10.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg, err := registry.Lookup("upper", tt.version)
+			if err != nil || reg.Version != tt.expected {
+				t.Errorf("Expected %s, got %s (%v)", tt.expected, reg.Version, err)
+			}
+		})
+	}
+
+	for _, version := range []string{"1.2.0", "v1.2.0", "1.2", "1.2.0+build.7"} {
+		if err := registry.Register(Registration{Name: "upper", Version: version, New: func() Plugin { return &ExamplePlugin{} }}); err == nil {
+			t.Errorf("Expected registering 1.2.0 again as %s to fail", version)
+		}
+	}
+	if _, err := registry.Lookup("upper", "3.0.0"); err == nil {
+		t.Errorf("Expected looking up a missing version to fail")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0", "1.0.0", 0},
+		{"1.2.0", "1.10.0", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.0.0+build.5", "1.0.0", 0},
+		{"1.0.0-beta.2", "1.0.0-beta.10", -1},
+		{"1.0.0-beta.10", "1.0.0-beta.rc", -1},
+		{"1.0.0-beta", "1.0.0-beta.1", -1},
+		{"1.0.0-1", "1.0.0-alpha", -1},
+		{"1.0.0-alpha.beta", "1.0.0-alpha.1", 1},
+		{"1.0.0-rc.1", "1.0.0-rc.01", 0},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.expected {
+			t.Errorf("Expected CompareVersions(%s, %s) = %d, got %d", tt.a, tt.b, tt.expected, got)
+		}
+	}
+}
+
+func TestHostLifecycleInDependencyOrder(t *testing.T) {
+	var log []string
+	registry := newTestRegistry(&log, map[string][]string{"a": {"b"}, "b": {"c"}})
+	host := NewHost(registry)
+
+	// c is not listed but b requires it
+	if err := host.Load([]Spec{{Name: "a"}, {Name: "d"}, {Name: "b"}}); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	chain, _ := host.Chain()
+	if names := chain.Names(); !reflect.DeepEqual(names, []string{"c", "b", "a", "d"}) {
+		t.Errorf("Expected load order c, b, a, d, got %v", names)
+	}
+	if err := host.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	expected := []string{"init c", "init b", "init a", "init d", "close d", "close a", "close b", "close c"}
+	if !reflect.DeepEqual(log, expected) {
+		t.Errorf("Expected %v, got %v", expected, log)
+	}
+}
+
+func TestHostLoadErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		deps     map[string][]string
+		specs    []Spec
+		expected string
+	}{
+		{"Cycle", map[string][]string{"a": {"b"}, "b": {"a"}}, []Spec{{Name: "a"}}, "dependency cycle: a -> b -> a"},
+		{"Missing dependency", map[string][]string{"a": {"z"}}, []Spec{{Name: "a"}}, "plugin z is not registered"},
+		{"Unknown plugin", nil, []Spec{{Name: "z"}}, "plugin z is not registered"},
+		{"Listed twice", nil, []Spec{{Name: "a"}, {Name: "a"}}, "plugin a is listed twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log []string
+			host := NewHost(newTestRegistry(&log, tt.deps))
+			err := host.Load(tt.specs)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+			if len(host.Loaded()) != 0 {
+				t.Errorf("Expected nothing to be loaded, got %d plugins", len(host.Loaded()))
+			}
+		})
+	}
+}
+
+func TestHostInitFailureClosesStarted(t *testing.T) {
+	var log []string
+	registry := newTestRegistry(&log, nil)
+	initErr := errors.New("bad config")
+	registry.MustRegister(Registration{Name: "broken", Version: "1.0.0", New: func() Plugin {
+		return &lifecyclePlugin{name: "broken", log: &log, initErr: initErr}
+	}})
+	host := NewHost(registry)
+
+	err := host.Load([]Spec{{Name: "a"}, {Name: "b"}, {Name: "broken"}})
+	if !errors.Is(err, initErr) {
+		t.Fatalf("Expected the Init error, got %v", err)
+	}
+	expected := []string{"init a", "init b", "init broken", "close broken", "close b", "close a"}
+	if !reflect.DeepEqual(log, expected) {
+		t.Errorf("Expected %v, got %v", expected, log)
+	}
+}
+
+// halfInitPlugin opens a file in Init and then fails, leaving the file
+// for Close to release
+type halfInitPlugin struct{ file *os.File }
+
+func (p *halfInitPlugin) Init(Config) error {
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		return err
+	}
+	p.file = f
+	return errors.New("bad config")
+}
+
+func (p *halfInitPlugin) Process(data string) string {
+	return data
+}
+
+func (p *halfInitPlugin) Close() error {
+	return p.file.Close()
+}
+
+func TestHostInitFailureClosesThePlugin(t *testing.T) {
+	p := &halfInitPlugin{}
+	registry := &Registry{}
+	registry.MustRegister(Registration{Name: "half", Version: "1.0.0", New: func() Plugin { return p }})
+	host := NewHost(registry)
+
+	if err := host.Load([]Spec{{Name: "half"}}); err == nil || !strings.Contains(err.Error(), "bad config") {
+		t.Fatalf("Expected the Init error, got %v", err)
+	}
+	if p.file == nil {
+		t.Fatal("Expected Init to have opened its file")
+	}
+	if err := p.file.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected the plugin's file to be closed by the host, got %v", err)
+	}
+}
+
+func TestChainProcess(t *testing.T) {
+	registry := &Registry{}
+	RegisterBuiltins(registry)
+	registry.MustRegister(Registration{Name: "panics", Version: "0.1.0", New: func() Plugin {
+		return FuncPlugin(func(string) string { panic("boom") })
+	}})
+	host := NewHost(registry)
+	err := host.Load([]Spec{
+		{Name: "trim"},
+		{Name: "replace", Config: Config{"old": "world", "new": "plugins"}},
+		{Name: "prefix", Config: Config{"text": "# "}},
+		{Name: "panics"},
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	defer host.Close()
+
+	chain, err := host.Chain("trim", "replace", "prefix")
+	if err != nil {
+		t.Fatalf("Chain failed: %v", err)
+	}
+	result, err := chain.Process(context.Background(), "  hello world\nbye world \n")
+	if expected := "# hello plugins\n# bye plugins"; result != expected || err != nil {
+		t.Errorf("Expected %q, got %q (%v)", expected, result, err)
+	}
+
+	chain, _ = host.Chain("trim", "panics")
+	_, err = chain.Process(context.Background(), "x")
+	var stageErr *StageError
+	var panicErr *PanicError
+	if !errors.As(err, &stageErr) || stageErr.Stage != 1 || !errors.As(err, &panicErr) {
+		t.Errorf("Expected a recovered panic at stage 1, got %v", err)
+	}
+
+	if _, err := host.Chain("upper"); err == nil {
+		t.Errorf("Expected a chain with a plugin not loaded to fail")
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"10-trim.json":   `{"name": "trim"}`,
+		"20-prefix.json": `{"name": "prefix", "version": "1.0.0", "config": {"text": "> "}}`,
+		"notes.txt":      `not a spec`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	specs, err := Discover(dir)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	expected := []Spec{{Name: "trim"}, {Name: "prefix", Version: "1.0.0", Config: Config{"text": "> "}}}
+	if !reflect.DeepEqual(specs, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, specs)
+	}
+
+	var out strings.Builder
+	registry := &Registry{}
+	RegisterBuiltins(registry)
+	if err := run(registry, dir, "", strings.NewReader("  text  "), &out); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if out.String() != "> text" {
+		t.Errorf("Expected %q, got %q", "> text", out.String())
+	}
+}
@@ -0,0 +1,131 @@
+// main.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func main() {
+	pluginDir := flag.String("plugins", "", "directory of *.json plugin specs to load")
+	chainFlag := flag.String("chain", "", "comma-separated plugins to run, in order; default all loaded, in load order")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [list | serve <plugin> [version] | manifest <file>]\n\nReads text from stdin, runs it through the plugin chain and writes the result to stdout.\n\"serve\" runs a built-in plugin out of process, speaking the stdio protocol.\n\"manifest\" checks a plugin manifest against this host.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	registry := &Registry{}
+	RegisterBuiltins(registry)
+
+	switch flag.Arg(0) {
+	case "list":
+		for _, reg := range registry.List() {
+			fmt.Printf("%s\t%s\n", reg.Name, reg.Version)
+		}
+		return
+	case "serve":
+		if err := serve(registry, flag.Arg(1), flag.Arg(2)); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		return
+	case "manifest":
+		if err := checkManifest(flag.Arg(1), os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := run(registry, *pluginDir, *chainFlag, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// run loads the plugins in pluginDir plus those named in chain, and runs
+// the chain over in
+func run(registry *Registry, pluginDir, chain string, in io.Reader, out io.Writer) error {
+	var specs []Spec
+	if pluginDir != "" {
+		var err error
+		if specs, err = Discover(pluginDir); err != nil {
+			return err
+		}
+	}
+	var names []string
+	if chain != "" {
+		names = strings.Split(chain, ",")
+	}
+	// Plugins named in the chain but not configured in pluginDir are loaded
+	// with no configuration
+	for _, name := range names {
+		if !hasSpec(specs, name) {
+			specs = append(specs, Spec{Name: name})
+		}
+	}
+
+	host := NewHost(registry)
+	if err := host.Load(specs); err != nil {
+		return err
+	}
+	defer host.Close()
+
+	c, err := host.Chain(names...)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	result, err := c.Process(context.Background(), string(data))
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, result)
+	return err
+}
+
+// serve serves the registered plugin name over stdin and stdout; version
+// "" means the latest
+func serve(registry *Registry, name, version string) error {
+	reg, err := registry.Lookup(name, version)
+	if err != nil {
+		return err
+	}
+	return Serve(reg.Name, reg.Version, reg.New())
+}
+
+// checkManifest validates the manifest at path and reports how the host
+// would run the plugin
+func checkManifest(path string, out io.Writer) error {
+	m, err := LoadManifest(path)
+	if err != nil {
+		return err
+	}
+	n, err := Negotiate(m)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s %s works with this host (API %s) at API %s\n", m.Name, m.Version, HostAPI, n.API)
+	fmt.Fprintf(out, "capabilities: %s\n", strings.Join(n.Capabilities, ", "))
+	if len(n.Dropped) > 0 {
+		fmt.Fprintf(out, "unavailable: %s\n", strings.Join(n.Dropped, ", "))
+	}
+	return nil
+}
+
+func hasSpec(specs []Spec, name string) bool {
+	for _, spec := range specs {
+		if spec.Name == name {
+			return true
+		}
+	}
+	return false
+}
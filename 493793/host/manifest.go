@@ -0,0 +1,398 @@
+// manifest.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// HostAPI is the version of what the host offers plugins: the Plugin
+// interface, its optional extensions and the stdio protocol. A new minor
+// version adds a capability; a new major one would break plugins written
+// for the one before.
+var HostAPI = Version{Major: 1, Minor: 1}
+
+// hostAPIs are the API versions the host can serve a plugin at, oldest
+// first
+var hostAPIs = []Version{{Major: 1}, {Major: 1, Minor: 1}}
+
+// capabilities maps each capability a plugin can have to the host API
+// version that brought it in
+var capabilities = map[string]Version{
+	"process": {Major: 1},           // Plugin
+	"init":    {Major: 1},           // Initializer, configured by Config
+	"hello":   {Major: 1, Minor: 1}, // Greeter
+}
+
+// ManifestFormat is the version of the manifest format the host reads
+const ManifestFormat = 1
+
+// Manifest is what a plugin declares about itself, so that the host can
+// tell before running it whether they will work together. Out-of-process
+// plugins ship it as a JSON file named by their Spec; built-in ones carry
+// it in their Registration:
+//
+//	{
+//		"manifest": 1,
+//		"name": "shout",
+//		"version": "2.1.0",
+//		"host_api": "^1.0",
+//		"capabilities": ["process", "init"],
+//		"optional_capabilities": ["hello"],
+//		"config": {"suffix": {"type": "string", "default": "!"}}
+//	}
+type Manifest struct {
+	Format  int    `json:"manifest"` // ManifestFormat
+	Name    string `json:"name"`
+	Version string `json:"version"`
+
+	// HostAPI is the range of host API versions the plugin works with,
+	// e.g. "^1.0" or ">=1.1 <3"; see ParseRange
+	HostAPI string `json:"host_api"`
+
+	// Capabilities are those the plugin cannot work without; Optional
+	// ones it uses if the host has them
+	Capabilities []string `json:"capabilities"`
+	Optional     []string `json:"optional_capabilities,omitempty"`
+
+	Config map[string]ConfigField `json:"config,omitempty"`
+}
+
+// ConfigField describes one setting in a plugin's Config
+type ConfigField struct {
+	Type        string `json:"type"` // "string", "number" or "bool"
+	Required    bool   `json:"required,omitempty"`
+	Default     any    `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// LoadManifest reads and validates the manifest at path
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := ParseManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// ParseManifest decodes and validates a JSON manifest. Unknown fields are
+// errors, to catch misspellings.
+func ParseManifest(data []byte) (*Manifest, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var m Manifest
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Validate checks that the manifest is complete and well formed. It does
+// not check that the host can run the plugin; Negotiate does.
+func (m *Manifest) Validate() error {
+	var problems []string
+	switch {
+	case m.Format == 0:
+		problems = append(problems, `the manifest format ("manifest": 1) is missing`)
+	case m.Format > ManifestFormat:
+		problems = append(problems, fmt.Sprintf("manifest format %d is newer than this host reads (%d)", m.Format, ManifestFormat))
+	}
+	if m.Name == "" {
+		problems = append(problems, "the name is missing")
+	}
+	if _, err := ParseVersion(m.Version); err != nil {
+		problems = append(problems, "the version is missing or invalid: "+m.Version)
+	}
+	if _, err := ParseRange(m.HostAPI); err != nil || m.HostAPI == "" {
+		problems = append(problems, fmt.Sprintf("host_api %q is not a version range such as \"^1.0\"", m.HostAPI))
+	}
+	if !slices.Contains(m.Capabilities, "process") {
+		problems = append(problems, `the capabilities do not include "process"`)
+	}
+	for _, key := range sortedKeys(m.Config) {
+		field := m.Config[key]
+		if !slices.Contains([]string{"string", "number", "bool"}, field.Type) {
+			problems = append(problems, fmt.Sprintf("setting %q has type %q, not string, number or bool", key, field.Type))
+		} else if field.Default != nil && typeOf(field.Default) != field.Type {
+			problems = append(problems, fmt.Sprintf("the default of setting %q is not a %s", key, field.Type))
+		}
+	}
+	if len(problems) > 0 {
+		name := m.Name
+		if name == "" {
+			name = "plugin"
+		}
+		return fmt.Errorf("manifest of %s: %s", name, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ValidateConfig checks config against the manifest's settings and
+// returns it with the defaults filled in
+func (m *Manifest) ValidateConfig(config Config) (Config, error) {
+	var problems []string
+	for _, key := range sortedKeys(config) {
+		field, ok := m.Config[key]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("unknown setting %q%s", key, m.knownSettings()))
+		case typeOf(config[key]) != field.Type:
+			problems = append(problems, fmt.Sprintf("setting %q must be a %s, not %s", key, field.Type, typeOf(config[key])))
+		}
+	}
+
+	valid := make(Config, len(m.Config))
+	for _, key := range sortedKeys(m.Config) {
+		field := m.Config[key]
+		value, ok := config[key]
+		switch {
+		case ok:
+			valid[key] = value
+		case field.Required:
+			problems = append(problems, fmt.Sprintf("required setting %q is missing", key))
+		case field.Default != nil:
+			valid[key] = field.Default
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("configuration of plugin %s: %s", m.Name, strings.Join(problems, "; "))
+	}
+	return valid, nil
+}
+
+func (m *Manifest) knownSettings() string {
+	if len(m.Config) == 0 {
+		return " (the plugin has no settings)"
+	}
+	return " (settings: " + strings.Join(sortedKeys(m.Config), ", ") + ")"
+}
+
+// typeOf names the ConfigField type of a value decoded from JSON
+func typeOf(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64, int:
+		return "number"
+	case bool:
+		return "bool"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Negotiation is what the host and a plugin agreed on
+type Negotiation struct {
+	API          Version  // the newest host API version in the plugin's range
+	Capabilities []string // required and optional ones the host has at API
+	Dropped      []string // optional ones it does not
+}
+
+// IncompatibleError is a plugin the host refuses to load because its
+// manifest asks for something the host cannot give
+type IncompatibleError struct {
+	Plugin  string
+	Version string
+	Reason  string
+}
+
+func (e *IncompatibleError) Error() string {
+	return fmt.Sprintf("plugin %s %s is incompatible with this host: %s", e.Plugin, e.Version, e.Reason)
+}
+
+// Negotiate works out how the host can run the plugin m describes: at the
+// newest host API version the plugin accepts, without the optional
+// capabilities the host lacks at that version. It fails with an
+// *IncompatibleError if the plugin accepts no version the host can serve,
+// or needs a capability the host lacks.
+func Negotiate(m *Manifest) (Negotiation, error) {
+	incompatible := func(format string, args ...any) error {
+		return &IncompatibleError{Plugin: m.Name, Version: m.Version, Reason: fmt.Sprintf(format, args...)}
+	}
+	accepts, err := ParseRange(m.HostAPI)
+	if err != nil {
+		return Negotiation{}, err
+	}
+
+	var n Negotiation
+	found := false
+	for _, v := range hostAPIs {
+		if accepts.Contains(v) {
+			n.API, found = v, true
+		}
+	}
+	if !found {
+		return Negotiation{}, incompatible("it needs host API %s; this host provides %s and serves plugins back to %s", m.HostAPI, HostAPI, hostAPIs[0])
+	}
+
+	for _, c := range m.Capabilities {
+		since, ok := capabilities[c]
+		switch {
+		case !ok:
+			return Negotiation{}, incompatible("it needs capability %q, which this host (API %s) does not have", c, HostAPI)
+		case since.Compare(n.API) > 0:
+			return Negotiation{}, incompatible("it needs capability %q, which came in host API %s, but it accepts only %s", c, since, m.HostAPI)
+		}
+		n.Capabilities = append(n.Capabilities, c)
+	}
+	for _, c := range m.Optional {
+		if since, ok := capabilities[c]; ok && since.Compare(n.API) <= 0 {
+			n.Capabilities = append(n.Capabilities, c)
+		} else {
+			n.Dropped = append(n.Dropped, c)
+		}
+	}
+	return n, nil
+}
+
+// Has reports whether the capability was agreed on
+func (n *Negotiation) Has(capability string) bool {
+	return slices.Contains(n.Capabilities, capability)
+}
+
+// VersionRange is a set of versions, parsed by ParseRange
+type VersionRange struct {
+	text        string
+	comparators []comparator
+}
+
+type comparator struct {
+	op string // "=", ">", ">=", "<" or "<="
+	v  Version
+}
+
+// ParseRange parses a version range: space-separated comparisons that
+// must all hold, each a version preceded by =, >, >=, < or <=, or by ^
+// (the same major version, or the same minor for 0.x) or ~ (the same
+// minor version). A bare version matches only itself, and "" or "*"
+// matches any.
+func ParseRange(s string) (VersionRange, error) {
+	r := VersionRange{text: s}
+	for _, term := range strings.Fields(s) {
+		if term == "*" {
+			continue
+		}
+		op := term[:len(term)-len(strings.TrimLeft(term, "<>=^~"))]
+		v, err := ParseVersion(term[len(op):])
+		if err != nil {
+			return VersionRange{}, fmt.Errorf("invalid version range %q: %w", s, err)
+		}
+		switch op {
+		case "", "=":
+			r.comparators = append(r.comparators, comparator{"=", v})
+		case ">", ">=", "<", "<=":
+			r.comparators = append(r.comparators, comparator{op, v})
+		case "^":
+			upper := Version{Major: v.Major + 1}
+			if v.Major == 0 {
+				upper = Version{Minor: v.Minor + 1}
+			}
+			r.comparators = append(r.comparators, comparator{">=", v}, comparator{"<", upper})
+		case "~":
+			r.comparators = append(r.comparators, comparator{">=", v}, comparator{"<", Version{Major: v.Major, Minor: v.Minor + 1}})
+		default:
+			return VersionRange{}, fmt.Errorf("invalid version range %q: unknown operator %q", s, op)
+		}
+	}
+	return r, nil
+}
+
+// Contains reports whether v is in the range
+func (r VersionRange) Contains(v Version) bool {
+	for _, c := range r.comparators {
+		d := v.Compare(c.v)
+		ok := false
+		switch c.op {
+		case "=":
+			ok = d == 0
+		case ">":
+			ok = d > 0
+		case ">=":
+			ok = d >= 0
+		case "<":
+			ok = d < 0
+		case "<=":
+			ok = d <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (r VersionRange) String() string {
+	return r.text
+}
+
+// negotiate checks a plugin's manifest against the host and config
+// against the manifest. Plugins without a manifest predate manifests and
+// are loaded as before, with a nil Negotiation.
+func negotiate(reg Registration, config Config) (*Negotiation, Config, error) {
+	m := reg.Manifest
+	if m == nil {
+		return nil, config, nil
+	}
+	n, err := Negotiate(m)
+	if err != nil {
+		return nil, nil, err
+	}
+	config, err = m.ValidateConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &n, config, nil
+}
+
+// checkCapabilities checks that in has the capabilities it agreed to
+func checkCapabilities(in *Instance) error {
+	if in.Negotiated == nil {
+		return nil
+	}
+	for _, c := range in.Negotiated.Capabilities {
+		if !offers(in.Plugin, c) {
+			return fmt.Errorf("plugin %s %s declares capability %q but does not have it", in.Name, in.Version, c)
+		}
+	}
+	return nil
+}
+
+// offers reports whether p has a capability, as reported in its handshake
+// if it runs out of process
+func offers(p Plugin, capability string) bool {
+	if remote, ok := p.(*RemotePlugin); ok {
+		return remote.Info().Has(capability)
+	}
+	var ok bool
+	switch capability {
+	case "process":
+		ok = true
+	case "init":
+		_, ok = p.(Initializer)
+	case "hello":
+		_, ok = p.(Greeter)
+	}
+	return ok
+}
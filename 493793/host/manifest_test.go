@@ -0,0 +1,241 @@
+// manifest_test.go
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestVersionRange(t *testing.T) {
+	tests := []struct {
+		rng      string
+		version  string
+		expected bool
+	}{
+		{"^1.0", "1.4.2", true},
+		{"^1.0", "2.0.0", false},
+		{"^1.2", "1.1.9", false},
+		{"^0.3", "0.3.5", true},
+		{"^0.3", "0.4.0", false},
+		{"~1.1", "1.1.7", true},
+		{"~1.1", "1.2.0", false},
+		{">=1.1 <3", "2.9.0", true},
+		{">=1.1 <3", "3.0.0", false},
+		{">1.0.0", "1.0.0", false},
+		{"<=1.0.0", "1.0.0", true},
+		{"1.1.0", "1.1.0", true},
+		{"=1.1.0", "1.1.1", false},
+		{"*", "7.0.0", true},
+		{"", "0.1.0", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rng+" "+tt.version, func(t *testing.T) {
+			r, err := ParseRange(tt.rng)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, _ := ParseVersion(tt.version)
+			if got := r.Contains(v); got != tt.expected {
+				t.Errorf("Expected %s contains %s = %v, got %v", tt.rng, tt.version, tt.expected, got)
+			}
+		})
+	}
+
+	for _, bad := range []string{"^", ">=x", "!1.0", "1.0.0.0"} {
+		if _, err := ParseRange(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestParseManifestErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		expected string
+	}{
+		{"Not JSON", `{`, "invalid manifest"},
+		{"Misspelt field", `{"manifest": 1, "name": "x", "version": "1.0.0", "host_api": "^1", "capabilities": ["process"], "capabilites": []}`, `unknown field "capabilites"`},
+		{"No format", `{"name": "x", "version": "1.0.0", "host_api": "^1", "capabilities": ["process"]}`, "manifest format"},
+		{"Newer format", `{"manifest": 2, "name": "x", "version": "1.0.0", "host_api": "^1", "capabilities": ["process"]}`, "manifest format 2 is newer than this host reads (1)"},
+		{"Several problems", `{"manifest": 1, "name": "x", "version": "one", "host_api": "", "capabilities": []}`,
+			`manifest of x: the version is missing or invalid: one; host_api "" is not a version range such as "^1.0"; the capabilities do not include "process"`},
+		{"Bad setting", `{"manifest": 1, "name": "x", "version": "1.0.0", "host_api": "^1", "capabilities": ["process"], "config": {"n": {"type": "int"}, "s": {"type": "string", "default": 3}}}`,
+			`setting "n" has type "int", not string, number or bool; the default of setting "s" is not a string`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseManifest([]byte(tt.json))
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name         string
+		hostAPI      string
+		capabilities []string
+		optional     []string
+		api          string
+		agreed       []string
+		dropped      []string
+		expected     string // error
+	}{
+		{"Current", "^1.0", []string{"process", "init"}, nil, "1.1.0", []string{"process", "init"}, nil, ""},
+		{"Older API", "~1.0", []string{"process"}, []string{"hello"}, "1.0.0", []string{"process"}, []string{"hello"}, ""},
+		{"Unknown optional capability", "^1.1", []string{"process"}, []string{"hello", "stream"}, "1.1.0", []string{"process", "hello"}, []string{"stream"}, ""},
+		{"Newer API", ">=1.2", []string{"process"}, nil, "", nil, nil,
+			"plugin shout 2.0.0 is incompatible with this host: it needs host API >=1.2; this host provides 1.1.0 and serves plugins back to 1.0.0"},
+		{"Next major", "^2.0", []string{"process"}, nil, "", nil, nil, "it needs host API ^2.0"},
+		{"Unknown capability", "^1.0", []string{"process", "stream"}, nil, "", nil, nil, `it needs capability "stream", which this host (API 1.1.0) does not have`},
+		{"Capability outside range", "~1.0", []string{"process", "hello"}, nil, "", nil, nil, `it needs capability "hello", which came in host API 1.1.0, but it accepts only ~1.0`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Manifest{Format: 1, Name: "shout", Version: "2.0.0", HostAPI: tt.hostAPI, Capabilities: tt.capabilities, Optional: tt.optional}
+			n, err := Negotiate(m)
+			if tt.expected != "" {
+				var incompatible *IncompatibleError
+				if !errors.As(err, &incompatible) || !strings.Contains(err.Error(), tt.expected) {
+					t.Errorf("Expected an *IncompatibleError containing %q, got %v", tt.expected, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n.API.String() != tt.api || !slices.Equal(n.Capabilities, tt.agreed) || !slices.Equal(n.Dropped, tt.dropped) {
+				t.Errorf("Expected API %s with %v, dropping %v, got %+v", tt.api, tt.agreed, tt.dropped, n)
+			}
+		})
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	registry := &Registry{}
+	RegisterBuiltins(registry)
+	replace, _ := registry.Lookup("replace", "")
+	tests := []struct {
+		name     string
+		config   Config
+		expected Config
+		err      string
+	}{
+		{"Defaults", Config{"old": "a"}, Config{"old": "a", "new": ""}, ""},
+		{"All set", Config{"old": "a", "new": "b"}, Config{"old": "a", "new": "b"}, ""},
+		{"Problems", Config{"olb": "a", "new": 1.0}, nil,
+			`configuration of plugin replace: setting "new" must be a string, not number; unknown setting "olb" (settings: new, old); required setting "old" is missing`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := replace.Manifest.ValidateConfig(tt.config)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("Expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil || len(got) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v (%v)", tt.expected, got, err)
+			}
+			for key, value := range tt.expected {
+				if got[key] != value {
+					t.Errorf("Expected %s = %v, got %v", key, value, got[key])
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterChecksManifest(t *testing.T) {
+	registry := &Registry{}
+	err := registry.Register(Registration{Name: "a", Version: "1.0.0", New: func() Plugin { return &ExamplePlugin{} }, Manifest: &Manifest{
+		Format: 1, Name: "a", Version: "1.1.0", HostAPI: "^1", Capabilities: []string{"process"},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "the manifest is for a 1.1.0") {
+		t.Errorf("Expected a version mismatch, got %v", err)
+	}
+}
+
+func TestHostLoadChecksManifests(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOST_TEST_SERVE", "upper")
+	dir := t.TempDir()
+	manifests := map[string]string{
+		"upper.json":   `{"manifest": 1, "name": "upper", "version": "1.0.0", "host_api": "^1.0", "capabilities": ["process"], "optional_capabilities": ["stream"]}`,
+		"greeter.json": `{"manifest": 1, "name": "upper", "version": "1.0.0", "host_api": "^1.1", "capabilities": ["process", "hello"]}`,
+		"future.json":  `{"manifest": 1, "name": "upper", "version": "1.0.0", "host_api": "^2.0", "capabilities": ["process"]}`,
+	}
+	for name, content := range manifests {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	host := NewHost(testRegistry())
+	if err := host.Load([]Spec{{Name: "upper", Exec: exe, Manifest: filepath.Join(dir, "upper.json")}, {Name: "prefix"}}); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	defer host.Close()
+	upper, prefix := host.Loaded()[0], host.Loaded()[1]
+	if n := upper.Negotiated; n == nil || n.API != HostAPI || !slices.Equal(n.Dropped, []string{"stream"}) {
+		t.Errorf("Expected upper at API %s without stream, got %+v", HostAPI, n)
+	}
+	// The default from the manifest reaches Init
+	if out, err := prefix.Process(context.Background(), "x"); out != "> x" || err != nil {
+		t.Errorf("Expected %q, got %q (%v)", "> x", out, err)
+	}
+
+	tests := []struct {
+		name     string
+		spec     Spec
+		expected string
+	}{
+		{"Missing setting", Spec{Name: "replace"}, `configuration of plugin replace: required setting "old" is missing`},
+		{"Unknown setting", Spec{Name: "prefix", Config: Config{"txt": "# "}}, `unknown setting "txt" (settings: text)`},
+		{"Capability not offered", Spec{Name: "upper", Exec: exe, Manifest: filepath.Join(dir, "greeter.json")}, `plugin upper 1.0.0 declares capability "hello" but does not have it`},
+		{"Incompatible", Spec{Name: "upper", Exec: exe, Manifest: filepath.Join(dir, "future.json")}, "plugin upper 1.0.0 is incompatible with this host: it needs host API ^2.0"},
+		{"Wrong name", Spec{Name: "shout", Exec: exe, Manifest: filepath.Join(dir, "upper.json")}, "is for plugin upper"},
+		{"Wrong version", Spec{Name: "upper", Version: "2.0.0", Exec: exe, Manifest: filepath.Join(dir, "upper.json")}, "is for version 1.0.0, not 2.0.0"},
+		{"Built-in with a file", Spec{Name: "upper", Manifest: filepath.Join(dir, "upper.json")}, "only out-of-process plugins take a manifest file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := NewHost(testRegistry())
+			err := host.Load([]Spec{tt.spec})
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+			if len(host.Loaded()) != 0 {
+				t.Errorf("Expected nothing to be loaded, got %d plugins", len(host.Loaded()))
+			}
+		})
+	}
+}
+
+func TestCheckManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shout.manifest.json")
+	manifest := `{"manifest": 1, "name": "shout", "version": "2.1.0", "host_api": "~1.0", "capabilities": ["process", "init"], "optional_capabilities": ["hello"]}`
+	if err := os.WriteFile(path, []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := checkManifest(path, &out); err != nil {
+		t.Fatal(err)
+	}
+	expected := "shout 2.1.0 works with this host (API 1.1.0) at API 1.0.0\ncapabilities: process, init\nunavailable: hello\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
@@ -0,0 +1,77 @@
+// plugin.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// Plugin is the interface from 493793/b1: a text transformer. Plugins that
+// hold resources also implement io.Closer; Close is called once, when the
+// host shuts down.
+type Plugin interface {
+	Process(data string) string
+}
+
+// Initializer is implemented by plugins that need configuration. Init is
+// called once, before the first Process.
+type Initializer interface {
+	Init(config Config) error
+}
+
+// ContextProcessor is implemented by plugins whose processing can fail or
+// be cancelled, such as out-of-process ones. The host prefers it to
+// Process.
+type ContextProcessor interface {
+	ProcessContext(ctx context.Context, data string) (string, error)
+}
+
+// Config is the configuration of one plugin, as decoded from JSON
+type Config map[string]any
+
+// String returns the string at key, or def if it is missing
+func (c Config) String(key, def string) string {
+	if s, ok := c[key].(string); ok {
+		return s
+	}
+	return def
+}
+
+// PanicError is a panic in a plugin, recovered by the host
+type PanicError struct {
+	Plugin string
+	Value  any
+	Stack  []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("plugin %s panicked: %v", e.Plugin, e.Value)
+}
+
+// process runs p on data, through ProcessContext if p has it
+func process(ctx context.Context, name string, p Plugin, data string) (out string, err error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	err = guard(name, func() error {
+		if cp, ok := p.(ContextProcessor); ok {
+			out, err = cp.ProcessContext(ctx, data)
+			return err
+		}
+		out = p.Process(data)
+		return nil
+	})
+	return out, err
+}
+
+// guard calls fn and turns a panic in it into a *PanicError, so that one
+// plugin cannot bring down the host
+func guard(name string, fn func() error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Plugin: name, Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,417 @@
+// conformance.go
+
+// Package plugintest is the test kit for host plugins: a conformance suite
+// every plugin has to pass, and snapshot tests of their output. It needs
+// only the Process method of a plugin, so plugin authors can use it
+// without importing the host.
+package plugintest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// Plugin is the interface of a host plugin: a text transformer. Plugins
+// that hold resources also implement io.Closer.
+type Plugin interface {
+	Process(data string) string
+}
+
+// contextProcessor is implemented by plugins whose processing can fail or
+// be cancelled; it is preferred to Process, as the host does
+type contextProcessor interface {
+	ProcessContext(ctx context.Context, data string) (string, error)
+}
+
+// Factory makes a new instance of a plugin, ready to use: plugins that
+// need configuration are initialised by it
+type Factory func() Plugin
+
+// Conformance is the bar every plugin has to clear before the host accepts
+// it. Plugin authors run it from a test:
+//
+//	func TestConformance(t *testing.T) {
+//		plugintest.Run(t, func() plugintest.Plugin { return &MyPlugin{} })
+//	}
+//
+// and go test -race makes its concurrency check catch data races too. The
+// zero value runs every check with the defaults.
+type Conformance struct {
+	Inputs     []string      // added to the built-in corpus
+	LargeInput int           // size in bytes of the large input; 0 means 1 MiB
+	Timeout    time.Duration // per call; 0 means 5 seconds
+	Workers    int           // goroutines in the concurrency check; 0 means 8
+
+	// Golden, if set, is a JSON file of cases the plugin must reproduce:
+	//
+	//	[{"input": "", "output": " processed"}]
+	Golden string
+}
+
+// Run runs the default Conformance suite against the plugins made by
+// factory, one subtest per check
+func Run(t *testing.T, factory Factory) {
+	Conformance{}.Run(t, factory)
+}
+
+// Run runs every check as a subtest of t
+func (c Conformance) Run(t *testing.T, factory Factory) {
+	c = c.withDefaults()
+	for _, check := range conformanceChecks {
+		t.Run(check.name, func(t *testing.T) {
+			if err := check.run(c, factory); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// Check runs every check without a testing.T, e.g. to vet a plugin before
+// loading it, and returns the failures by check name
+func (c Conformance) Check(factory Factory) map[string]error {
+	c = c.withDefaults()
+	failures := make(map[string]error)
+	for _, check := range conformanceChecks {
+		if err := check.run(c, factory); err != nil {
+			failures[check.name] = err
+		}
+	}
+	return failures
+}
+
+var conformanceChecks = []struct {
+	name string
+	run  func(Conformance, Factory) error
+}{
+	{"Lifecycle", Conformance.checkLifecycle},
+	{"Determinism", Conformance.checkDeterminism},
+	{"Repeatability", Conformance.checkRepeatability},
+	{"Concurrency", Conformance.checkConcurrency},
+	{"Unicode", Conformance.checkUnicode},
+	{"LargeInput", Conformance.checkLargeInput},
+	{"Golden", Conformance.checkGolden},
+}
+
+// conformanceCorpus is run through every plugin
+var conformanceCorpus = []string{
+	"",
+	" ",
+	"test",
+	"test multiple words",
+	"line one\nline two\r\nline three\n",
+	"\t tabs and  spaces \t",
+	"@#$%^&*()<>\"'\\",
+	"\x00nul inside",
+}
+
+// unicodeCorpus holds multi-byte, combining, right-to-left and astral text
+var unicodeCorpus = []string{
+	"こんにちは",
+	"Grüße, Jürgen",
+	"é combining accent",
+	"مرحبا بالعالم",
+	"emoji 👋🏽 and flags 🇳🇿",
+	"\u200bzero\u200bwidth\u200b",
+	"𝔘𝔫𝔦𝔠𝔬𝔡𝔢",
+}
+
+func (c Conformance) withDefaults() Conformance {
+	if c.LargeInput <= 0 {
+		c.LargeInput = 1 << 20
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 5 * time.Second
+	}
+	if c.Workers <= 0 {
+		c.Workers = 8
+	}
+	return c
+}
+
+func (c Conformance) corpus() []string {
+	return append(append(append([]string(nil), conformanceCorpus...), unicodeCorpus...), c.Inputs...)
+}
+
+// checkLifecycle makes, initialises, uses and closes a plugin
+func (c Conformance) checkLifecycle(factory Factory) error {
+	p, err := c.start(factory)
+	if err != nil {
+		return err
+	}
+	if _, err := c.call(p, "test"); err != nil {
+		c.stop(p)
+		return err
+	}
+	return c.stop(p)
+}
+
+// checkDeterminism requires two instances to agree on every input
+func (c Conformance) checkDeterminism(factory Factory) error {
+	first, err := c.start(factory)
+	if err != nil {
+		return err
+	}
+	defer c.stop(first)
+	second, err := c.start(factory)
+	if err != nil {
+		return err
+	}
+	defer c.stop(second)
+
+	for _, input := range c.corpus() {
+		a, err := c.call(first, input)
+		if err != nil {
+			return err
+		}
+		b, err := c.call(second, input)
+		if err != nil {
+			return err
+		}
+		if a != b {
+			return fmt.Errorf("two instances disagree on %q: %q and %q", input, a, b)
+		}
+	}
+	return nil
+}
+
+// checkRepeatability requires an instance to give the same output for the
+// same input whatever it processed in between, i.e. to keep no state
+// between calls that changes its results
+func (c Conformance) checkRepeatability(factory Factory) error {
+	p, err := c.start(factory)
+	if err != nil {
+		return err
+	}
+	defer c.stop(p)
+
+	corpus := c.corpus()
+	want := make([]string, len(corpus))
+	for i, input := range corpus {
+		if want[i], err = c.call(p, input); err != nil {
+			return err
+		}
+	}
+	for i := len(corpus) - 1; i >= 0; i-- {
+		got, err := c.call(p, corpus[i])
+		if err != nil {
+			return err
+		}
+		if got != want[i] {
+			return fmt.Errorf("output for %q changed from %q to %q on a later call", corpus[i], want[i], got)
+		}
+	}
+	return nil
+}
+
+// checkConcurrency calls one instance from Workers goroutines at once;
+// the outputs must match the sequential ones
+func (c Conformance) checkConcurrency(factory Factory) error {
+	p, err := c.start(factory)
+	if err != nil {
+		return err
+	}
+	defer c.stop(p)
+
+	corpus := c.corpus()
+	want := make(map[string]string, len(corpus))
+	for _, input := range corpus {
+		if want[input], err = c.call(p, input); err != nil {
+			return err
+		}
+	}
+
+	errs := make(chan error, c.Workers)
+	var wg sync.WaitGroup
+	for w := range c.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range corpus {
+				input := corpus[(i+w)%len(corpus)]
+				got, err := c.call(p, input)
+				if err == nil && got != want[input] {
+					err = fmt.Errorf("concurrent call on %q gave %q, sequential gave %q", input, got, want[input])
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// checkUnicode requires valid UTF-8 in to give valid UTF-8 out
+func (c Conformance) checkUnicode(factory Factory) error {
+	p, err := c.start(factory)
+	if err != nil {
+		return err
+	}
+	defer c.stop(p)
+
+	for _, input := range c.corpus() {
+		out, err := c.call(p, input)
+		if err != nil {
+			return err
+		}
+		if utf8.ValidString(input) && !utf8.ValidString(out) {
+			return fmt.Errorf("valid UTF-8 input %q gave invalid UTF-8 %q", input, out)
+		}
+	}
+	return nil
+}
+
+// checkLargeInput requires a large input to be handled within Timeout
+func (c Conformance) checkLargeInput(factory Factory) error {
+	p, err := c.start(factory)
+	if err != nil {
+		return err
+	}
+	defer c.stop(p)
+
+	line := "The quick brown fox jumps over the lazy dog. こんにちは 👋\n"
+	input := strings.Repeat(line, c.LargeInput/len(line)+1)[:c.LargeInput]
+	input = strings.ToValidUTF8(input, "") // the cut may split a rune
+	out, err := c.call(p, input)
+	if err != nil {
+		return fmt.Errorf("large input of %d bytes: %w", len(input), err)
+	}
+	if !utf8.ValidString(out) {
+		return fmt.Errorf("large input of %d bytes gave invalid UTF-8", len(input))
+	}
+	return nil
+}
+
+// goldenCase is one entry of a Golden file
+type goldenCase struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+}
+
+// checkGolden compares the outputs for the Golden file's inputs
+func (c Conformance) checkGolden(factory Factory) error {
+	if c.Golden == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.Golden)
+	if err != nil {
+		return err
+	}
+	var cases []goldenCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		return fmt.Errorf("%s: %w", c.Golden, err)
+	}
+
+	p, err := c.start(factory)
+	if err != nil {
+		return err
+	}
+	defer c.stop(p)
+
+	var errs []error
+	for _, tc := range cases {
+		got, err := c.call(p, tc.Input)
+		if err != nil {
+			return err
+		}
+		if got != tc.Output {
+			errs = append(errs, fmt.Errorf("for %q expected %q, got %q", tc.Input, tc.Output, got))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// start makes a plugin
+func (c Conformance) start(factory Factory) (Plugin, error) {
+	var p Plugin
+	if err := guard("factory", func() error { p = factory(); return nil }); err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, errors.New("factory returned nil")
+	}
+	return p, nil
+}
+
+// stop closes a plugin
+func (c Conformance) stop(p Plugin) error {
+	closer, ok := p.(io.Closer)
+	if !ok {
+		return nil
+	}
+	if err := guard("plugin", closer.Close); err != nil {
+		return fmt.Errorf("closing: %w", err)
+	}
+	return nil
+}
+
+// call processes input, failing on a panic or when it takes longer than
+// Timeout. A plugin that ignores its context is left running.
+func (c Conformance) call(p Plugin, input string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	type result struct {
+		out string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := process(ctx, p, input)
+		done <- result{out, err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return "", fmt.Errorf("processing %q: %w", shorten(input), r.err)
+		}
+		return r.out, nil
+	case <-ctx.Done():
+		return "", fmt.Errorf("processing %q: no result after %v", shorten(input), c.Timeout)
+	}
+}
+
+// shorten keeps error messages about large inputs readable
+func shorten(s string) string {
+	const limit = 40
+	if len(s) <= limit {
+		return s
+	}
+	return strings.ToValidUTF8(s[:limit], "") + fmt.Sprintf("... (%d bytes)", len(s))
+}
+
+// process runs p on data, through ProcessContext if p has it
+func process(ctx context.Context, p Plugin, data string) (out string, err error) {
+	err = guard("plugin", func() error {
+		if cp, ok := p.(contextProcessor); ok {
+			out, err = cp.ProcessContext(ctx, data)
+			return err
+		}
+		out = p.Process(data)
+		return nil
+	})
+	return out, err
+}
+
+// guard calls fn and turns a panic in it into an error carrying the stack
+func guard(name string, fn func() error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("%s panicked: %v\n%s", name, v, debug.Stack())
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,97 @@
+// conformance_test.go
+package plugintest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// funcPlugin adapts a function to Plugin
+type funcPlugin func(string) string
+
+func (f funcPlugin) Process(data string) string {
+	return f(data)
+}
+
+func TestConformancePasses(t *testing.T) {
+	Run(t, func() Plugin { return funcPlugin(strings.ToUpper) })
+}
+
+// counter is a plugin whose output depends on how often it was called
+type counter struct{ n atomic.Int64 }
+
+func (c *counter) Process(data string) string {
+	if c.n.Add(1) > 3 {
+		return data + "!"
+	}
+	return data
+}
+
+func TestConformanceCatchesBadPlugins(t *testing.T) {
+	golden := filepath.Join(t.TempDir(), "golden.json")
+	if err := os.WriteFile(golden, []byte(`[{"input": "test", "output": "test processed"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var made atomic.Int64
+	tests := []struct {
+		name     string
+		factory  Factory
+		failing  string
+		expected string
+	}{
+		{"Panics", func() Plugin {
+			return funcPlugin(func(data string) string {
+				if data == "" {
+					panic("empty input")
+				}
+				return data
+			})
+		}, "Determinism", ""},
+		{"Nondeterministic", func() Plugin {
+			n := made.Add(1)
+			return funcPlugin(func(data string) string { return data + strings.Repeat("+", int(n%2)) })
+		}, "Determinism", "two instances disagree"},
+		{"Stateful", func() Plugin { return &counter{} }, "Repeatability", "changed"},
+		{"Splits runes", func() Plugin {
+			return funcPlugin(func(data string) string {
+				b := []byte(data)
+				for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+					b[i], b[j] = b[j], b[i]
+				}
+				return string(b)
+			})
+		}, "Unicode", "invalid UTF-8"},
+		{"Slow on large input", func() Plugin {
+			return funcPlugin(func(data string) string {
+				if len(data) > 1000 {
+					time.Sleep(time.Second)
+				}
+				return data
+			})
+		}, "LargeInput", "no result after"},
+		{"Wrong golden output", func() Plugin { return funcPlugin(strings.ToUpper) }, "Golden", `for "test" expected "test processed", got "TEST"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suite := Conformance{Timeout: 100 * time.Millisecond, Golden: golden}
+			failures := suite.Check(tt.factory)
+			err := failures[tt.failing]
+			if err == nil {
+				t.Fatalf("Expected the %s check to fail, failures: %v", tt.failing, failures)
+			}
+			if tt.name == "Panics" {
+				if !strings.Contains(err.Error(), "panicked: empty input") {
+					t.Errorf("Expected the panic to be reported, got %v", err)
+				}
+				return
+			}
+			if !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+// snapshot.go
+package plugintest
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// updateSnapshots is the -update flag of go test
+var updateSnapshots = func() *bool {
+	if !testing.Testing() {
+		return new(bool)
+	}
+	return flag.Bool("update", false, "rewrite the snapshots under testdata/snapshots instead of comparing with them")
+}()
+
+// SnapshotDir is where snapshots are kept, relative to the package under
+// test
+const SnapshotDir = "testdata/snapshots"
+
+// Snapshot compares output with the snapshot SnapshotDir/name.golden and
+// fails t with a line diff if they differ. With go test -update it writes
+// output as the new snapshot instead.
+func Snapshot(t *testing.T, name, output string) {
+	t.Helper()
+	path := filepath.Join(SnapshotDir, filepath.FromSlash(name)+".golden")
+	if *updateSnapshots {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(output), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("snapshot %s does not exist; run go test -update to record it", path)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(want) != output {
+		t.Errorf("output differs from snapshot %s (- snapshot, + output; go test -update accepts it):\n%s", path, LineDiff(string(want), output))
+	}
+}
+
+// SnapshotCorpus runs a plugin made by factory over every file in
+// corpusDir and snapshots each output as plugin/<file name>, one subtest
+// per file. With go test -update, snapshots of files no longer in the
+// corpus are removed.
+func SnapshotCorpus(t *testing.T, plugin string, factory Factory, corpusDir string) {
+	t.Helper()
+	entries, err := os.ReadDir(corpusDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p Plugin
+	if err := guard("factory", func() error { p = factory(); return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if closer, ok := p.(io.Closer); ok {
+		t.Cleanup(func() { closer.Close() })
+	}
+
+	names := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		names[name+".golden"] = true
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(filepath.Join(corpusDir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			output, err := process(context.Background(), p, string(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			Snapshot(t, plugin+"/"+name, output)
+		})
+	}
+
+	if *updateSnapshots {
+		dir := filepath.Join(SnapshotDir, plugin)
+		stale, _ := filepath.Glob(filepath.Join(dir, "*.golden"))
+		for _, path := range stale {
+			if !names[filepath.Base(path)] {
+				os.Remove(path)
+			}
+		}
+	}
+}
+
+// LineDiff returns a readable diff from want to got: unchanged lines
+// start with two spaces, removed ones with "- " and added ones with "+ ".
+// Unchanged lines more than three lines away from a change are elided.
+func LineDiff(want, got string) string {
+	ops := diffLines(strings.SplitAfter(want, "\n"), strings.SplitAfter(got, "\n"))
+
+	const contextLines = 3
+	keep := make([]bool, len(ops))
+	for i, op := range ops {
+		if op.kind == ' ' {
+			continue
+		}
+		for k := max(0, i-contextLines); k <= min(len(ops)-1, i+contextLines); k++ {
+			keep[k] = true
+		}
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(ops); {
+		if !keep[i] {
+			j := i
+			for j < len(ops) && !keep[j] {
+				j++
+			}
+			if j-i > 1 {
+				fmt.Fprintf(&out, "  ... %d unchanged lines\n", j-i)
+				i = j
+				continue
+			}
+		}
+		out.WriteString(string(ops[i].kind) + " " + showLine(ops[i].line))
+		i++
+	}
+	return out.String()
+}
+
+// showLine makes line end in exactly one newline, marking a missing one
+// and trailing whitespace, which a diff would otherwise hide
+func showLine(line string) string {
+	text, hasNewline := strings.CutSuffix(line, "\n")
+	if trimmed := strings.TrimRight(text, " \t\r"); trimmed != text {
+		text = fmt.Sprintf("%s%q", trimmed, text[len(trimmed):])
+	}
+	if !hasNewline {
+		text += " (no newline at end)"
+	}
+	return text + "\n"
+}
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// diffLines computes a shortest edit script from a to b by longest common
+// subsequence. It is quadratic, which is fine for documents of a few
+// thousand lines.
+func diffLines(a, b []string) []diffOp {
+	if len(a) > 0 && a[len(a)-1] == "" {
+		a = a[:len(a)-1]
+	}
+	if len(b) > 0 && b[len(b)-1] == "" {
+		b = b[:len(b)-1]
+	}
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	return ops
+}
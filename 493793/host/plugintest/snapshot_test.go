@@ -0,0 +1,44 @@
+// snapshot_test.go
+package plugintest
+
+import "testing"
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		name      string
+		want, got string
+		expected  string
+	}{
+		{
+			"Changed line",
+			"a\nb\nc\n",
+			"a\nB\nc\n",
+			"  a\n- b\n+ B\n  c\n",
+		},
+		{
+			"Long unchanged runs are elided",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			"1\n2\n3\n4\n5\n6\n7\n8\nnine\n",
+			"  ... 5 unchanged lines\n  6\n  7\n  8\n- 9\n+ nine\n",
+		},
+		{
+			"Missing newline and trailing spaces",
+			"a \n",
+			"a",
+			"- a\" \"\n+ a (no newline at end)\n",
+		},
+		{
+			"Added lines",
+			"",
+			"x\ny\n",
+			"+ x\n+ y\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LineDiff(tt.want, tt.got); got != tt.expected {
+				t.Errorf("Expected diff\n%s\ngot\n%s", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+// race_test.go
+
+//go:build race
+
+package main
+
+const raceEnabled = true
@@ -0,0 +1,234 @@
+// registry.go
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Factory makes a new, uninitialised instance of a plugin
+type Factory func() Plugin
+
+// Registration describes a plugin the host can load
+type Registration struct {
+	Name    string
+	Version string // semantic version, e.g. "1.2.0"
+	New     Factory
+
+	// Requires names plugins that must be loaded, and so initialised,
+	// before this one
+	Requires []string
+
+	// Manifest is checked against the host when the plugin is loaded;
+	// nil means the plugin predates manifests
+	Manifest *Manifest
+}
+
+// Registry holds the plugins known to the host by name and version. It is
+// safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	plugins map[string][]Registration // by name, oldest version first
+}
+
+// Register adds reg; a name and version can only be registered once
+func (r *Registry) Register(reg Registration) error {
+	if reg.Name == "" || reg.New == nil {
+		return fmt.Errorf("registering plugin %q: name and factory are required", reg.Name)
+	}
+	if _, err := ParseVersion(reg.Version); err != nil {
+		return fmt.Errorf("registering plugin %s: %w", reg.Name, err)
+	}
+	if m := reg.Manifest; m != nil {
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("registering plugin %s: %w", reg.Name, err)
+		}
+		if m.Name != reg.Name || CompareVersions(m.Version, reg.Version) != 0 {
+			return fmt.Errorf("registering plugin %s %s: the manifest is for %s %s", reg.Name, reg.Version, m.Name, m.Version)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.plugins == nil {
+		r.plugins = make(map[string][]Registration)
+	}
+	versions := r.plugins[reg.Name]
+	for _, other := range versions {
+		if CompareVersions(other.Version, reg.Version) == 0 {
+			return fmt.Errorf("plugin %s %s is already registered", reg.Name, reg.Version)
+		}
+	}
+	versions = append(versions, reg)
+	sort.Slice(versions, func(i, j int) bool {
+		return CompareVersions(versions[i].Version, versions[j].Version) < 0
+	})
+	r.plugins[reg.Name] = versions
+	return nil
+}
+
+// MustRegister is Register for plugins built into the host, which panics
+// on error
+func (r *Registry) MustRegister(reg Registration) {
+	if err := r.Register(reg); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the registration of name at version, or of its latest
+// version if version is ""
+func (r *Registry) Lookup(name, version string) (Registration, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	versions := r.plugins[name]
+	if len(versions) == 0 {
+		return Registration{}, fmt.Errorf("plugin %s is not registered", name)
+	}
+	if version == "" {
+		return versions[len(versions)-1], nil
+	}
+	for _, reg := range versions {
+		if CompareVersions(reg.Version, version) == 0 {
+			return reg, nil
+		}
+	}
+	return Registration{}, fmt.Errorf("plugin %s has no version %s", name, version)
+}
+
+// List returns every registration, by name and then version
+func (r *Registry) List() []Registration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var regs []Registration
+	for _, versions := range r.plugins {
+		regs = append(regs, versions...)
+	}
+	sort.Slice(regs, func(i, j int) bool {
+		if regs[i].Name != regs[j].Name {
+			return regs[i].Name < regs[j].Name
+		}
+		return CompareVersions(regs[i].Version, regs[j].Version) < 0
+	})
+	return regs
+}
+
+// Version is a parsed semantic version
+type Version struct {
+	Major, Minor, Patch int
+	Pre                 string // pre-release, e.g. "beta.1"; sorts before the release
+}
+
+// ParseVersion parses "1.2.3", "v1.2.3" or "1.2.3-beta.1". Missing minor
+// and patch numbers are 0, and build metadata after "+" is ignored.
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	rest := strings.TrimPrefix(s, "v")
+	rest, _, _ = strings.Cut(rest, "+")
+	rest, v.Pre, _ = strings.Cut(rest, "-")
+	parts := strings.Split(rest, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid version %q", s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 as v sorts before, with or after w
+func (v Version) Compare(w Version) int {
+	for _, d := range []int{v.Major - w.Major, v.Minor - w.Minor, v.Patch - w.Patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	switch {
+	case v.Pre == w.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case w.Pre == "":
+		return -1
+	}
+	return comparePre(v.Pre, w.Pre)
+}
+
+// comparePre orders pre-releases as semver does: identifier by identifier,
+// numbers by value and before words, and a shorter list first when one is
+// a prefix of the other, so beta.2 < beta.10 < beta.rc
+func comparePre(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, y := as[i], bs[i]
+		numX, numY := isNumeric(x), isNumeric(y)
+		switch {
+		case numX && numY:
+			// Without leading zeros the longer number is the larger
+			x, y = strings.TrimLeft(x, "0"), strings.TrimLeft(y, "0")
+			if len(x) != len(y) {
+				return sign(len(x) - len(y))
+			}
+		case numX:
+			return -1
+		case numY:
+			return 1
+		}
+		if c := strings.Compare(x, y); c != 0 {
+			return c
+		}
+	}
+	return sign(len(as) - len(bs))
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// CompareVersions compares two version strings; invalid ones sort first
+func CompareVersions(a, b string) int {
+	va, errA := ParseVersion(a)
+	vb, errB := ParseVersion(b)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+	return va.Compare(vb)
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,365 @@
+// remote.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrPluginExited is returned, wrapped with the exit status, when a plugin
+// process dies during a call
+var ErrPluginExited = errors.New("plugin process exited")
+
+// RemotePlugin is a Plugin running in its own process and spoken to over
+// the stdio protocol (see ProtocolVersion), so that its crashes and hangs
+// do not take the host down. The process is started by Init, or by the
+// first call. A call that overruns Timeout kills the process; one that
+// finds it dead restarts it and is retried once. After MaxRestarts
+// restarts without a successful call in between, the plugin is given up
+// on.
+//
+// With a Sandbox the process runs with resource limits and a restricted
+// environment, and a call it fails because of them returns a
+// *SandboxError.
+type RemotePlugin struct {
+	Path    string
+	Args    []string
+	Env     []string // added to the host's environment, or to the sandbox's
+	Sandbox *Sandbox
+
+	Timeout     time.Duration // per call; 0 means 10 seconds
+	MaxRestarts int           // 0 means 3
+	Stderr      io.Writer     // the plugin's stderr; nil means os.Stderr
+
+	mu       sync.Mutex
+	proc     *remoteProcess
+	info     PluginInfo
+	config   Config
+	started  bool // the process has been started before
+	restarts int
+	nextID   int64
+}
+
+// remoteProcess is one run of the plugin executable
+type remoteProcess struct {
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	enc       *json.Encoder
+	responses chan rpcMessage
+	exited    chan struct{} // closed once the process has exited
+	waitErr   error         // set before exited is closed
+	stderr    *tailBuffer   // the end of stderr, if sandboxed
+}
+
+// Init starts the plugin and configures it
+func (r *RemotePlugin) Init(config Config) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.config = config
+	return r.start(context.Background())
+}
+
+// Info returns what the plugin reported in its handshake
+func (r *RemotePlugin) Info() PluginInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.info
+}
+
+// Process is ProcessContext without cancellation. The Plugin interface
+// has no room for an error, so failures give ""; the host calls
+// ProcessContext instead.
+func (r *RemotePlugin) Process(data string) string {
+	out, _ := r.ProcessContext(context.Background(), data)
+	return out
+}
+
+func (r *RemotePlugin) ProcessContext(ctx context.Context, data string) (string, error) {
+	var result textResult
+	err := r.call(ctx, "process", processParams{Data: data}, &result)
+	return result.Result, err
+}
+
+// HelloWorld calls the plugin's HelloWorld, if it has the hello capability
+func (r *RemotePlugin) HelloWorld(ctx context.Context, name string) (string, error) {
+	var result textResult
+	err := r.call(ctx, "hello", helloParams{Name: name}, &result)
+	return result.Result, err
+}
+
+// Close asks the plugin to shut down and waits briefly for it to exit
+// before killing it. The process is gone when Close returns.
+func (r *RemotePlugin) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	proc := r.proc
+	if proc == nil {
+		return nil
+	}
+	r.proc = nil
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := r.roundTrip(ctx, proc, "shutdown", nil, nil)
+	proc.stdin.Close()
+	select {
+	case <-proc.exited:
+	case <-ctx.Done():
+		proc.kill()
+		<-proc.exited
+	}
+	return err
+}
+
+// call makes one request, restarting the plugin as needed
+func (r *RemotePlugin) call(ctx context.Context, method string, params, result any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for retried := false; ; retried = true {
+		if r.proc == nil {
+			if err := r.restart(ctx); err != nil {
+				return err
+			}
+		}
+		err := r.roundTrip(ctx, r.proc, method, params, result)
+		switch {
+		case err == nil:
+			r.restarts = 0
+			return nil
+		case errors.Is(err, ErrPluginExited):
+			proc := r.proc
+			r.proc = nil
+			if r.Sandbox != nil {
+				// A plugin that hit a limit would only hit it again
+				if sbErr := r.Sandbox.diagnose(r.name(), proc, err); sbErr.Limit != "" || retried {
+					return sbErr
+				}
+			}
+			if retried {
+				return err
+			}
+		case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+			// A hung plugin cannot take another call
+			proc := r.proc
+			proc.kill()
+			r.proc = nil
+			if r.Sandbox != nil && ctx.Err() == nil {
+				return &SandboxError{
+					Plugin: r.name(),
+					Limit:  LimitTimeout,
+					Detail: fmt.Sprintf("no answer within %v", r.timeout()),
+					Stderr: proc.stderr.String(),
+					Err:    err,
+				}
+			}
+			return err
+		default:
+			return err
+		}
+	}
+}
+
+// restart starts the plugin again, within the restart budget
+func (r *RemotePlugin) restart(ctx context.Context) error {
+	if r.restarts >= r.maxRestarts() {
+		return fmt.Errorf("plugin %s: gave up after %d restarts", r.Path, r.restarts)
+	}
+	if r.started {
+		r.restarts++
+	}
+	return r.start(ctx)
+}
+
+// start launches the process, handshakes and sends the configuration.
+// The caller holds r.mu.
+func (r *RemotePlugin) start(ctx context.Context) error {
+	cmd, cleanup, err := r.command()
+	if err != nil {
+		return fmt.Errorf("starting plugin %s: %w", r.Path, err)
+	}
+	stderr := r.Stderr
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	var tail *tailBuffer
+	if r.Sandbox != nil {
+		tail = &tailBuffer{}
+		stderr = io.MultiWriter(stderr, tail)
+	}
+	cmd.Stderr = stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cleanup()
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cleanup()
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		cleanup()
+		return fmt.Errorf("starting plugin %s: %w", r.Path, err)
+	}
+
+	r.started = true
+
+	proc := &remoteProcess{
+		cmd:       cmd,
+		stdin:     stdin,
+		enc:       json.NewEncoder(stdin),
+		responses: make(chan rpcMessage, 16),
+		exited:    make(chan struct{}),
+		stderr:    tail,
+	}
+	go func() {
+		dec := json.NewDecoder(stdout)
+		for {
+			var msg rpcMessage
+			if err := dec.Decode(&msg); err != nil {
+				break
+			}
+			select {
+			case proc.responses <- msg:
+			default:
+				// Nobody is waiting for this many answers; drop it rather
+				// than block the reaping of the process
+			}
+		}
+		proc.waitErr = cmd.Wait()
+		cleanup()
+		close(proc.exited)
+	}()
+
+	var info PluginInfo
+	if err := r.roundTrip(ctx, proc, "handshake", handshakeParams{Protocol: ProtocolVersion}, &info); err != nil {
+		proc.kill()
+		if r.Sandbox != nil && errors.Is(err, ErrPluginExited) {
+			err = r.Sandbox.diagnose(r.name(), proc, err)
+		}
+		return fmt.Errorf("handshake with plugin %s: %w", r.Path, err)
+	}
+	if info.Protocol != ProtocolVersion {
+		proc.kill()
+		return fmt.Errorf("plugin %s speaks protocol %d, the host %d", r.Path, info.Protocol, ProtocolVersion)
+	}
+	if info.Has("init") {
+		if err := r.roundTrip(ctx, proc, "init", initParams{Config: r.config}, nil); err != nil {
+			proc.kill()
+			return fmt.Errorf("initialising plugin %s: %w", info.Name, err)
+		}
+	}
+	r.info = info
+	r.proc = proc
+	return nil
+}
+
+// command makes the command that runs the plugin, in its sandbox if it
+// has one; cleanup is to be called once the process has exited
+func (r *RemotePlugin) command() (cmd *exec.Cmd, cleanup func(), err error) {
+	if r.Sandbox != nil {
+		return r.Sandbox.command(r.Path, r.Args, r.Env)
+	}
+	cmd = exec.Command(r.Path, r.Args...)
+	cmd.Env = append(os.Environ(), r.Env...)
+	return cmd, func() {}, nil
+}
+
+// roundTrip sends one request to proc and waits for its response within
+// the call timeout
+func (r *RemotePlugin) roundTrip(ctx context.Context, proc *remoteProcess, method string, params, result any) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout())
+	defer cancel()
+
+	r.nextID++
+	id := json.RawMessage(strconv.FormatInt(r.nextID, 10))
+	req := rpcMessage{JSONRPC: "2.0", ID: id, Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = data
+	}
+	if err := proc.enc.Encode(req); err != nil {
+		// The pipe is broken; the process is gone or going
+		return proc.exitError(ctx)
+	}
+
+	for {
+		var resp rpcMessage
+		select {
+		case resp = <-proc.responses:
+		case <-proc.exited:
+			// The answer may have come just before the exit
+			select {
+			case resp = <-proc.responses:
+			default:
+				return proc.exitError(ctx)
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("plugin %s: %s call: %w", r.Path, method, ctx.Err())
+		}
+		if string(resp.ID) != string(id) {
+			continue // a late answer to an abandoned call
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	}
+}
+
+// exitError waits for the process to exit and describes how it did
+func (p *remoteProcess) exitError(ctx context.Context) error {
+	select {
+	case <-p.exited:
+		return fmt.Errorf("%w: %v", ErrPluginExited, p.waitErr)
+	case <-ctx.Done():
+		p.kill()
+		return fmt.Errorf("%w: stopped responding", ErrPluginExited)
+	}
+}
+
+// kill stops the process; the reader goroutine reaps it
+func (p *remoteProcess) kill() {
+	p.cmd.Process.Kill()
+}
+
+// name is the plugin's name, for errors
+func (r *RemotePlugin) name() string {
+	if r.info.Name != "" {
+		return r.info.Name
+	}
+	return filepath.Base(r.Path)
+}
+
+func (r *RemotePlugin) timeout() time.Duration {
+	if r.Sandbox != nil && r.Sandbox.Timeout > 0 {
+		return r.Sandbox.Timeout
+	}
+	if r.Timeout <= 0 {
+		return 10 * time.Second
+	}
+	return r.Timeout
+}
+
+func (r *RemotePlugin) maxRestarts() int {
+	if r.MaxRestarts <= 0 {
+		return 3
+	}
+	return r.MaxRestarts
+}
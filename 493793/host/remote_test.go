@@ -0,0 +1,223 @@
+// remote_test.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary double as a plugin executable: with
+// HOST_TEST_SERVE set, or run as "serve <plugin> <version>" like the host
+// runs itself for a sandbox, it serves that plugin over stdio instead of
+// running the tests
+func TestMain(m *testing.M) {
+	name, version := os.Getenv("HOST_TEST_SERVE"), ""
+	if len(os.Args) == 4 && os.Args[1] == "serve" {
+		name, version = os.Args[2], os.Args[3]
+	}
+	if name != "" {
+		if err := serve(testRegistry(), name, version); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// testRegistry has the built-in plugins and some that misbehave on demand
+func testRegistry() *Registry {
+	registry := &Registry{}
+	RegisterBuiltins(registry)
+	registry.MustRegister(Registration{Name: "crashy", Version: "1.0.0", New: func() Plugin {
+		return FuncPlugin(func(data string) string {
+			if data == "crash" {
+				os.Exit(3)
+			}
+			return strings.ToUpper(data)
+		})
+	}})
+	registry.MustRegister(Registration{Name: "sleepy", Version: "1.0.0", New: func() Plugin {
+		return FuncPlugin(func(data string) string {
+			if data == "hang" {
+				time.Sleep(time.Minute)
+			}
+			return data
+		})
+	}})
+	registry.MustRegister(Registration{Name: "panicky", Version: "1.0.0", New: func() Plugin {
+		return FuncPlugin(func(data string) string { panic("boom") })
+	}})
+	registry.MustRegister(Registration{Name: "greedy", Version: "1.0.0", New: func() Plugin {
+		return FuncPlugin(greedy)
+	}})
+	return registry
+}
+
+// greedy uses up what data asks for, to test sandbox limits: "cpu" spins,
+// "memory" allocates 4 GiB, "files" opens files until it cannot and
+// "environment" describes its working directory and environment
+func greedy(data string) string {
+	switch data {
+	case "cpu":
+		for {
+		}
+	case "memory":
+		hog := make([]byte, 4<<30)
+		for i := range hog {
+			hog[i] = 1
+		}
+		return strconv.Itoa(len(hog))
+	case "files":
+		n := 0
+		for ; n < 1000; n++ {
+			f, err := os.Open(os.DevNull)
+			if err != nil {
+				break
+			}
+			defer f.Close()
+		}
+		return strconv.Itoa(n)
+	case "environment":
+		dir, _ := os.Getwd()
+		return dir + "\n" + strings.Join(os.Environ(), "\n")
+	}
+	return data
+}
+
+// remoteTestPlugin runs the named test plugin out of process
+func remoteTestPlugin(t *testing.T, name string) *RemotePlugin {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &RemotePlugin{Path: exe, Env: []string{"HOST_TEST_SERVE=" + name}, Timeout: 5 * time.Second}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestRemotePluginHandshakeAndCalls(t *testing.T) {
+	p := remoteTestPlugin(t, "hello")
+	if err := p.Init(nil); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	info := p.Info()
+	if info.Name != "hello" || info.Version != "1.0.0" || !info.Has("hello") || !info.Has("process") {
+		t.Errorf("Unexpected handshake %+v", info)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Normal Input", "Alice", "Hello, Alice!"},
+		{"Empty Input", "", "Hello, !"},
+		{"Unicode Input", "こんにちは", "Hello, こんにちは!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := p.HelloWorld(context.Background(), tt.input)
+			if err != nil || result != tt.expected {
+				t.Errorf("Expected %s, got %s (%v)", tt.expected, result, err)
+			}
+		})
+	}
+}
+
+func TestRemotePluginConfig(t *testing.T) {
+	p := remoteTestPlugin(t, "replace")
+	if err := p.Init(Config{"old": "a", "new": "o"}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if result, err := p.ProcessContext(context.Background(), "banana"); result != "bonono" || err != nil {
+		t.Errorf("Expected bonono, got %s (%v)", result, err)
+	}
+
+	p = remoteTestPlugin(t, "replace")
+	var rpcErr *RPCError
+	if err := p.Init(Config{}); !errors.As(err, &rpcErr) || rpcErr.Code != CodePluginError {
+		t.Errorf("Expected Init to fail with a plugin error, got %v", err)
+	}
+}
+
+func TestRemotePluginRestartsAfterCrash(t *testing.T) {
+	p := remoteTestPlugin(t, "crashy")
+	if _, err := p.ProcessContext(context.Background(), "crash"); !errors.Is(err, ErrPluginExited) {
+		t.Fatalf("Expected ErrPluginExited, got %v", err)
+	}
+	if result, err := p.ProcessContext(context.Background(), "back"); result != "BACK" || err != nil {
+		t.Errorf("Expected the restarted plugin to answer BACK, got %s (%v)", result, err)
+	}
+}
+
+func TestRemotePluginGivesUp(t *testing.T) {
+	p := remoteTestPlugin(t, "crashy")
+	p.MaxRestarts = 2
+	var err error
+	for range 4 {
+		_, err = p.ProcessContext(context.Background(), "crash")
+	}
+	if err == nil || !strings.Contains(err.Error(), "gave up after 2 restarts") {
+		t.Errorf("Expected the host to give up on the plugin, got %v", err)
+	}
+}
+
+func TestRemotePluginTimeout(t *testing.T) {
+	p := remoteTestPlugin(t, "sleepy")
+	p.Timeout = 200 * time.Millisecond
+
+	start := time.Now()
+	if _, err := p.ProcessContext(context.Background(), "hang"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the call to be abandoned at the timeout, took %v", elapsed)
+	}
+	if result, err := p.ProcessContext(context.Background(), "awake"); result != "awake" || err != nil {
+		t.Errorf("Expected a fresh process to answer, got %s (%v)", result, err)
+	}
+}
+
+func TestRemotePluginPanic(t *testing.T) {
+	p := remoteTestPlugin(t, "panicky")
+	_, err := p.ProcessContext(context.Background(), "x")
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodePluginError || !strings.Contains(rpcErr.Message, "boom") {
+		t.Errorf("Expected the panic as a plugin error, got %v", err)
+	}
+}
+
+func TestHostLoadsRemoteSpec(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOST_TEST_SERVE", "upper")
+	host := NewHost(testRegistry())
+	if err := host.Load([]Spec{{Name: "upper", Exec: exe}, {Name: "example"}}); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	defer host.Close()
+
+	chain, _ := host.Chain("upper", "example")
+	result, err := chain.Process(context.Background(), "text")
+	if result != "TEXT processed" || err != nil {
+		t.Errorf("Expected %q, got %q (%v)", "TEXT processed", result, err)
+	}
+	if v := chain[0].Version; v != "1.0.0" {
+		t.Errorf("Expected the version from the handshake, got %s", v)
+	}
+
+	err = NewHost(testRegistry()).Load([]Spec{{Name: "shout", Exec: exe}})
+	if err == nil || !strings.Contains(err.Error(), "reports its name as upper") {
+		t.Errorf("Expected a name mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+// rpc.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ProtocolVersion is the version of the stdio protocol spoken here. A
+// plugin is an executable that reads JSON-RPC 2.0 requests from stdin and
+// writes responses to stdout, one JSON object per line. The methods are:
+//
+//	handshake {"protocol": 1}  -> PluginInfo
+//	init      {"config": {..}} -> null       (capability "init")
+//	process   {"data": ".."}   -> {"result": ".."}
+//	hello     {"name": ".."}   -> {"result": ".."} (capability "hello")
+//	shutdown                   -> null, then the plugin exits
+const ProtocolVersion = 1
+
+// Error codes: the JSON-RPC 2.0 ones and those of this protocol
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodePluginError    = -32000 // the plugin failed or panicked
+	CodeIncompatible   = -32001 // protocol versions do not match
+)
+
+// PluginInfo is what a plugin reports about itself in the handshake
+type PluginInfo struct {
+	Name         string   `json:"name"`
+	Version      string   `json:"version"`
+	Protocol     int      `json:"protocol"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// Has reports whether the plugin has the capability
+func (i PluginInfo) Has(capability string) bool {
+	for _, c := range i.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// RPCError is an error returned by a plugin over the protocol
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// rpcMessage is a JSON-RPC 2.0 request, notification or response
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // absent for notifications
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+type handshakeParams struct {
+	Protocol int `json:"protocol"`
+}
+
+type initParams struct {
+	Config Config `json:"config"`
+}
+
+type processParams struct {
+	Data string `json:"data"`
+}
+
+type helloParams struct {
+	Name string `json:"name"`
+}
+
+type textResult struct {
+	Result string `json:"result"`
+}
+
+// Greeter is implemented by plugins with the HelloWorld function of
+// 493793/a1 and 493793/ideal2
+type Greeter interface {
+	HelloWorld(name string) string
+}
+
+// Serve serves p over stdin and stdout until the host shuts it down or
+// closes stdin; it is all the main function of a plugin executable needs:
+//
+//	func main() {
+//		if err := Serve("upper", "1.0.0", FuncPlugin(strings.ToUpper)); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+//
+// Anything the plugin prints to os.Stdout goes to stderr instead, so that
+// it cannot corrupt the protocol.
+func Serve(name, version string, p Plugin) error {
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = out }()
+	return ServeIO(os.Stdin, out, name, version, p)
+}
+
+// ServeIO is Serve over r and w
+func ServeIO(r io.Reader, w io.Writer, name, version string, p Plugin) error {
+	info := PluginInfo{Name: name, Version: version, Protocol: ProtocolVersion, Capabilities: []string{"process"}}
+	if _, ok := p.(Initializer); ok {
+		info.Capabilities = append(info.Capabilities, "init")
+	}
+	if _, ok := p.(Greeter); ok {
+		info.Capabilities = append(info.Capabilities, "hello")
+	}
+
+	dec := json.NewDecoder(r)
+	enc := json.NewEncoder(w)
+	for {
+		var msg rpcMessage
+		if err := dec.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			// The stream cannot be resynchronised after a syntax error
+			enc.Encode(rpcMessage{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &RPCError{Code: CodeParseError, Message: err.Error()}})
+			return err
+		}
+
+		result, rpcErr := dispatch(info, p, msg)
+		if msg.ID == nil {
+			continue // a notification gets no response
+		}
+		resp := rpcMessage{JSONRPC: "2.0", ID: msg.ID, Error: rpcErr}
+		if rpcErr == nil {
+			data, err := json.Marshal(result)
+			if err != nil {
+				resp.Error = &RPCError{Code: CodePluginError, Message: err.Error()}
+			}
+			resp.Result = data
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+		if msg.Method == "shutdown" {
+			return nil
+		}
+	}
+}
+
+// dispatch runs one request against p
+func dispatch(info PluginInfo, p Plugin, msg rpcMessage) (any, *RPCError) {
+	if msg.JSONRPC != "2.0" || msg.Method == "" {
+		return nil, &RPCError{Code: CodeInvalidRequest, Message: "not a JSON-RPC 2.0 request"}
+	}
+	decode := func(params any) *RPCError {
+		if len(msg.Params) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			return &RPCError{Code: CodeInvalidParams, Message: err.Error()}
+		}
+		return nil
+	}
+
+	switch msg.Method {
+	case "handshake":
+		var params handshakeParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		if params.Protocol != ProtocolVersion {
+			return nil, &RPCError{Code: CodeIncompatible, Message: fmt.Sprintf("host speaks protocol %d, plugin speaks %d", params.Protocol, ProtocolVersion)}
+		}
+		return info, nil
+
+	case "init":
+		var params initParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		initializer, ok := p.(Initializer)
+		if !ok {
+			return nil, nil
+		}
+		return nil, pluginError(guard(info.Name, func() error { return initializer.Init(params.Config) }))
+
+	case "process":
+		var params processParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		var out string
+		err := guard(info.Name, func() error {
+			out = p.Process(params.Data)
+			return nil
+		})
+		if err != nil {
+			return nil, pluginError(err)
+		}
+		return textResult{Result: out}, nil
+
+	case "hello":
+		greeter, ok := p.(Greeter)
+		if !ok {
+			return nil, &RPCError{Code: CodeMethodNotFound, Message: "plugin has no hello capability"}
+		}
+		var params helloParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		var out string
+		err := guard(info.Name, func() error {
+			out = greeter.HelloWorld(params.Name)
+			return nil
+		})
+		if err != nil {
+			return nil, pluginError(err)
+		}
+		return textResult{Result: out}, nil
+
+	case "shutdown":
+		if closer, ok := p.(io.Closer); ok {
+			return nil, pluginError(guard(info.Name, closer.Close))
+		}
+		return nil, nil
+	}
+	return nil, &RPCError{Code: CodeMethodNotFound, Message: "unknown method " + msg.Method}
+}
+
+// pluginError reports a plugin's failure over the protocol
+func pluginError(err error) *RPCError {
+	if err == nil {
+		return nil
+	}
+	rpcErr := &RPCError{Code: CodePluginError, Message: err.Error()}
+	var p *PanicError
+	if errors.As(err, &p) {
+		rpcErr.Data = map[string]any{"panic": true}
+	}
+	return rpcErr
+}
@@ -0,0 +1,199 @@
+// sandbox.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sandbox limits what a RemotePlugin's process may use. The limits are
+// rlimits, set before the plugin's code starts, so they only work on
+// Linux; elsewhere starting a sandboxed plugin fails. A Spec with a
+// sandbox and no Exec runs a built-in plugin in a sandboxed copy of the
+// host:
+//
+//	{"name": "upper", "sandbox": {"cpu": "2s", "memory_mb": 512, "open_files": 32, "timeout": "5s"}}
+type Sandbox struct {
+	CPU       time.Duration // CPU time over the process's life; 0 means no limit
+	MemoryMB  int           // address space; 0 means no limit
+	OpenFiles int           // open file descriptors; 0 means no limit
+	Timeout   time.Duration // wall clock per call; 0 means the plugin's Timeout
+
+	// Dir is the working directory; "" means a fresh empty directory,
+	// removed when the process exits
+	Dir string
+
+	// Env is the whole environment apart from PATH, HOME, TMPDIR and LANG,
+	// which are always set to safe values, whatever Env says; the host's
+	// own is not passed on
+	Env []string
+}
+
+// UnmarshalJSON reads the durations as strings such as "1.5s"
+func (s *Sandbox) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		CPU       string   `json:"cpu"`
+		MemoryMB  int      `json:"memory_mb"`
+		OpenFiles int      `json:"open_files"`
+		Timeout   string   `json:"timeout"`
+		Dir       string   `json:"dir"`
+		Env       []string `json:"env"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*s = Sandbox{MemoryMB: raw.MemoryMB, OpenFiles: raw.OpenFiles, Dir: raw.Dir, Env: raw.Env}
+	for _, d := range []struct {
+		text string
+		dst  *time.Duration
+	}{{raw.CPU, &s.CPU}, {raw.Timeout, &s.Timeout}} {
+		if d.text == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.text)
+		if err != nil {
+			return fmt.Errorf("sandbox: %w", err)
+		}
+		*d.dst = v
+	}
+	return nil
+}
+
+// Limits a SandboxError can report
+const (
+	LimitCPU       = "cpu"
+	LimitMemory    = "memory"
+	LimitOpenFiles = "open files"
+	LimitTimeout   = "timeout"
+)
+
+// SandboxError reports a sandboxed plugin that was stopped by one of its
+// limits or died some other way
+type SandboxError struct {
+	Plugin string
+	Limit  string // one of the Limit constants, or "" if no limit was hit
+	Detail string // e.g. "used 1.01s of CPU, limit 1s"
+	Status string // how the process ended, e.g. "signal: killed"
+	Stderr string // the end of the plugin's stderr
+	Err    error  // ErrPluginExited or context.DeadlineExceeded
+}
+
+func (e *SandboxError) Error() string {
+	var b strings.Builder
+	if e.Limit != "" {
+		fmt.Fprintf(&b, "sandboxed plugin %s exceeded its %s limit", e.Plugin, e.Limit)
+	} else {
+		fmt.Fprintf(&b, "sandboxed plugin %s failed", e.Plugin)
+	}
+	if e.Detail != "" {
+		b.WriteString(" (" + e.Detail + ")")
+	}
+	if e.Status != "" {
+		b.WriteString(": " + e.Status)
+	}
+	return b.String()
+}
+
+func (e *SandboxError) Unwrap() error {
+	return e.Err
+}
+
+// diagnose turns the death of a sandboxed process, reported as err, into
+// a *SandboxError, working out which limit, if any, killed it
+func (s *Sandbox) diagnose(plugin string, proc *remoteProcess, err error) *SandboxError {
+	sbErr := &SandboxError{Plugin: plugin, Stderr: proc.stderr.String(), Err: err}
+	state := proc.cmd.ProcessState
+	if state == nil {
+		return sbErr
+	}
+	sbErr.Status = state.String()
+	cpu := state.UserTime() + state.SystemTime()
+	stderr := strings.ToLower(sbErr.Stderr)
+	switch {
+	case s.CPU > 0 && cpu >= s.CPU:
+		sbErr.Limit = LimitCPU
+		sbErr.Detail = fmt.Sprintf("used %v of CPU, limit %v", cpu.Round(10*time.Millisecond), s.CPU)
+	case s.MemoryMB > 0 && (strings.Contains(stderr, "out of memory") || strings.Contains(stderr, "cannot allocate memory")):
+		sbErr.Limit = LimitMemory
+		sbErr.Detail = fmt.Sprintf("limit %d MB", s.MemoryMB)
+	case s.OpenFiles > 0 && strings.Contains(stderr, "too many open files"):
+		sbErr.Limit = LimitOpenFiles
+		sbErr.Detail = fmt.Sprintf("limit %d", s.OpenFiles)
+	}
+	return sbErr
+}
+
+// command makes the command that runs path with args inside the sandbox.
+// cleanup removes what it set up and is called once the process has
+// exited.
+func (s *Sandbox) command(path string, args []string, env []string) (cmd *exec.Cmd, cleanup func(), err error) {
+	// A relative path is relative to the host's directory, not the
+	// sandbox's; a bare name is looked up in PATH
+	if strings.ContainsRune(path, '/') {
+		if path, err = filepath.Abs(path); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	dir := s.Dir
+	cleanup = func() {}
+	if dir == "" {
+		if dir, err = os.MkdirTemp("", "plugin-sandbox-"); err != nil {
+			return nil, nil, err
+		}
+		cleanup = func() { os.RemoveAll(dir) }
+	}
+
+	cmd, err = sandboxCommand(s, path, args)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	cmd.Dir = dir
+	// The last of duplicate variables wins, so the safe values and the
+	// sandbox's own settings come after those of the spec
+	own := cmd.Env
+	cmd.Env = append(append([]string(nil), s.Env...), env...)
+	cmd.Env = append(cmd.Env,
+		"PATH=/usr/local/bin:/usr/bin:/bin",
+		"HOME="+dir,
+		"TMPDIR="+dir,
+		"LANG=C.UTF-8",
+	)
+	cmd.Env = append(cmd.Env, own...)
+	return cmd, cleanup, nil
+}
+
+// tailBuffer keeps the last bytes written to it, for the stderr of a
+// sandboxed plugin
+type tailBuffer struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+const tailSize = 64 << 10
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if len(b.data) > tailSize {
+		b.data = append([]byte(nil), b.data[len(b.data)-tailSize:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	if b == nil {
+		return ""
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.data)
+}
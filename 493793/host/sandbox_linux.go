@@ -0,0 +1,96 @@
+// sandbox_linux.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// sandboxEnv carries the limits to the trampoline
+const sandboxEnv = "PLUGIN_SANDBOX_LIMITS"
+
+// A sandboxed plugin is started through a trampoline: the host runs its
+// own executable with the limits in sandboxEnv, and before anything else
+// that process sets the limits on itself and execs the plugin, which
+// inherits them. This way the plugin never runs unlimited, not even for
+// a moment.
+func init() {
+	if limits, ok := os.LookupEnv(sandboxEnv); ok {
+		sandboxExec(limits, os.Args[1:])
+	}
+}
+
+// sandboxCommand makes the trampoline command for path
+func sandboxCommand(s *Sandbox, path string, args []string) (*exec.Cmd, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	var limits []string
+	if s.CPU > 0 {
+		// Whole seconds, rounded up; the hard limit is a second later, as
+		// Go programs ignore the SIGXCPU sent at the soft one
+		secs := (s.CPU.Nanoseconds() + 1e9 - 1) / 1e9
+		limits = append(limits, fmt.Sprintf("%d=%d", syscall.RLIMIT_CPU, secs))
+	}
+	if s.MemoryMB > 0 {
+		limits = append(limits, fmt.Sprintf("%d=%d", syscall.RLIMIT_AS, int64(s.MemoryMB)<<20))
+	}
+	if s.OpenFiles > 0 {
+		limits = append(limits, fmt.Sprintf("%d=%d", syscall.RLIMIT_NOFILE, s.OpenFiles))
+	}
+
+	cmd := exec.Command(self, append([]string{path}, args...)...)
+	cmd.Env = []string{sandboxEnv + "=" + strings.Join(limits, ",")}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid:   true,            // keep terminal signals for the host
+		Pdeathsig: syscall.SIGKILL, // do not outlive the host
+	}
+	return cmd, nil
+}
+
+// sandboxExec is the trampoline: it applies limits, a comma-separated
+// list of resource=value, and execs argv. It never returns.
+func sandboxExec(limits string, argv []string) {
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, "sandbox:", err)
+		os.Exit(126)
+	}
+	if len(argv) == 0 {
+		fail(fmt.Errorf("no command"))
+	}
+	for _, limit := range strings.Split(limits, ",") {
+		if limit == "" {
+			continue
+		}
+		resource, value, _ := strings.Cut(limit, "=")
+		r, err1 := strconv.Atoi(resource)
+		v, err2 := strconv.ParseUint(value, 10, 64)
+		if err1 != nil || err2 != nil {
+			fail(fmt.Errorf("bad limit %q", limit))
+		}
+		rlimit := syscall.Rlimit{Cur: v, Max: v}
+		if r == syscall.RLIMIT_CPU {
+			rlimit.Max = v + 1
+		}
+		if err := syscall.Setrlimit(r, &rlimit); err != nil {
+			fail(fmt.Errorf("setting limit %q: %w", limit, err))
+		}
+	}
+
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, sandboxEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		fail(err)
+	}
+	fail(syscall.Exec(path, argv, env))
+}
@@ -0,0 +1,191 @@
+// sandbox_linux_test.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sandboxedTestPlugin runs the named test plugin in sandbox, keeping its
+// crash dumps out of the test output
+func sandboxedTestPlugin(t *testing.T, name string, sandbox *Sandbox) *RemotePlugin {
+	t.Helper()
+	p := remoteTestPlugin(t, name)
+	p.Sandbox = sandbox
+	p.Stderr = io.Discard
+	return p
+}
+
+func TestSandboxLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		plugin  string
+		sandbox Sandbox
+		input   string
+		limit   string
+	}{
+		{"CPU", "greedy", Sandbox{CPU: time.Second, Timeout: 30 * time.Second}, "cpu", LimitCPU},
+		{"Memory", "greedy", Sandbox{MemoryMB: 2048}, "memory", LimitMemory},
+		{"Wall clock", "sleepy", Sandbox{Timeout: 200 * time.Millisecond}, "hang", LimitTimeout},
+		{"No limit hit", "crashy", Sandbox{CPU: time.Minute}, "crash", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.limit == LimitMemory && raceEnabled {
+				t.Skip("the race detector needs more address space than the limit")
+			}
+			p := sandboxedTestPlugin(t, tt.plugin, &tt.sandbox)
+			if err := p.Init(nil); err != nil {
+				t.Fatalf("Init failed: %v", err)
+			}
+			if _, err := p.ProcessContext(context.Background(), "within limits"); err != nil {
+				t.Fatalf("Expected no error within the limits, got %v", err)
+			}
+
+			_, err := p.ProcessContext(context.Background(), tt.input)
+			var sbErr *SandboxError
+			if !errors.As(err, &sbErr) {
+				t.Fatalf("Expected a *SandboxError, got %v", err)
+			}
+			if sbErr.Limit != tt.limit || sbErr.Plugin != tt.plugin {
+				t.Errorf("Expected plugin %s to exceed its %q limit, got %v", tt.plugin, tt.limit, err)
+			}
+			if tt.limit == LimitTimeout && !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Expected a timeout to be a DeadlineExceeded, got %v", err)
+			}
+
+			// The plugin is restarted for the next call
+			if _, err := p.ProcessContext(context.Background(), "again"); err != nil {
+				t.Errorf("Expected no error after a restart, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSandboxOpenFiles(t *testing.T) {
+	p := sandboxedTestPlugin(t, "greedy", &Sandbox{OpenFiles: 32})
+	out, err := p.ProcessContext(context.Background(), "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := strconv.Atoi(out); n == 0 || n >= 32 {
+		t.Errorf("Expected fewer than 32 files to open, %s did", out)
+	}
+}
+
+func TestSandboxEnvironment(t *testing.T) {
+	t.Setenv("HOST_SECRET", "hunter2")
+
+	// A fresh working directory, removed afterwards
+	p := sandboxedTestPlugin(t, "greedy", &Sandbox{Env: []string{"GREETING=hi", "PATH=/tmp/evil", "HOME=/root"}})
+	out, err := p.ProcessContext(context.Background(), "environment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, env, _ := strings.Cut(out, "\n")
+	if cwd, _ := os.Getwd(); dir == cwd || !strings.Contains(filepath.Base(dir), "plugin-sandbox-") {
+		t.Errorf("Expected a fresh working directory, got %s", dir)
+	}
+	for _, expected := range []string{"GREETING=hi", "HOME=" + dir, "PATH=/usr/local/bin:/usr/bin:/bin", "HOST_TEST_SERVE=greedy"} {
+		if !strings.Contains(env+"\n", expected+"\n") {
+			t.Errorf("Expected %s in the environment, got\n%s", expected, env)
+		}
+	}
+	for _, unexpected := range []string{"HOST_SECRET", sandboxEnv, "/tmp/evil", "HOME=/root"} {
+		if strings.Contains(env, unexpected) {
+			t.Errorf("Expected no %s in the environment, got\n%s", unexpected, env)
+		}
+	}
+	p.Close()
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected %s to be removed, got %v", dir, err)
+	}
+
+	// A given working directory
+	given := t.TempDir()
+	p = sandboxedTestPlugin(t, "greedy", &Sandbox{Dir: given})
+	if out, _ := p.ProcessContext(context.Background(), "environment"); !strings.HasPrefix(out, given+"\n") {
+		t.Errorf("Expected to run in %s, got %q", given, out)
+	}
+}
+
+func TestSandboxRelativeExec(t *testing.T) {
+	// A relative Exec, as discovery makes it, resolves against the host's
+	// directory even though the plugin runs in a fresh one
+	p := sandboxedTestPlugin(t, "upper", &Sandbox{})
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(p.Path, filepath.Join(dir, "bin", "plug")); err != nil {
+		t.Fatal(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) })
+
+	p.Path = "bin/plug"
+	if out, err := p.ProcessContext(context.Background(), "shout"); err != nil || out != "SHOUT" {
+		t.Errorf("Expected SHOUT from %s, got %q, %v", p.Path, out, err)
+	}
+}
+
+func TestHostLoadsSandboxedBuiltin(t *testing.T) {
+	var spec Spec
+	err := json.Unmarshal([]byte(`{"name": "upper", "sandbox": {"cpu": "5s", "memory_mb": 4096, "timeout": "1.5s"}}`), &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Sandbox{CPU: 5 * time.Second, MemoryMB: 4096, Timeout: 1500 * time.Millisecond}
+	if spec.Sandbox == nil || spec.Sandbox.CPU != expected.CPU || spec.Sandbox.MemoryMB != expected.MemoryMB || spec.Sandbox.Timeout != expected.Timeout {
+		t.Fatalf("Expected sandbox %+v, got %+v", expected, spec.Sandbox)
+	}
+	if raceEnabled {
+		spec.Sandbox.MemoryMB = 0
+	}
+
+	host := NewHost(testRegistry())
+	if err := host.Load([]Spec{spec}); err != nil {
+		t.Fatal(err)
+	}
+	defer host.Close()
+	in := host.Loaded()[0]
+	if _, ok := in.Plugin.(*RemotePlugin); !ok || in.Version != "1.0.0" {
+		t.Fatalf("Expected upper 1.0.0 out of process, got %s %T", in.Version, in.Plugin)
+	}
+	if out, err := in.Process(context.Background(), "shout"); err != nil || out != "SHOUT" {
+		t.Errorf("Expected SHOUT, got %q, %v", out, err)
+	}
+}
+
+func TestSandboxErrorMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      *SandboxError
+		expected string
+	}{
+		{"Limit", &SandboxError{Plugin: "greedy", Limit: LimitCPU, Detail: "used 2s of CPU, limit 1s", Status: "signal: killed"},
+			"sandboxed plugin greedy exceeded its cpu limit (used 2s of CPU, limit 1s): signal: killed"},
+		{"Crash", &SandboxError{Plugin: "crashy", Status: "exit status 3"}, "sandboxed plugin crashy failed: exit status 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
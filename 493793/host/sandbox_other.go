@@ -0,0 +1,14 @@
+// sandbox_other.go
+
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func sandboxCommand(s *Sandbox, path string, args []string) (*exec.Cmd, error) {
+	return nil, errors.New("plugin sandboxes are only supported on Linux")
+}
@@ -0,0 +1,50 @@
+// snapshot_test.go
+package main
+
+import (
+	"context"
+	"testing"
+
+	"snippets/493793/host/plugintest"
+)
+
+const corpusDir = "testdata/corpus"
+
+// Run go test -update to record new snapshots after changing a plugin,
+// then review them with git diff
+func TestBuiltinSnapshots(t *testing.T) {
+	configs := map[string]Config{
+		"prefix":  {"text": "> "},
+		"replace": {"old": "world", "new": "plugins"},
+	}
+	registry := &Registry{}
+	RegisterBuiltins(registry)
+	for _, reg := range registry.List() {
+		t.Run(reg.Name, func(t *testing.T) {
+			plugintest.SnapshotCorpus(t, reg.Name, configured(reg.New, configs[reg.Name]), corpusDir)
+		})
+	}
+}
+
+func TestChainSnapshot(t *testing.T) {
+	registry := &Registry{}
+	RegisterBuiltins(registry)
+	host := NewHost(registry)
+	err := host.Load([]Spec{
+		{Name: "trim"},
+		{Name: "replace", Config: Config{"old": "world", "new": "plugins"}},
+		{Name: "prefix", Config: Config{"text": "| "}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer host.Close()
+
+	chain, _ := host.Chain()
+	input := "  Hello, world!\nGoodbye, world.  \n"
+	output, err := chain.Process(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugintest.Snapshot(t, "chain/trim-replace-prefix", output)
+}
@@ -0,0 +1,286 @@
+// feedback.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Feedback is one user's rating of a plugin version
+type Feedback struct {
+	Plugin      string      `json:"plugin"`
+	Version     string      `json:"version"`
+	Rating      int         `json:"rating"` // 1 to 5
+	Text        string      `json:"text,omitempty"`
+	Environment Environment `json:"environment"`
+	Time        time.Time   `json:"time"`
+}
+
+// Environment describes where the plugin was used
+type Environment struct {
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	GoVersion string `json:"go_version"`
+}
+
+// CurrentEnvironment returns the environment of this process
+func CurrentEnvironment() Environment {
+	return Environment{OS: runtime.GOOS, Arch: runtime.GOARCH, GoVersion: runtime.Version()}
+}
+
+// maxFeedbackText is the longest free text accepted, in bytes
+const maxFeedbackText = 4000
+
+// Validate checks that the feedback can be stored
+func (f Feedback) Validate() error {
+	switch {
+	case f.Plugin == "":
+		return errors.New("feedback: plugin name is required")
+	case f.Version == "":
+		return errors.New("feedback: plugin version is required")
+	case f.Rating < 1 || f.Rating > 5:
+		return fmt.Errorf("feedback: rating must be from 1 to 5, got %d", f.Rating)
+	case len(f.Text) > maxFeedbackText:
+		return fmt.Errorf("feedback: text is %d bytes, the limit is %d", len(f.Text), maxFeedbackText)
+	}
+	return nil
+}
+
+// FeedbackStore keeps feedback in an append-only file with one JSON
+// object per line
+type FeedbackStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Add validates f and appends it to the store, stamping it with the
+// current time if it has none
+func (s *FeedbackStore) Add(f Feedback) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	if f.Time.IsZero() {
+		f.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.OpenFile(s.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	// One write per entry, so that appends from other processes do not
+	// interleave with it
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// All returns every entry in the store, oldest first. A store that does
+// not exist yet is empty. A last line cut short, as by a crash during
+// Add, is skipped.
+func (s *FeedbackStore) All() ([]Feedback, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Feedback
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var f Feedback
+		if err := json.Unmarshal(line, &f); err != nil {
+			if n == bytes.Count(data, []byte("\n"))+1 {
+				break // the unfinished last line
+			}
+			return nil, fmt.Errorf("%s:%d: %w", s.Path, n, err)
+		}
+		entries = append(entries, f)
+	}
+	return entries, scanner.Err()
+}
+
+// VersionReport aggregates the ratings of one plugin version
+type VersionReport struct {
+	Plugin  string  `json:"plugin"`
+	Version string  `json:"version"`
+	Count   int     `json:"count"`
+	Mean    float64 `json:"mean"`
+	Ratings [5]int  `json:"ratings"` // how many of each rating, 1 first
+}
+
+// Report aggregates entries per plugin version, sorted by plugin and then
+// version
+func Report(entries []Feedback) []VersionReport {
+	type key struct{ plugin, version string }
+	byVersion := make(map[key]*VersionReport)
+	for _, f := range entries {
+		k := key{f.Plugin, f.Version}
+		r, ok := byVersion[k]
+		if !ok {
+			r = &VersionReport{Plugin: f.Plugin, Version: f.Version}
+			byVersion[k] = r
+		}
+		if f.Rating >= 1 && f.Rating <= 5 {
+			r.Count++
+			r.Ratings[f.Rating-1]++
+		}
+	}
+
+	reports := make([]VersionReport, 0, len(byVersion))
+	for _, r := range byVersion {
+		sum := 0
+		for i, n := range r.Ratings {
+			sum += (i + 1) * n
+		}
+		if r.Count > 0 {
+			r.Mean = float64(sum) / float64(r.Count)
+		}
+		reports = append(reports, *r)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Plugin != reports[j].Plugin {
+			return reports[i].Plugin < reports[j].Plugin
+		}
+		return compareVersions(reports[i].Version, reports[j].Version) < 0
+	})
+	return reports
+}
+
+// compareVersions orders dotted versions by their numbers, so that 1.10
+// comes after 1.9; parts that are not numbers compare as text
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, errX := strconv.Atoi(as[i])
+		y, errY := strconv.Atoi(bs[i])
+		switch {
+		case errX == nil && errY == nil && x != y:
+			if x < y {
+				return -1
+			}
+			return 1
+		case errX != nil || errY != nil:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return len(as) - len(bs)
+}
+
+// WriteReport writes reports as a table
+func WriteReport(w io.Writer, reports []VersionReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PLUGIN\tVERSION\tRATINGS\tMEAN\t1\t2\t3\t4\t5")
+	for _, r := range reports {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.2f", r.Plugin, r.Version, r.Count, r.Mean)
+		for _, n := range r.Ratings {
+			fmt.Fprintf(tw, "\t%d", n)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// FeedbackHandler serves the store over HTTP: POST /feedback adds a JSON
+// Feedback and answers with it as stored, and GET /report returns the
+// Report as JSON
+func FeedbackHandler(store *FeedbackStore) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feedback", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		var f Feedback
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&f); err != nil {
+			http.Error(w, "invalid feedback: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// The server's clock, not the client's, orders the store
+		f.Time = time.Now().UTC()
+		if err := f.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := store.Add(f); err != nil {
+			http.Error(w, "storing feedback failed", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusCreated, f)
+	})
+	mux.HandleFunc("/report", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "use GET", http.StatusMethodNotAllowed)
+			return
+		}
+		entries, err := store.All()
+		if err != nil {
+			http.Error(w, "reading feedback failed", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, Report(entries))
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// SubmitFeedback posts f to the feedback server at baseURL
+func SubmitFeedback(client *http.Client, baseURL string, f Feedback) error {
+	body, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(strings.TrimSuffix(baseURL, "/")+"/feedback", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("feedback server: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+// feedback_test.go
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func tempStore(t *testing.T) *FeedbackStore {
+	t.Helper()
+	return &FeedbackStore{Path: filepath.Join(t.TempDir(), "feedback.jsonl")}
+}
+
+func TestFeedbackValidate(t *testing.T) {
+	valid := Feedback{Plugin: "hello", Version: "1.0.0", Rating: 5}
+	tests := []struct {
+		name     string
+		change   func(*Feedback)
+		expected string
+	}{
+		{"Valid", func(f *Feedback) {}, ""},
+		{"No plugin", func(f *Feedback) { f.Plugin = "" }, "plugin name is required"},
+		{"No version", func(f *Feedback) { f.Version = "" }, "plugin version is required"},
+		{"Rating too low", func(f *Feedback) { f.Rating = 0 }, "rating must be from 1 to 5, got 0"},
+		{"Rating too high", func(f *Feedback) { f.Rating = 6 }, "rating must be from 1 to 5, got 6"},
+		{"Text too long", func(f *Feedback) { f.Text = strings.Repeat("a", 4001) }, "text is 4001 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := valid
+			tt.change(&f)
+			err := f.Validate()
+			if tt.expected == "" && err != nil || tt.expected != "" && (err == nil || !strings.Contains(err.Error(), tt.expected)) {
+				t.Errorf("Expected %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestFeedbackStore(t *testing.T) {
+	store := tempStore(t)
+	if entries, err := store.All(); err != nil || len(entries) != 0 {
+		t.Fatalf("Expected a missing store to be empty, got %v (%v)", entries, err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f := Feedback{Plugin: "hello", Version: "1.0.0", Rating: i%5 + 1, Text: "こんにちは\nworld"}
+			if err := store.Add(f); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := store.Add(Feedback{Plugin: "hello"}); err == nil {
+		t.Errorf("Expected invalid feedback to be refused")
+	}
+
+	// A crash in the middle of an append leaves a partial last line
+	file, err := os.OpenFile(store.Path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"plugin": "hel`)
+	file.Close()
+
+	entries, err := store.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 20 {
+		t.Fatalf("Expected 20 entries, got %d", len(entries))
+	}
+	for _, f := range entries {
+		if f.Time.IsZero() || f.Text != "こんにちは\nworld" {
+			t.Errorf("Expected a stamped entry with the text intact, got %+v", f)
+		}
+	}
+
+	// Anywhere else a bad line is an error
+	data, _ := os.ReadFile(store.Path)
+	os.WriteFile(store.Path, []byte("not json\n"+string(data)), 0o644)
+	if _, err := store.All(); err == nil || !strings.Contains(err.Error(), "feedback.jsonl:1:") {
+		t.Errorf("Expected an error naming line 1, got %v", err)
+	}
+}
+
+func TestReport(t *testing.T) {
+	entries := []Feedback{
+		{Plugin: "hello", Version: "1.10.0", Rating: 5},
+		{Plugin: "hello", Version: "1.9.0", Rating: 2},
+		{Plugin: "hello", Version: "1.9.0", Rating: 3},
+		{Plugin: "echo", Version: "0.1.0", Rating: 4},
+		{Plugin: "hello", Version: "1.10.0", Rating: 4},
+	}
+	var out strings.Builder
+	if err := WriteReport(&out, Report(entries)); err != nil {
+		t.Fatal(err)
+	}
+	expected := "" +
+		"PLUGIN  VERSION  RATINGS  MEAN  1  2  3  4  5\n" +
+		"echo    0.1.0    1        4.00  0  0  0  1  0\n" +
+		"hello   1.9.0    2        2.50  0  1  1  0  0\n" +
+		"hello   1.10.0   2        4.50  0  0  0  1  1\n"
+	if out.String() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestFeedbackHandler(t *testing.T) {
+	store := tempStore(t)
+	server := httptest.NewServer(FeedbackHandler(store))
+	defer server.Close()
+
+	if err := SubmitFeedback(server.Client(), server.URL, Feedback{Plugin: "hello", Version: "1.0.0", Rating: 4, Text: "nice"}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	err := SubmitFeedback(server.Client(), server.URL+"/", Feedback{Plugin: "hello", Version: "1.0.0", Rating: 9})
+	if err == nil || !strings.Contains(err.Error(), "400 Bad Request: feedback: rating must be from 1 to 5") {
+		t.Errorf("Expected the rating to be refused, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"Unknown field", http.MethodPost, "/feedback", `{"plugin": "hello", "version": "1.0.0", "rating": 3, "stars": 3}`, http.StatusBadRequest},
+		{"Not JSON", http.MethodPost, "/feedback", `rating=3`, http.StatusBadRequest},
+		{"Wrong method", http.MethodGet, "/feedback", "", http.StatusMethodNotAllowed},
+		{"Report", http.MethodGet, "/report", "", http.StatusOK},
+		{"Report by POST", http.MethodPost, "/report", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(tt.body))
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("Expected status %d, got %s", tt.status, resp.Status)
+			}
+			if tt.name != "Report" {
+				return
+			}
+			var reports []VersionReport
+			if err := json.NewDecoder(resp.Body).Decode(&reports); err != nil {
+				t.Fatal(err)
+			}
+			if len(reports) != 1 || reports[0].Count != 1 || reports[0].Ratings[3] != 1 {
+				t.Errorf("Expected one rating of 4, got %+v", reports)
+			}
+		})
+	}
+}
+
+func TestFeedbackCommands(t *testing.T) {
+	store := tempStore(t)
+	var out strings.Builder
+	if err := runCommand([]string{"report", "-store", store.Path}, &out); err != nil || out.String() != "No feedback yet.\n" {
+		t.Errorf("Expected an empty report, got %q (%v)", out.String(), err)
+	}
+
+	for _, args := range [][]string{
+		{"feedback", "-store", store.Path, "-rating", "5", "works", "well"},
+		{"feedback", "-store", store.Path, "-version", "2.0.0", "-rating", "1"},
+	} {
+		if err := runCommand(args, &out); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+	if err := runCommand([]string{"feedback", "-store", store.Path}, &out); err == nil {
+		t.Errorf("Expected feedback without a rating to be refused")
+	}
+	entries, _ := store.All()
+	if len(entries) != 2 || entries[0].Text != "works well" || entries[0].Environment != CurrentEnvironment() {
+		t.Errorf("Expected two entries, the first with its text and environment, got %+v", entries)
+	}
+
+	out.Reset()
+	if err := runCommand([]string{"report", "-store", store.Path, "-json"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	var reports []VersionReport
+	if err := json.Unmarshal([]byte(out.String()), &reports); err != nil || len(reports) != 2 || reports[1].Mean != 1 {
+		t.Errorf("Expected a JSON report of two versions, got %s (%v)", out.String(), err)
+	}
+
+	if err := runCommand([]string{"survey"}, &out); err == nil {
+		t.Errorf("Expected an unknown command to fail")
+	}
+}
+
+func TestGatherUserFeedback(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *Feedback
+	}{
+		{"Rated", "4\nLove it\n", &Feedback{Rating: 4, Text: "Love it"}},
+		{"Retried", "ten\n0\n5\n\n", &Feedback{Rating: 5}},
+		{"Skipped", "\n", nil},
+		{"No input", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *Feedback
+			var out strings.Builder
+			err := gatherUserFeedback(strings.NewReader(tt.input), &out, func(f Feedback) error {
+				got = &f
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.expected == nil {
+				if got != nil {
+					t.Errorf("Expected no feedback, got %+v", got)
+				}
+				return
+			}
+			if got == nil || got.Rating != tt.expected.Rating || got.Text != tt.expected.Text || got.Plugin != pluginName || got.Version != pluginVersion {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+			if !strings.Contains(out.String(), "Thank you") {
+				t.Errorf("Expected thanks, got %q", out.String())
+			}
+		})
+	}
+}
@@ -1,29 +1,22 @@
-
+// hello_fuzz_test.go
 package main
 
 import (
-	"fmt"
+	"strings"
+	"testing"
 )
 
-// HelloWorld function that returns a greeting message
-func HelloWorld(name string) string {
-	if name == "" {
-		return "Hello, !"
+func FuzzHelloWorld(f *testing.F) {
+	for _, name := range []string{"Alice", "", "こんにちは", "@#$%^&*()"} {
+		f.Add(name)
 	}
-	return fmt.Sprintf("Hello, %s!", name)
-}
-
-// gatherUserFeedback simulates gathering user feedback (for demonstration purposes)
-func gatherUserFeedback() {
-	// This can be connected to an external survey tool or feedback API.
-	fmt.Println("Please provide your feedback at: https://yourplugin.feedback")
-}
-
-func main() {
-	// Example usage of HelloWorld
-	fmt.Println(HelloWorld("Alice")) // "Hello, Alice!"
-	fmt.Println(HelloWorld(""))      // "Hello, !"
-
-	// Call feedback gathering function (just for demonstration)
-	gatherUserFeedback()
+	f.Fuzz(func(t *testing.T, name string) {
+		result := HelloWorld(name)
+		if !strings.HasPrefix(result, "Hello, ") || !strings.HasSuffix(result, "!") {
+			t.Errorf("Expected a greeting, got %q", result)
+		}
+		if inner := strings.TrimSuffix(strings.TrimPrefix(result, "Hello, "), "!"); inner != name {
+			t.Errorf("Expected the name %q in the greeting, got %q", name, inner)
+		}
+	})
 }
@@ -1,4 +1,4 @@
-//test file 
+// hello_test.go
 package main
 
 import (
@@ -0,0 +1,159 @@
+// main.go
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The plugin this program is, as it appears in feedback
+const (
+	pluginName    = "hello"
+	pluginVersion = "1.0.0"
+)
+
+// defaultStore is where feedback is kept unless -store says otherwise
+const defaultStore = "feedback.jsonl"
+
+// HelloWorld function that returns a greeting message
+func HelloWorld(name string) string {
+	if name == "" {
+		return "Hello, !"
+	}
+	return fmt.Sprintf("Hello, %s!", name)
+}
+
+// gatherUserFeedback asks the user to rate the plugin and passes the
+// answer to submit. An empty answer, or none, skips it.
+func gatherUserFeedback(in io.Reader, out io.Writer, submit func(Feedback) error) error {
+	scanner := bufio.NewScanner(in)
+	var rating int
+	for {
+		fmt.Fprintf(out, "How would you rate %s %s from 1 to 5? (Enter to skip) ", pluginName, pluginVersion)
+		if !scanner.Scan() || strings.TrimSpace(scanner.Text()) == "" {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+		if err == nil && n >= 1 && n <= 5 {
+			rating = n
+			break
+		}
+		fmt.Fprintln(out, "Please answer with a number from 1 to 5.")
+	}
+	fmt.Fprint(out, "Anything else you would like to tell us? ")
+	scanner.Scan()
+
+	err := submit(Feedback{
+		Plugin:      pluginName,
+		Version:     pluginVersion,
+		Rating:      rating,
+		Text:        strings.TrimSpace(scanner.Text()),
+		Environment: CurrentEnvironment(),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Thank you for your feedback!")
+	return nil
+}
+
+// runCommand runs a feedback subcommand:
+//
+//	feedback [-store file | -server url] [-plugin name] [-version v] -rating n [text...]
+//	serve [-store file] [-addr host:port]
+//	report [-store file] [-json]
+func runCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fs.SetOutput(out)
+	storePath := fs.String("store", defaultStore, "the JSON-lines file feedback is kept in")
+	switch args[0] {
+	case "feedback":
+		server := fs.String("server", "", "submit to the feedback server at this URL instead of the store")
+		plugin := fs.String("plugin", pluginName, "the plugin the feedback is about")
+		version := fs.String("version", pluginVersion, "its version")
+		rating := fs.Int("rating", 0, "from 1 to 5")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		f := Feedback{
+			Plugin:      *plugin,
+			Version:     *version,
+			Rating:      *rating,
+			Text:        strings.Join(fs.Args(), " "),
+			Environment: CurrentEnvironment(),
+		}
+		if *server != "" {
+			return SubmitFeedback(&http.Client{Timeout: 10 * time.Second}, *server, f)
+		}
+		return (&FeedbackStore{Path: *storePath}).Add(f)
+
+	case "serve":
+		addr := fs.String("addr", "127.0.0.1:7070", "the address to listen on")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Accepting feedback at http://%s/feedback, storing it in %s\n", *addr, *storePath)
+		server := &http.Server{
+			Addr:              *addr,
+			Handler:           FeedbackHandler(&FeedbackStore{Path: *storePath}),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		return server.ListenAndServe()
+
+	case "report":
+		asJSON := fs.Bool("json", false, "write the report as JSON")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		entries, err := (&FeedbackStore{Path: *storePath}).All()
+		if err != nil {
+			return err
+		}
+		reports := Report(entries)
+		if *asJSON {
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			return enc.Encode(reports)
+		}
+		if len(reports) == 0 {
+			_, err := fmt.Fprintln(out, "No feedback yet.")
+			return err
+		}
+		return WriteReport(out, reports)
+	}
+	return fmt.Errorf("unknown command %q; use feedback, serve or report", args[0])
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		err := runCommand(os.Args[1:], os.Stdout)
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Example usage of HelloWorld
+	fmt.Println(HelloWorld("Alice")) // "Hello, Alice!"
+	fmt.Println(HelloWorld(""))      // "Hello, !"
+
+	// Ask for feedback, kept locally; "report" summarises it
+	store := &FeedbackStore{Path: defaultStore}
+	if err := gatherUserFeedback(os.Stdin, os.Stdout, store.Add); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+	}
+}